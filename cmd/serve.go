@@ -2,13 +2,12 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"kuchak/internal/api"
 	"kuchak/internal/config"
 	"kuchak/internal/repository"
-	"kuchak/internal/repository/postgres"
-	"kuchak/internal/repository/redis"
 	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"kuchak/pkg/unfurl"
 	"os"
 	"os/signal"
 	"time"
@@ -25,26 +24,94 @@ func Serve() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	pgxSession, err := postgres.NewPostgresSession()
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to connect postgres")
+	pgxSession, redisClient := connect()
+
+	URLRedisRepository := repository.NewURLRedisRepository(redisClient)
+	URLPostgresRepository := repository.NewURLPostgresRepository(pgxSession)
+	accountPostgresRepository := repository.NewAccountPostgresRepository(pgxSession)
+	accountRedisRepository := repository.NewAccountRedisRepository(redisClient)
+	rateLimitRepository := repository.NewRateLimiterRepository(redisClient)
+	utmTemplatePostgresRepository := repository.NewUTMTemplatePostgresRepository(pgxSession)
+	clickPostgresRepository := repository.NewClickPostgresRepository(pgxSession)
+	qrRedisRepository := repository.NewQRRedisRepository(redisClient)
+	quotaRepository := repository.NewQuotaRepository(redisClient)
+	tagPostgresRepository := repository.NewTagPostgresRepository(pgxSession)
+	folderPostgresRepository := repository.NewFolderPostgresRepository(pgxSession)
+	urlHistoryPostgresRepository := repository.NewURLHistoryPostgresRepository(pgxSession)
+	auditPostgresRepository := repository.NewAuditPostgresRepository(pgxSession)
+	identityPostgresRepository := repository.NewIdentityPostgresRepository(pgxSession)
+
+	switch config.AppConfig.RedirectDefaultStatus {
+	case 301, 302, 307, 308:
+	default:
+		log.Fatal().Int("status", config.AppConfig.RedirectDefaultStatus).Msg("invalid default redirect status")
 	}
 
-	err = pgxSession.Ping(context.Background())
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to ping postgres")
+	// The unlock cookie is an HMAC, a short or empty secret would let anyone
+	// forge one for any protected link.
+	if len(config.AppConfig.UnlockCookieSecret) < 32 {
+		log.Fatal().Msg("UNLOCK_COOKIE_SECRET must be at least 32 bytes")
+	}
+
+	for name, interval := range map[string]time.Duration{
+		"SCREEN_RELOAD_INTERVAL":    config.AppConfig.ScreenReloadInterval,
+		"GEOIP_RELOAD_INTERVAL":     config.AppConfig.GeoIPReloadInterval,
+		"METADATA_FETCH_INTERVAL":   config.AppConfig.MetadataFetchInterval,
+		"TRASH_PURGE_INTERVAL":      config.AppConfig.TrashPurgeInterval,
+		"ACCOUNT_DELETION_INTERVAL": config.AppConfig.AccountDeletionInterval,
+	} {
+		if interval <= 0 {
+			log.Fatal().Dur(name, interval).Msg("interval must be positive")
+		}
+	}
+
+	auth.PasswordParams = auth.Argon2Params{
+		Memory:      config.AppConfig.PasswordArgon2Memory,
+		Iterations:  config.AppConfig.PasswordArgon2Iterations,
+		Parallelism: config.AppConfig.PasswordArgon2Parallelism,
+		SaltLength:  config.AppConfig.PasswordArgon2SaltLength,
+		KeyLength:   config.AppConfig.PasswordArgon2KeyLength,
+	}
+	if err := auth.PasswordParams.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid password hashing parameters")
+	}
+
+	if !service.IsQueryConflictPolicy(config.AppConfig.RedirectQueryConflict) {
+		log.Fatal().Str("policy", config.AppConfig.RedirectQueryConflict).Msg("invalid redirect query conflict policy")
 	}
 
-	redisClient, err := redis.NewRedisClient(fmt.Sprintf("%s:%s", config.AppConfig.RedisHost, config.AppConfig.RedisPort))
+	screeningService := newScreeningService(URLPostgresRepository, URLRedisRepository)
+
+	go screeningService.Run(ctx, config.AppConfig.ScreenReloadInterval)
+
+	geoIPService, err := service.NewGeoIPService(config.AppConfig.GeoIPDatabasePath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to connect redis")
+		log.Fatal().Err(err).Msg("failed to load geoip database")
 	}
 
-	URLRedisRepository := repository.NewURLRedisRepository(redisClient)
-	URLPostgresRepository := repository.NewURLPostgresRepository(pgxSession)
-	accountPostgresRepository := repository.NewAccountPostgresRepository(pgxSession)
-	accountRedisRepository := repository.NewAccountRedisRepository(redisClient)
-	rateLimitRepository := repository.NewRateLimiterRepository(redisClient)
+	go geoIPService.Run(ctx, config.AppConfig.GeoIPReloadInterval)
+
+	metadataService := service.NewMetadataService(URLPostgresRepository, unfurl.NewFetcher(unfurl.Options{
+		Timeout:      config.AppConfig.MetadataFetchTimeout,
+		MaxBytes:     config.AppConfig.MetadataFetchMaxBytes,
+		MaxRedirects: config.AppConfig.MetadataFetchMaxRedirects,
+		UserAgent:    "kuchak/1.0 (+" + config.AppConfig.AppURL + ")",
+	}), config.AppConfig.MetadataFetchBatch)
+
+	go metadataService.Run(ctx, config.AppConfig.MetadataFetchInterval)
+
+	trashService := service.NewTrashService(URLPostgresRepository, config.AppConfig.TrashRetention)
+
+	go trashService.Run(ctx, config.AppConfig.TrashPurgeInterval)
+
+	accountDeletionService := service.NewAccountDeletionService(accountPostgresRepository, accountRedisRepository,
+		URLPostgresRepository, URLRedisRepository, config.AppConfig.AccountDeletionGrace)
+
+	go accountDeletionService.Run(ctx, config.AppConfig.AccountDeletionInterval)
+
+	urlPolicyService := newURLPolicyService()
+	quotaService := service.NewQuotaService(quotaRepository, config.AppConfig.URLDailyQuota)
+	urlHistoryService := service.NewURLHistoryService(urlHistoryPostgresRepository)
 
 	app := service.NewApp(
 		service.NewAccountPostgresService(accountPostgresRepository),
@@ -53,6 +120,25 @@ func Serve() {
 		service.NewURLRedisService(URLRedisRepository),
 		service.NewRateLimitService(rateLimitRepository),
 		service.NewEmailService(config.AppConfig.SmtpHost, config.AppConfig.SmtpPort, config.AppConfig.SmtpUsername, config.AppConfig.SmtpPassword, config.AppConfig.SmtpUsername),
+		urlPolicyService,
+		screeningService,
+		service.NewRedirectService(config.AppConfig.RedirectQueryConflict),
+		service.NewUTMTemplatePostgresService(utmTemplatePostgresRepository),
+		service.NewClickPostgresService(clickPostgresRepository),
+		geoIPService,
+		service.NewQRService(config.AppConfig.AppURL, qrRedisRepository),
+		quotaService,
+		service.NewURLTransferService(URLPostgresRepository, urlPolicyService, screeningService, quotaService, urlHistoryService),
+		service.NewTagPostgresService(tagPostgresRepository),
+		service.NewFolderPostgresService(folderPostgresRepository),
+		trashService,
+		urlHistoryService,
+		service.NewAuditService(auditPostgresRepository),
+		accountDeletionService,
+		service.NewAccountExportService(accountPostgresRepository, URLPostgresRepository, tagPostgresRepository,
+			folderPostgresRepository, utmTemplatePostgresRepository, clickPostgresRepository, auditPostgresRepository, identityPostgresRepository),
+		newOIDCService(accountPostgresRepository, identityPostgresRepository, accountRedisRepository),
+		newKeySet(),
 	)
 
 	wa := api.NewWebApp(config.AppConfig.ServerAddr, config.AppConfig.AppURL, app)
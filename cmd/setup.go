@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"kuchak/internal/config"
+	"kuchak/internal/repository"
+	"kuchak/internal/repository/postgres"
+	"kuchak/internal/repository/redis"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"kuchak/pkg/oidc"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/rueidis"
+	"github.com/rs/zerolog/log"
+)
+
+func connect() (*pgxpool.Pool, rueidis.Client) {
+	pgxSession, err := postgres.NewPostgresSession()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect postgres")
+	}
+
+	err = pgxSession.Ping(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to ping postgres")
+	}
+
+	redisClient, err := redis.NewRedisClient(fmt.Sprintf("%s:%s", config.AppConfig.RedisHost, config.AppConfig.RedisPort))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect redis")
+	}
+
+	return pgxSession, redisClient
+}
+
+func newURLPolicyService() *service.URLPolicyService {
+	return service.NewURLPolicyService(config.AppConfig.AppURL, config.AppConfig.URLAllowedSchemes, config.AppConfig.URLMaxLength, config.AppConfig.URLAllowedDomains, config.AppConfig.URLDeniedDomains, config.AppConfig.URLShortenerDomains)
+}
+
+func newScreeningService(urls repository.URL, cache repository.URLRedis) *service.ScreeningService {
+	for _, action := range []string{config.AppConfig.ScreenBlocklistAction, config.AppConfig.ScreenIPHostAction, config.AppConfig.ScreenSubdomainAction} {
+		if !service.IsScreeningVerdict(action) {
+			log.Fatal().Str("action", action).Msg("invalid screening action")
+		}
+	}
+
+	blocklistScreener, err := service.NewBlocklistScreener(config.AppConfig.ScreenDomainBlocklists, config.AppConfig.ScreenPatternBlocklists, config.AppConfig.ScreenBlocklistAction)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load blocklists")
+	}
+
+	return service.NewScreeningService(
+		urls,
+		cache,
+		blocklistScreener,
+		service.NewHeuristicScreener(config.AppConfig.ScreenIPHostAction, config.AppConfig.ScreenMaxSubdomains, config.AppConfig.ScreenSubdomainAction),
+	)
+}
+
+func newOIDCService(accounts repository.Account, identities repository.Identity, states repository.AccountRedis) *service.OIDCService {
+	var providers []service.OIDCProvider
+	for _, provider := range config.AppConfig.OIDCProviders {
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Fatal().Str("provider", provider.Name).Msg("oidc provider needs an issuer and a client id")
+		}
+
+		providers = append(providers, service.OIDCProvider{
+			Name:       provider.Name,
+			AutoCreate: provider.AutoCreate,
+			Provider: oidc.NewProvider(oidc.Config{
+				Issuer:       provider.Issuer,
+				ClientID:     provider.ClientID,
+				ClientSecret: provider.ClientSecret,
+				RedirectURL:  fmt.Sprintf("%s/auth/oidc/%s/callback", config.AppConfig.AppURL, provider.Name),
+				Scopes:       provider.Scopes,
+			}),
+		})
+	}
+
+	return service.NewOIDCService(providers, accounts, identities, states)
+}
+
+func newKeySet() *auth.KeySet {
+	var current *auth.SigningKey
+	if config.AppConfig.JWTSigningKey == "" {
+		if !config.AppConfig.JWTEphemeralKey {
+			log.Fatal().Msg("JWT_SIGNING_KEY is not set, set JWT_EPHEMERAL_KEY=true to sign with a throwaway key in development")
+		}
+		log.Warn().Msg("no jwt signing key configured, tokens will not survive a restart")
+
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to generate jwt signing key")
+		}
+		current, err = auth.NewSigningKey(config.AppConfig.JWTSigningKeyID, key)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load jwt signing key")
+		}
+	} else {
+		current = loadSigningKey(config.AppConfig.JWTSigningKey, config.AppConfig.JWTSigningKeyID)
+	}
+
+	var previous []*auth.SigningKey
+	for _, entry := range config.AppConfig.JWTPreviousKeys {
+		// An entry is a path, or kid=path for a key that had its id set
+		// with JWT_SIGNING_KEY_ID so tokens it signed still match.
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			id, path = "", entry
+		}
+		key := loadSigningKey(path, id)
+		if !config.AppConfig.JWTKeyRotatedAt.IsZero() {
+			key.RetiresAt = config.AppConfig.JWTKeyRotatedAt.Add(config.AppConfig.JWTKeyOverlap)
+		}
+		previous = append(previous, key)
+	}
+	if len(previous) > 0 && config.AppConfig.JWTKeyRotatedAt.IsZero() {
+		log.Warn().Msg("JWT_KEY_ROTATED_AT is not set, previous jwt keys are accepted until removed")
+	}
+
+	issuer := config.AppConfig.JWTIssuer
+	if issuer == "" {
+		issuer = config.AppConfig.AppURL
+	}
+
+	keys, err := auth.NewKeySet(issuer, config.AppConfig.JWTAudience, current, previous...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load jwt keys")
+	}
+
+	log.Info().Str("kid", current.ID).Int("previous", len(previous)).Msg("jwt keys loaded")
+
+	return keys
+}
+
+func loadSigningKey(path, id string) *auth.SigningKey {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("failed to read jwt signing key")
+	}
+
+	signer, err := auth.ParsePrivateKey(data)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("failed to parse jwt signing key")
+	}
+
+	key, err := auth.NewSigningKey(id, signer)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("failed to load jwt signing key")
+	}
+
+	return key
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"kuchak/internal/config"
+	"kuchak/internal/repository"
+	"kuchak/internal/service"
+	"os"
+	"os/signal"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Export writes links to a file or stdout, e.g.
+//
+//	kuchak export -user-id 42 -format ndjson -out links.ndjson
+func Export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	userID := flags.Int("user-id", 0, "only export links of this user, 0 exports every link")
+	format := flags.String("format", service.TransferFormatCSV, "csv or ndjson")
+	out := flags.String("out", "", "file to write to, stdout when empty")
+	flags.Parse(args)
+
+	if !service.IsTransferFormat(*format) {
+		log.Fatal().Str("format", *format).Msg(service.ErrUnknownTransferFormat.Error())
+	}
+
+	config.LoadConfig()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to create export file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := newURLTransferService().Export(ctx, *userID, *format, w); err != nil {
+		log.Fatal().Err(err).Msg("failed to export urls")
+	}
+}
+
+// Import reads links from a file or stdin into a user's account and prints
+// the report as json. The daily quota does not apply to imports run here.
+func Import(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	userID := flags.Int("user-id", 0, "user that owns the imported links")
+	format := flags.String("format", service.TransferFormatCSV, "csv or ndjson")
+	file := flags.String("file", "", "file to read from, stdin when empty")
+	onConflict := flags.String("on-conflict", service.ImportConflictSkip, "skip or rename links whose short url is taken")
+	unprotect := flags.Bool("unprotect", false, "import password protected links without their password")
+	flags.Parse(args)
+
+	if *userID <= 0 {
+		log.Fatal().Msg("-user-id is required")
+	}
+
+	if !service.IsTransferFormat(*format) {
+		log.Fatal().Str("format", *format).Msg(service.ErrUnknownTransferFormat.Error())
+	}
+
+	if *onConflict != service.ImportConflictSkip && *onConflict != service.ImportConflictRename {
+		log.Fatal().Str("on_conflict", *onConflict).Msg("on-conflict must be skip or rename")
+	}
+
+	config.LoadConfig()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var r io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open import file")
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := newURLTransferService().Import(ctx, *userID, *format, r, service.ImportOptions{
+		OnConflict: *onConflict,
+		Unprotect:  *unprotect,
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to import urls")
+	}
+}
+
+func newURLTransferService() *service.URLTransferService {
+	pgxSession, redisClient := connect()
+
+	URLPostgresRepository := repository.NewURLPostgresRepository(pgxSession)
+	URLRedisRepository := repository.NewURLRedisRepository(redisClient)
+
+	return service.NewURLTransferService(
+		URLPostgresRepository,
+		newURLPolicyService(),
+		newScreeningService(URLPostgresRepository, URLRedisRepository),
+		service.NewQuotaService(repository.NewQuotaRepository(redisClient), config.AppConfig.URLDailyQuota),
+		service.NewURLHistoryService(repository.NewURLHistoryPostgresRepository(pgxSession)),
+	)
+}
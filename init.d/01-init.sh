@@ -2,8 +2,14 @@
 set -e
 
 psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
-    -- Create new user
-    CREATE USER $DB_APP_USER WITH PASSWORD '$DB_APP_PASSWORD';
+    -- Create new user, skipped when the script runs again to upgrade
+    DO \$\$
+    BEGIN
+        IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '$DB_APP_USER') THEN
+            CREATE USER $DB_APP_USER WITH PASSWORD '$DB_APP_PASSWORD';
+        END IF;
+    END
+    \$\$;
 
     -- Create database
     -- CREATE DATABASE $DB_APP_USER;
@@ -16,18 +22,178 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$DB_APP_USER" <<-E
         email VARCHAR(255) UNIQUE NOT NULL,
         password VARCHAR(255) NOT NULL,
         is_email_verified BOOLEAN DEFAULT FALSE,      
+        role VARCHAR(16) NOT NULL DEFAULT 'user',
+        deletion_scheduled_at TIMESTAMP WITH TIME ZONE,
         created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
     );
 
+    -- Upgrade users tables created by older versions
+    ALTER TABLE users
+        ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user',
+        ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+
+    CREATE TABLE IF NOT EXISTS user_identities (
+        id SERIAL PRIMARY KEY,
+        user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        provider VARCHAR(64) NOT NULL,
+        subject VARCHAR(255) NOT NULL,
+        email VARCHAR(255) NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+        UNIQUE (provider, subject)
+    );
+
+    CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
+
+    CREATE TABLE IF NOT EXISTS folders (
+        id SERIAL PRIMARY KEY,
+        user_id INT REFERENCES users(id) ON DELETE CASCADE,
+        parent_id INT REFERENCES folders(id) ON DELETE CASCADE,
+        name VARCHAR(255) NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+        UNIQUE NULLS NOT DISTINCT (user_id, parent_id, name)
+    );
+
     CREATE TABLE IF NOT EXISTS urls (
         id SERIAL PRIMARY KEY,
         short_url VARCHAR(255) UNIQUE NOT NULL,
         original_url TEXT NOT NULL,
+        title VARCHAR(512) NOT NULL DEFAULT '',
+        description TEXT NOT NULL DEFAULT '',
+        notes TEXT NOT NULL DEFAULT '',
+        metadata_status VARCHAR(16) NOT NULL DEFAULT '',
         user_id INT REFERENCES users(id) ON DELETE CASCADE,
         click_count INT DEFAULT 0,
+        screening VARCHAR(16) NOT NULL DEFAULT 'allow',
+        preview BOOLEAN NOT NULL DEFAULT FALSE,
+        force_preview BOOLEAN NOT NULL DEFAULT FALSE,
+        redirect_status SMALLINT NOT NULL DEFAULT 0,
+        forward_query BOOLEAN NOT NULL DEFAULT FALSE,
+        query_conflict VARCHAR(16) NOT NULL DEFAULT '',
+        forward_path BOOLEAN NOT NULL DEFAULT FALSE,
+        utm JSONB,
+        device_rules JSONB,
+        geo_rules JSONB,
+        variants JSONB,
+        sticky_variants BOOLEAN NOT NULL DEFAULT FALSE,
+        active_from TIMESTAMP WITH TIME ZONE,
+        schedule JSONB,
+        password_hash VARCHAR(255) NOT NULL DEFAULT '',
+        folder_id INT REFERENCES folders(id) ON DELETE SET NULL,
+        deleted_at TIMESTAMP WITH TIME ZONE,
         created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
     );
 
+    -- Upgrade urls tables created by older versions
+    ALTER TABLE urls
+        ADD COLUMN IF NOT EXISTS screening VARCHAR(16) NOT NULL DEFAULT 'allow',
+        ADD COLUMN IF NOT EXISTS preview BOOLEAN NOT NULL DEFAULT FALSE,
+        ADD COLUMN IF NOT EXISTS force_preview BOOLEAN NOT NULL DEFAULT FALSE,
+        ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NOT NULL DEFAULT 0,
+        ADD COLUMN IF NOT EXISTS forward_query BOOLEAN NOT NULL DEFAULT FALSE,
+        ADD COLUMN IF NOT EXISTS query_conflict VARCHAR(16) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS forward_path BOOLEAN NOT NULL DEFAULT FALSE,
+        ADD COLUMN IF NOT EXISTS utm JSONB,
+        ADD COLUMN IF NOT EXISTS device_rules JSONB,
+        ADD COLUMN IF NOT EXISTS geo_rules JSONB,
+        ADD COLUMN IF NOT EXISTS variants JSONB,
+        ADD COLUMN IF NOT EXISTS sticky_variants BOOLEAN NOT NULL DEFAULT FALSE,
+        ADD COLUMN IF NOT EXISTS active_from TIMESTAMP WITH TIME ZONE,
+        ADD COLUMN IF NOT EXISTS schedule JSONB,
+        ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS folder_id INT REFERENCES folders(id) ON DELETE SET NULL,
+        ADD COLUMN IF NOT EXISTS title VARCHAR(512) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS metadata_status VARCHAR(16) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+    CREATE TABLE IF NOT EXISTS utm_templates (
+        id SERIAL PRIMARY KEY,
+        user_id INT REFERENCES users(id) ON DELETE CASCADE,
+        name VARCHAR(255) NOT NULL,
+        source VARCHAR(255) NOT NULL DEFAULT '',
+        medium VARCHAR(255) NOT NULL DEFAULT '',
+        campaign VARCHAR(255) NOT NULL DEFAULT '',
+        term VARCHAR(255) NOT NULL DEFAULT '',
+        content VARCHAR(255) NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+        UNIQUE (user_id, name)
+    );
+
+    CREATE INDEX IF NOT EXISTS urls_folder_id_idx ON urls (folder_id);
+    CREATE INDEX IF NOT EXISTS urls_deleted_at_idx ON urls (deleted_at) WHERE deleted_at IS NOT NULL;
+    CREATE INDEX IF NOT EXISTS urls_metadata_pending_idx ON urls (id) WHERE metadata_status = 'pending';
+
+    CREATE TABLE IF NOT EXISTS tags (
+        id SERIAL PRIMARY KEY,
+        user_id INT REFERENCES users(id) ON DELETE CASCADE,
+        name VARCHAR(64) NOT NULL,
+        color VARCHAR(9) NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+        UNIQUE (user_id, name)
+    );
+
+    CREATE TABLE IF NOT EXISTS url_tags (
+        url_id INT REFERENCES urls(id) ON DELETE CASCADE,
+        tag_id INT REFERENCES tags(id) ON DELETE CASCADE,
+        PRIMARY KEY (url_id, tag_id)
+    );
+
+    CREATE INDEX IF NOT EXISTS url_tags_tag_id_idx ON url_tags (tag_id);
+
+    CREATE TABLE IF NOT EXISTS url_history (
+        id BIGSERIAL PRIMARY KEY,
+        url_id INT REFERENCES urls(id) ON DELETE CASCADE,
+        version INT NOT NULL,
+        action VARCHAR(16) NOT NULL,
+        actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+        request_id VARCHAR(64) NOT NULL DEFAULT '',
+        changes JSONB,
+        snapshot JSONB NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+        UNIQUE (url_id, version)
+    );
+
+    CREATE TABLE IF NOT EXISTS clicks (
+        id BIGSERIAL PRIMARY KEY,
+        url_id INT REFERENCES urls(id) ON DELETE CASCADE,
+        utm_source VARCHAR(255) NOT NULL DEFAULT '',
+        utm_medium VARCHAR(255) NOT NULL DEFAULT '',
+        utm_campaign VARCHAR(255) NOT NULL DEFAULT '',
+        os VARCHAR(16) NOT NULL DEFAULT '',
+        device VARCHAR(16) NOT NULL DEFAULT '',
+        bot BOOLEAN NOT NULL DEFAULT FALSE,
+        country VARCHAR(2) NOT NULL DEFAULT '',
+        variant VARCHAR(64) NOT NULL DEFAULT '',
+        clicked_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+    );
+
+    CREATE INDEX IF NOT EXISTS clicks_url_id_clicked_at_idx ON clicks (url_id, clicked_at);
+
+    CREATE TABLE IF NOT EXISTS conversions (
+        id BIGSERIAL PRIMARY KEY,
+        url_id INT REFERENCES urls(id) ON DELETE CASCADE,
+        variant VARCHAR(64) NOT NULL DEFAULT '',
+        converted_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+    );
+
+    CREATE INDEX IF NOT EXISTS conversions_url_id_idx ON conversions (url_id);
+
+    CREATE TABLE IF NOT EXISTS audit_events (
+        id BIGSERIAL PRIMARY KEY,
+        user_id INT REFERENCES users(id) ON DELETE CASCADE,
+        actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+        event VARCHAR(64) NOT NULL,
+        ip VARCHAR(45) NOT NULL DEFAULT '',
+        user_agent VARCHAR(512) NOT NULL DEFAULT '',
+        request_id VARCHAR(64) NOT NULL DEFAULT '',
+        details JSONB,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+    );
+
+    CREATE INDEX IF NOT EXISTS audit_events_user_id_idx ON audit_events (user_id, id);
+    CREATE INDEX IF NOT EXISTS audit_events_event_idx ON audit_events (event, id);
+
     -- Grant privileges
     GRANT ALL PRIVILEGES ON DATABASE $DB_APP_USER TO $DB_APP_USER;
     GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO $DB_APP_USER;
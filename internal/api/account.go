@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/rueidis"
+	"github.com/rs/zerolog/log"
+)
+
+// humanDuration formats d in days when it is a whole number of them.
+func humanDuration(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		days := int(d / (24 * time.Hour))
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
+
+// requestAccountDeletion emails a link to confirm deleting the account.
+func (w *WebApp) requestAccountDeletion(c echo.Context) error {
+	var passwordRequest PasswordRequest
+	if err := c.Bind(&passwordRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(passwordRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	dbUser, err := w.App.AccountPostgres.GetUserByID(c.Request().Context(), user.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
+	}
+
+	if err := auth.PasswordVerify(dbUser.Password, passwordRequest.Password); err != nil {
+		w.audit(c, entity.AuditAccountDeletionFailed, dbUser.ID, echo.Map{"reason": "incorrect password"})
+		return c.JSON(http.StatusUnauthorized, ErrMessage{
+			Message: "incorrect password",
+			Success: false,
+		})
+	}
+
+	if dbUser.DeletionScheduledAt != nil {
+		return c.JSON(http.StatusConflict, ErrMessage{
+			Message: "account deletion already scheduled",
+			Success: false,
+		})
+	}
+
+	resp, err := w.App.AccountRedis.GetAccountDeletionByUser(c.Request().Context(), dbUser.ID)
+	if err != nil && !errors.Is(err, rueidis.Nil) {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to request account deletion",
+			Success: false,
+		})
+	}
+
+	if resp != "" {
+		return c.JSON(http.StatusConflict, ErrMessage{
+			Message: "account deletion already requested",
+			Success: false,
+		})
+	}
+
+	token, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate account deletion token",
+			Success: false,
+		})
+	}
+
+	if err := w.App.AccountRedis.SetAccountDeletion(c.Request().Context(), dbUser.ID, token); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to request account deletion",
+			Success: false,
+		})
+	}
+
+	confirmURL := fmt.Sprintf("%s/auth/confirmDelete/%s", w.appURL, token)
+	grace := humanDuration(w.App.AccountDeletion.Grace())
+
+	if err := w.App.EmailSender.SendAccountDeletionConfirmation(dbUser.Email, confirmURL, grace); err != nil {
+		log.Err(err).Int("user_id", dbUser.ID).Msg("failed to send account deletion confirmation")
+		// Consume the token so the user can ask again right away.
+		w.App.AccountRedis.GetByAccountDeletionToken(c.Request().Context(), token)
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to send account deletion confirmation",
+			Success: false,
+		})
+	}
+
+	w.audit(c, entity.AuditAccountDeletionRequested, dbUser.ID, nil)
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "confirmation email sent",
+		Success: true,
+	})
+}
+
+// confirmAccountDeletion schedules the deletion and signs the user out
+// everywhere. Signing in again and canceling keeps the account.
+func (w *WebApp) confirmAccountDeletion(c echo.Context) error {
+	userID, err := w.App.AccountRedis.GetByAccountDeletionToken(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		if errors.Is(err, rueidis.Nil) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "account deletion token is not valid or expired",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to check account deletion token",
+			Success: false,
+		})
+	}
+
+	dbUser, err := w.App.AccountPostgres.GetUserByID(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
+	}
+
+	deleteAt, err := w.App.AccountDeletion.Schedule(c.Request().Context(), dbUser)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to schedule account deletion",
+			Success: false,
+		})
+	}
+
+	w.audit(c, entity.AuditAccountDeletionScheduled, dbUser.ID, echo.Map{"delete_at": deleteAt})
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: fmt.Sprintf("account will be deleted in %s, sign in to cancel", humanDuration(w.App.AccountDeletion.Grace())),
+		Success: true,
+		Data: echo.Map{
+			"deletion_scheduled_at": deleteAt,
+		},
+	})
+}
+
+func (w *WebApp) cancelAccountDeletion(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	dbUser, err := w.App.AccountPostgres.GetUserByID(c.Request().Context(), user.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
+	}
+
+	if dbUser.DeletionScheduledAt == nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "account deletion is not scheduled",
+			Success: false,
+		})
+	}
+
+	if err := w.App.AccountDeletion.Cancel(c.Request().Context(), dbUser); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to cancel account deletion",
+			Success: false,
+		})
+	}
+
+	w.audit(c, entity.AuditAccountDeletionCanceled, dbUser.ID, nil)
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "account deletion canceled",
+		Success: true,
+	})
+}
+
+// exportAccount streams a zip of everything stored about the account.
+func (w *WebApp) exportAccount(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	w.audit(c, entity.AuditAccountExported, user.UserID, nil)
+
+	filename := fmt.Sprintf("kuchak-account-%s.zip", time.Now().UTC().Format(time.DateOnly))
+	c.Response().Header().Set(echo.HeaderContentType, "application/zip")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The status is already sent, a failure can only cut the stream short.
+	if err := w.App.AccountExport.Export(c.Request().Context(), user.UserID, c.Response()); err != nil {
+		log.Err(err).Int("user_id", user.UserID).Msg("failed to export account")
+	}
+
+	return nil
+}
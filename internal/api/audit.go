@@ -0,0 +1,156 @@
+package api
+
+import (
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// audit records event for the account userID, 0 when the account is not
+// known. The signed in user is stored as the actor when they act on
+// someone else's account.
+func (w *WebApp) audit(c echo.Context, event string, userID int, details echo.Map) {
+	auditEvent := entity.AuditEvent{
+		Event:     event,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		RequestID: requestID(c),
+		Details:   details,
+	}
+
+	if userID != 0 {
+		auditEvent.UserID = &userID
+	}
+
+	if claims, ok := c.Get("user").(*auth.Claims); ok && claims.UserID != userID {
+		actorID := claims.UserID
+		auditEvent.ActorID = &actorID
+	}
+
+	w.App.Audit.Record(c.Request().Context(), auditEvent)
+}
+
+// auditFilter reads the filters shared by the audit endpoints from the
+// query string. from and until are RFC 3339 timestamps.
+func auditFilter(c echo.Context) (entity.AuditFilter, error) {
+	filter := entity.AuditFilter{
+		Event: c.QueryParam("event"),
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, &invalidRequestError{message: "invalid from time"}
+		}
+		filter.From = &t
+	}
+
+	if until := c.QueryParam("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, &invalidRequestError{message: "invalid until time"}
+		}
+		filter.Until = &t
+	}
+
+	if beforeID := c.QueryParam("before_id"); beforeID != "" {
+		ID, err := strconv.ParseInt(beforeID, 10, 64)
+		if err != nil || ID < 1 {
+			return filter, &invalidRequestError{message: "invalid before id"}
+		}
+		filter.BeforeID = ID
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return filter, &invalidRequestError{message: "invalid limit"}
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+func (w *WebApp) getAccountAudit(c echo.Context) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: err.Error(),
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+	filter.UserID = user.UserID
+
+	events, err := w.App.Audit.GetEvents(c.Request().Context(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch audit events",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"events": events,
+		},
+	})
+}
+
+// getAuditEvents lets admins query the events of every account, on top of
+// the usual filters they can pick a user_id, actor_id or ip.
+func (w *WebApp) getAuditEvents(c echo.Context) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: err.Error(),
+			Success: false,
+		})
+	}
+
+	if userID := c.QueryParam("user_id"); userID != "" {
+		ID, err := strconv.Atoi(userID)
+		if err != nil || ID < 1 {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "invalid user id",
+				Success: false,
+			})
+		}
+		filter.UserID = ID
+	}
+
+	if actorID := c.QueryParam("actor_id"); actorID != "" {
+		ID, err := strconv.Atoi(actorID)
+		if err != nil || ID < 1 {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "invalid actor id",
+				Success: false,
+			})
+		}
+		filter.ActorID = ID
+	}
+
+	filter.IP = c.QueryParam("ip")
+
+	events, err := w.App.Audit.GetEvents(c.Request().Context(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch audit events",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"events": events,
+		},
+	})
+}
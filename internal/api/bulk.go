@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/config"
+	"kuchak/internal/entity"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"kuchak/pkg/utils"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// bulkInsertRounds bounds how often randomly generated short urls that
+// collided are regenerated and retried.
+const bulkInsertRounds = 5
+
+func (w *WebApp) createURLs(c echo.Context) error {
+	var bulkRequest BulkURLRequest
+	if err := c.Bind(&bulkRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if len(bulkRequest.URLs) == 0 || len(bulkRequest.URLs) > config.AppConfig.BulkMaxURLs {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: between 1 and %d urls are allowed", config.AppConfig.BulkMaxURLs),
+			Success: false,
+		})
+	}
+
+	ctx := c.Request().Context()
+	user := c.Get("user").(*auth.Claims)
+
+	results := make([]BulkURLResult, len(bulkRequest.URLs))
+	aliases := map[string]int{}
+
+	var pending []int
+	var urls []entity.URL
+	for i, item := range bulkRequest.URLs {
+		results[i].Index = i
+
+		if err := c.Validate(item); err != nil {
+			results[i].Error = fmt.Sprintf("failed to validate payload: %s", err.Error())
+			continue
+		}
+
+		if item.Alias != "" {
+			if err := service.ValidateAlias(item.Alias); err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			if _, ok := aliases[item.Alias]; ok {
+				results[i].Error = "alias is used twice in this request"
+				continue
+			}
+			aliases[item.Alias] = i
+		}
+
+		url := entity.URL{UserID: user.UserID, ShortURL: item.Alias}
+		if err := w.applyURLRequest(ctx, item.URLRequest, user.UserID, &url); err != nil {
+			var invalid *invalidRequestError
+			if errors.As(err, &invalid) {
+				results[i].Error = invalid.message
+			} else {
+				results[i].Error = "failed to create url"
+			}
+			continue
+		}
+
+		pending = append(pending, i)
+		urls = append(urls, url)
+	}
+
+	reserved := len(pending)
+	allowed, remaining, err := w.App.Quota.ReserveLinks(ctx, user.UserID, reserved)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create urls",
+			Success: false,
+		})
+	}
+	if !allowed {
+		return c.JSON(http.StatusTooManyRequests, ErrMessage{
+			Message: fmt.Sprintf("daily link quota exceeded, %d links left today", remaining),
+			Success: false,
+		})
+	}
+
+	created := 0
+	for round := 0; len(pending) > 0 && round < bulkInsertRounds; round++ {
+		for j := range urls {
+			if bulkRequest.URLs[pending[j]].Alias == "" {
+				urls[j].ShortURL = utils.GenerateRandomString()
+			}
+		}
+
+		inserted, err := w.App.URLPostgres.CreateURLs(ctx, urls)
+		if err != nil {
+			w.App.Quota.ReleaseLinks(ctx, user.UserID, reserved-created)
+			return c.JSON(http.StatusInternalServerError, ErrMessage{
+				Message: "failed to create urls",
+				Success: false,
+			})
+		}
+
+		var entries []entity.URLHistory
+		var retryPending []int
+		var retryURLs []entity.URL
+		for j, ok := range inserted {
+			i := pending[j]
+			switch {
+			case ok:
+				url := urls[j]
+				results[i].Success = true
+				results[i].URL = &url
+				created++
+				entries = append(entries, service.Entry(entity.HistoryCreate, nil, url, user.UserID, requestID(c)))
+			case bulkRequest.URLs[i].Alias != "":
+				results[i].Error = "alias is already taken"
+			default:
+				retryPending = append(retryPending, i)
+				retryURLs = append(retryURLs, urls[j])
+			}
+		}
+		pending, urls = retryPending, retryURLs
+
+		w.App.URLHistory.Record(ctx, entries...)
+	}
+
+	for _, i := range pending {
+		results[i].Error = "failed to generate a unique short url"
+	}
+
+	w.App.Quota.ReleaseLinks(ctx, user.UserID, reserved-created)
+
+	log.Info().Int("user_id", user.UserID).Int("requested", len(results)).Int("created", created).Msg("bulk urls created")
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: fmt.Sprintf("%d of %d urls created", created, len(results)),
+		Success: true,
+		Data: echo.Map{
+			"created": created,
+			"failed":  len(results) - created,
+			"results": results,
+		},
+	})
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errFolderNotFound = errors.New("folder not found")
+	errFolderCycle    = errors.New("folder can not be moved into itself")
+	errFolderTooDeep  = fmt.Errorf("folders can not be nested more than %d levels deep", entity.MaxFolderDepth)
+)
+
+// checkFolder makes sure the folder belongs to the user.
+func (w *WebApp) checkFolder(ctx context.Context, ID, userID int) error {
+	folder, err := w.App.Folder.GetFolderByID(ctx, ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errFolderNotFound
+		}
+		return err
+	}
+
+	if folder.UserID != userID {
+		return errFolderNotFound
+	}
+
+	return nil
+}
+
+// folderParent checks that folderID, or a new folder when it is 0, can be
+// put inside parentID and returns the parent to store.
+func (w *WebApp) folderParent(ctx context.Context, parentID, folderID, userID int) (*int, error) {
+	if parentID == 0 {
+		return nil, nil
+	}
+
+	if err := w.checkFolder(ctx, parentID, userID); err != nil {
+		return nil, err
+	}
+
+	ancestors, err := w.App.Folder.GetAncestorIDs(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if folderID != 0 && slices.Contains(ancestors, folderID) {
+		return nil, errFolderCycle
+	}
+
+	// The moved folder takes its subfolders along, so the deepest of them
+	// has to fit as well.
+	height := 0
+	if folderID != 0 {
+		height, err = w.App.Folder.GetSubtreeHeight(ctx, folderID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ancestors)+1+height > entity.MaxFolderDepth {
+		return nil, errFolderTooDeep
+	}
+
+	return &parentID, nil
+}
+
+func isFolderRequestError(err error) bool {
+	return errors.Is(err, errFolderNotFound) || errors.Is(err, errFolderCycle) || errors.Is(err, errFolderTooDeep)
+}
+
+func (w *WebApp) getAllFolders(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	folders, err := w.App.Folder.GetFoldersByUserID(c.Request().Context(), user.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch folders",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"folders": folders,
+		},
+	})
+}
+
+func (w *WebApp) createFolder(c echo.Context) error {
+	var folderRequest FolderRequest
+	if err := c.Bind(&folderRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(folderRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	parentID, err := w.folderParent(c.Request().Context(), folderRequest.ParentID, 0, user.UserID)
+	if err != nil {
+		if isFolderRequestError(err) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create folder",
+			Success: false,
+		})
+	}
+
+	folder, err := w.App.Folder.CreateFolder(c.Request().Context(), entity.Folder{
+		UserID:   user.UserID,
+		ParentID: parentID,
+		Name:     folderRequest.Name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "folder already exists",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create folder",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "folder created successfully",
+		Success: true,
+		Data: echo.Map{
+			"folder": folder,
+		},
+	})
+}
+
+func (w *WebApp) updateFolder(c echo.Context) error {
+	var folderRequest FolderRequest
+	if err := c.Bind(&folderRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(folderRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid folder id",
+			Success: false,
+		})
+	}
+
+	folder, err := w.App.Folder.GetFolderByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "folder not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch folder",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if folder.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to update this folder",
+			Success: false,
+		})
+	}
+
+	parentID, err := w.folderParent(c.Request().Context(), folderRequest.ParentID, folder.ID, user.UserID)
+	if err != nil {
+		if isFolderRequestError(err) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update folder",
+			Success: false,
+		})
+	}
+
+	folder.Name = folderRequest.Name
+	folder.ParentID = parentID
+
+	if err := w.App.Folder.UpdateFolder(c.Request().Context(), folder); err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "folder already exists",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update folder",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "folder updated successfully",
+		Success: true,
+		Data: echo.Map{
+			"folder": folder,
+		},
+	})
+}
+
+// deleteFolder removes a folder, its links and subfolders move up into its
+// parent.
+func (w *WebApp) deleteFolder(c echo.Context) error {
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid folder id",
+			Success: false,
+		})
+	}
+
+	folder, err := w.App.Folder.GetFolderByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "folder not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch folder",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if folder.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to delete this folder",
+			Success: false,
+		})
+	}
+
+	if err := w.App.Folder.DeleteFolder(c.Request().Context(), folder); err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "a subfolder has the same name as a folder in the parent folder",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to delete folder",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "folder deleted successfully",
+		Success: true,
+	})
+}
@@ -6,9 +6,15 @@ import (
 	"fmt"
 	"kuchak/internal/config"
 	"kuchak/internal/entity"
+	"kuchak/internal/service"
 	"kuchak/pkg/auth"
+	"kuchak/pkg/useragent"
 	"kuchak/pkg/utils"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -21,6 +27,13 @@ func (w *WebApp) healthz(c echo.Context) error {
 	return c.String(http.StatusOK, "OK\n")
 }
 
+// jwks publishes the keys our tokens are signed with. It is short lived in
+// caches so a retired key drops out soon after its overlap ends.
+func (w *WebApp) jwks(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, w.App.Tokens.JWKS())
+}
+
 func (w *WebApp) login(c echo.Context) error {
 	var loginRequest LoginRequest
 	if err := c.Bind(&loginRequest); err != nil {
@@ -42,6 +55,7 @@ func (w *WebApp) login(c echo.Context) error {
 	dbUser, err := w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), loginRequest.Email)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
+			w.audit(c, entity.AuditLoginFailed, 0, echo.Map{"email": loginRequest.Email, "reason": "unknown email"})
 			return c.JSON(http.StatusNotFound, ErrMessage{
 				Message: "user not found",
 				Success: false,
@@ -55,6 +69,7 @@ func (w *WebApp) login(c echo.Context) error {
 
 	if !dbUser.IsEmailVerified {
 		log.Error().Str("email", dbUser.Email).Msg("email not verified")
+		w.audit(c, entity.AuditLoginFailed, dbUser.ID, echo.Map{"reason": "email not verified"})
 		return c.JSON(http.StatusUnauthorized, ErrMessage{
 			Message: "email not verified",
 			Success: false,
@@ -62,13 +77,26 @@ func (w *WebApp) login(c echo.Context) error {
 	}
 
 	if err := auth.PasswordVerify(dbUser.Password, loginRequest.Password); err != nil {
+		w.audit(c, entity.AuditLoginFailed, dbUser.ID, echo.Map{"reason": "incorrect password"})
 		return c.JSON(http.StatusUnauthorized, ErrMessage{
 			Message: "incorrect password",
 			Success: false,
 		})
 	}
 
-	accessToken, err := auth.GenerateToken(dbUser, config.AppConfig.AccessTokenSecret, auth.AccessTokenExp)
+	// Hashes from bcrypt or older argon2 parameters are upgraded while we
+	// have the password at hand. Failing to do so does not fail the login.
+	if auth.PasswordNeedsRehash(dbUser.Password) {
+		if hashedPassword, err := auth.PasswordHash(loginRequest.Password); err == nil {
+			rehashed := dbUser
+			rehashed.Password = hashedPassword
+			if err := w.App.AccountPostgres.UpdateUserPassword(c.Request().Context(), rehashed); err != nil {
+				log.Err(err).Int("user_id", dbUser.ID).Msg("failed to rehash password")
+			}
+		}
+	}
+
+	accessToken, err := w.App.Tokens.GenerateToken(dbUser, auth.AccessToken, auth.AccessTokenExp)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrMessage{
 			Message: "failed to generate access token",
@@ -76,7 +104,7 @@ func (w *WebApp) login(c echo.Context) error {
 		})
 	}
 
-	refreshToken, err := auth.GenerateToken(dbUser, config.AppConfig.RefreshTokenSecret, auth.RefreshTokenExp)
+	refreshToken, err := w.App.Tokens.GenerateToken(dbUser, auth.RefreshToken, auth.RefreshTokenExp)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrMessage{
 			Message: "failed to generate refresh token",
@@ -84,6 +112,8 @@ func (w *WebApp) login(c echo.Context) error {
 		})
 	}
 
+	w.audit(c, entity.AuditLoginSucceeded, dbUser.ID, nil)
+
 	return c.JSON(http.StatusOK, AuthTokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
@@ -96,8 +126,18 @@ func (w *WebApp) refreshToken(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "refresh token required")
 	}
 
-	claims, err := auth.ValidateToken(refreshToken, config.AppConfig.RefreshTokenSecret)
+	claims, err := w.App.Tokens.ValidateToken(refreshToken, auth.RefreshToken)
+	if err != nil {
+		w.audit(c, entity.AuditTokenRefreshFailed, 0, nil)
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+	}
+
+	revoked, err := w.App.AccountRedis.IsTokenRevoked(c.Request().Context(), claims)
 	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check token")
+	}
+	if revoked {
+		w.audit(c, entity.AuditTokenRefreshFailed, claims.UserID, echo.Map{"reason": "revoked"})
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
 	}
 
@@ -106,11 +146,13 @@ func (w *WebApp) refreshToken(c echo.Context) error {
 		Email: claims.Email,
 	}
 
-	newAccessToken, err := auth.GenerateToken(user, config.AppConfig.AccessTokenSecret, auth.AccessTokenExp)
+	newAccessToken, err := w.App.Tokens.GenerateToken(user, auth.AccessToken, auth.AccessTokenExp)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate access token")
 	}
 
+	w.audit(c, entity.AuditTokenRefreshed, claims.UserID, nil)
+
 	return c.JSON(http.StatusOK, echo.Map{
 		"access_token": newAccessToken,
 	})
@@ -177,6 +219,10 @@ func (w *WebApp) register(c echo.Context) error {
 		})
 	}
 
+	if dbUser, err := w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), newUser.Email); err == nil {
+		w.audit(c, entity.AuditRegister, dbUser.ID, nil)
+	}
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "user created",
 		Success: true,
@@ -300,6 +346,8 @@ func (w *WebApp) verifyEmail(c echo.Context) error {
 		})
 	}
 
+	w.audit(c, entity.AuditEmailVerified, dbUser.ID, nil)
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "email verified successfully",
 		Success: true,
@@ -309,9 +357,11 @@ func (w *WebApp) verifyEmail(c echo.Context) error {
 	})
 }
 
+// updateEmail starts an email change. Nothing changes until the link sent
+// to the new address is opened, and the current address is told about it.
 func (w *WebApp) updateEmail(c echo.Context) error {
-	var updateEmailRequest EmailRequest
-	if err := c.Bind(&updateEmailRequest); err != nil {
+	var emailChangeRequest EmailChangeRequest
+	if err := c.Bind(&emailChangeRequest); err != nil {
 		log.Err(err).Msg("failed to bind request body")
 		return c.JSON(http.StatusBadRequest, ErrMessage{
 			Message: "invalid request body",
@@ -319,7 +369,7 @@ func (w *WebApp) updateEmail(c echo.Context) error {
 		})
 	}
 
-	if err := c.Validate(updateEmailRequest); err != nil {
+	if err := c.Validate(emailChangeRequest); err != nil {
 		log.Err(err).Msg("failed to validate payload")
 		return c.JSON(http.StatusBadRequest, ErrMessage{
 			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
@@ -329,24 +379,158 @@ func (w *WebApp) updateEmail(c echo.Context) error {
 
 	user := c.Get("user").(*auth.Claims)
 
-	updatedUser := entity.User{
-		ID:              user.UserID,
-		Email:           updateEmailRequest.Email,
-		IsEmailVerified: false,
+	dbUser, err := w.App.AccountPostgres.GetUserByID(c.Request().Context(), user.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
+	}
+
+	if err := auth.PasswordVerify(dbUser.Password, emailChangeRequest.Password); err != nil {
+		w.audit(c, entity.AuditEmailChangeFailed, dbUser.ID, echo.Map{"reason": "incorrect password"})
+		return c.JSON(http.StatusUnauthorized, ErrMessage{
+			Message: "incorrect password",
+			Success: false,
+		})
+	}
+
+	if strings.EqualFold(dbUser.Email, emailChangeRequest.Email) {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "new email is the same as the current one",
+			Success: false,
+		})
+	}
+
+	resp, err := w.App.AccountRedis.GetEmailChangeByUser(c.Request().Context(), dbUser.ID)
+	if err != nil && !errors.Is(err, rueidis.Nil) {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update email",
+			Success: false,
+		})
+	}
+
+	if resp != "" {
+		return c.JSON(http.StatusConflict, ErrMessage{
+			Message: "email change already requested",
+			Success: false,
+		})
+	}
+
+	_, err = w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), emailChangeRequest.Email)
+	if err == nil {
+		return c.JSON(http.StatusConflict, ErrMessage{
+			Message: "email is already in use",
+			Success: false,
+		})
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update email",
+			Success: false,
+		})
+	}
+
+	token, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate email change token",
+			Success: false,
+		})
+	}
+
+	if err := w.App.AccountRedis.SetEmailChange(c.Request().Context(), dbUser.ID, emailChangeRequest.Email, token); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update email",
+			Success: false,
+		})
+	}
+
+	confirmURL := fmt.Sprintf("%s/auth/confirmEmail/%s", w.appURL, token)
+
+	if err := w.App.EmailSender.SendEmailChangeConfirmation(emailChangeRequest.Email, confirmURL); err != nil {
+		log.Err(err).Int("user_id", dbUser.ID).Msg("failed to send email change confirmation")
+		// Consume the token so the user can ask again right away.
+		w.App.AccountRedis.GetByEmailChangeToken(c.Request().Context(), token)
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to send email change confirmation",
+			Success: false,
+		})
+	}
+
+	if err := w.App.EmailSender.SendEmailChangeNotice(dbUser.Email, emailChangeRequest.Email); err != nil {
+		log.Err(err).Int("user_id", dbUser.ID).Msg("failed to send email change notice")
+	}
+
+	w.audit(c, entity.AuditEmailChangeRequested, dbUser.ID, echo.Map{"to": emailChangeRequest.Email})
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "confirmation email sent to the new address",
+		Success: true,
+	})
+}
+
+func (w *WebApp) confirmEmail(c echo.Context) error {
+	userID, email, err := w.App.AccountRedis.GetByEmailChangeToken(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		if errors.Is(err, rueidis.Nil) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "email change token is not valid or expired",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to check email change token",
+			Success: false,
+		})
+	}
+
+	dbUser, err := w.App.AccountPostgres.GetUserByID(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
 	}
 
-	if err := w.App.AccountPostgres.UpdateUserEmail(c.Request().Context(), updatedUser); err != nil {
+	oldEmail := dbUser.Email
+
+	// Opening the link proves the new address is the user's.
+	dbUser.Email = email
+	dbUser.IsEmailVerified = true
+
+	if err := w.App.AccountPostgres.UpdateUserEmail(c.Request().Context(), dbUser); err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "email is already in use",
+				Success: false,
+			})
+		}
 		return c.JSON(http.StatusInternalServerError, ErrMessage{
 			Message: "failed to update email",
 			Success: false,
 		})
 	}
 
+	w.audit(c, entity.AuditEmailChanged, dbUser.ID, echo.Map{"from": oldEmail, "to": dbUser.Email})
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "email updated successfully",
 		Success: true,
 		Data: echo.Map{
-			"user": updatedUser,
+			"user": dbUser,
 		},
 	})
 }
@@ -395,6 +579,7 @@ func (w *WebApp) updatePassword(c echo.Context) error {
 	}
 
 	if err := auth.PasswordVerify(dbUser.Password, passwordUpdateRequest.OldPassword); err != nil {
+		w.audit(c, entity.AuditPasswordChangeFailed, dbUser.ID, echo.Map{"reason": "incorrect password"})
 		return c.JSON(http.StatusUnauthorized, ErrMessage{
 			Message: "incorrect password",
 			Success: false,
@@ -418,6 +603,8 @@ func (w *WebApp) updatePassword(c echo.Context) error {
 		})
 	}
 
+	w.audit(c, entity.AuditPasswordChanged, dbUser.ID, nil)
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "password updated successfully",
 		Success: true,
@@ -457,7 +644,7 @@ func (w *WebApp) requestResetPassword(c echo.Context) error {
 		})
 	}
 
-	_, err = w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), emailRequest.Email)
+	dbUser, err := w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), emailRequest.Email)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return c.JSON(http.StatusBadRequest, ErrMessage{
@@ -495,6 +682,8 @@ func (w *WebApp) requestResetPassword(c echo.Context) error {
 		})
 	}
 
+	w.audit(c, entity.AuditPasswordResetRequest, dbUser.ID, nil)
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "reset password email successfully sent",
 		Success: true,
@@ -571,6 +760,8 @@ func (w *WebApp) resetPassword(c echo.Context) error {
 		})
 	}
 
+	w.audit(c, entity.AuditPasswordReset, dbUser.ID, nil)
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "password changed successfully",
 		Success: true,
@@ -578,12 +769,184 @@ func (w *WebApp) resetPassword(c echo.Context) error {
 }
 
 func isUniqueViolation(err error) bool {
-	if pgErr, ok := err.(*pgconn.PgError); ok {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
 		return pgErr.Code == "23505"
 	}
 	return false
 }
 
+// invalidRequestError reports a request the client has to fix, its message
+// is safe to return as is.
+type invalidRequestError struct {
+	message string
+}
+
+func (e *invalidRequestError) Error() string {
+	return e.message
+}
+
+// applyURLRequest validates the destinations and options of req and applies
+// the ones it sets to url, the others keep their current value.
+func (w *WebApp) applyURLRequest(ctx context.Context, req URLRequest, userID int, url *entity.URL) error {
+	if req.OriginalURL == nil && url.ID == 0 {
+		return &invalidRequestError{message: "original_url is required"}
+	}
+
+	destinationChanged := false
+	if req.OriginalURL != nil {
+		originalURL, err := w.App.URLPolicy.Normalize(*req.OriginalURL)
+		if err != nil {
+			log.Err(err).Str("original_url", *req.OriginalURL).Msg("url rejected by policy")
+			return &invalidRequestError{message: err.Error()}
+		}
+		destinationChanged = url.OriginalURL != originalURL
+		url.OriginalURL = originalURL
+	}
+
+	if req.DeviceRules != nil {
+		var deviceRules []entity.DeviceRule
+		for _, rule := range *req.DeviceRules {
+			deviceRules = append(deviceRules, entity.DeviceRule{
+				OS:          rule.OS,
+				Device:      rule.Device,
+				Bot:         rule.Bot,
+				Destination: rule.Destination,
+			})
+		}
+		url.DeviceRules = deviceRules
+	}
+
+	if req.GeoRules != nil {
+		var geoRules []entity.GeoRule
+		for _, rule := range *req.GeoRules {
+			geoRules = append(geoRules, entity.GeoRule{
+				Countries:   rule.Countries,
+				Destination: rule.Destination,
+			})
+		}
+		url.GeoRules = geoRules
+	}
+
+	if req.Variants != nil {
+		var variants []entity.Variant
+		for _, variant := range *req.Variants {
+			variants = append(variants, entity.Variant{
+				Name:        variant.Name,
+				Destination: variant.Destination,
+				Weight:      variant.Weight,
+			})
+		}
+		url.Variants = variants
+	}
+
+	if req.Schedule != nil {
+		var schedule []entity.ScheduleRule
+		for _, rule := range *req.Schedule {
+			schedule = append(schedule, entity.ScheduleRule{
+				From:        rule.From,
+				Until:       rule.Until,
+				Destination: rule.Destination,
+			})
+		}
+		url.Schedule = schedule
+	}
+
+	if req.ActiveFrom != nil {
+		url.ActiveFrom = nil
+		if *req.ActiveFrom != "" {
+			activeFrom, err := time.Parse(time.RFC3339, *req.ActiveFrom)
+			if err != nil {
+				return &invalidRequestError{message: "active_from must be an RFC 3339 time"}
+			}
+			url.ActiveFrom = &activeFrom
+		}
+	}
+
+	if req.UTMTemplateID != nil {
+		utm, err := w.utmFromTemplate(ctx, *req.UTMTemplateID, userID)
+		if err != nil {
+			if errors.Is(err, errUTMTemplateNotFound) {
+				return &invalidRequestError{message: err.Error()}
+			}
+			return err
+		}
+		url.UTM = utm
+	}
+
+	if req.Title != nil {
+		url.Title = *req.Title
+	}
+	if req.Description != nil {
+		url.Description = *req.Description
+	}
+	if req.Notes != nil {
+		url.Notes = *req.Notes
+	}
+	if url.Title == "" && (url.ID == 0 || destinationChanged) {
+		url.MetadataStatus = entity.MetadataPending
+	}
+
+	if req.FolderID != nil {
+		url.FolderID = nil
+		if *req.FolderID != 0 {
+			if err := w.checkFolder(ctx, *req.FolderID, userID); err != nil {
+				if errors.Is(err, errFolderNotFound) {
+					return &invalidRequestError{message: err.Error()}
+				}
+				return err
+			}
+			url.FolderID = req.FolderID
+		}
+	}
+
+	if req.Preview != nil {
+		url.Preview = *req.Preview
+	}
+	if req.RedirectStatus != nil {
+		url.RedirectStatus = *req.RedirectStatus
+	}
+	if req.ForwardQuery != nil {
+		url.ForwardQuery = *req.ForwardQuery
+	}
+	if req.QueryConflict != nil {
+		url.QueryConflict = *req.QueryConflict
+	}
+	if req.ForwardPath != nil {
+		url.ForwardPath = *req.ForwardPath
+	}
+	if req.StickyVariants != nil {
+		url.StickyVariants = *req.StickyVariants
+	}
+
+	if req.Password != nil {
+		url.PasswordHash = ""
+		if *req.Password != "" {
+			passwordHash, err := auth.PasswordHash(*req.Password)
+			if err != nil {
+				return err
+			}
+			url.PasswordHash = passwordHash
+		}
+		url.Protected = url.PasswordHash != ""
+	}
+
+	// Imports go through the same checks, see URLTransferService.prepare.
+	if err := w.App.URLPolicy.NormalizeRules(url); err != nil {
+		log.Err(err).Str("short_url", url.ShortURL).Msg("url rules rejected")
+		return &invalidRequestError{message: err.Error()}
+	}
+
+	screening := w.App.Screening.ScreenAll(url.Destinations())
+	if screening.Verdict == entity.ScreeningReject {
+		log.Info().Strs("destinations", url.Destinations()).Strs("reasons", screening.Reasons).Msg("url rejected by screening")
+		return &invalidRequestError{message: "url is not allowed"}
+	}
+	url.Screening = screening.Verdict
+
+	return nil
+}
+
 func (w *WebApp) createURL(c echo.Context) error {
 	var createURLRequest URLRequest
 	if err := c.Bind(&createURLRequest); err != nil {
@@ -604,33 +967,61 @@ func (w *WebApp) createURL(c echo.Context) error {
 
 	user := c.Get("user").(*auth.Claims)
 
-	var newURL entity.URL
-	var err error
+	newURL := entity.URL{UserID: user.UserID}
+
+	if err := w.applyURLRequest(c.Request().Context(), createURLRequest, user.UserID, &newURL); err != nil {
+		var invalid *invalidRequestError
+		if errors.As(err, &invalid) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: invalid.message,
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create url",
+			Success: false,
+		})
+	}
+
+	allowed, remaining, err := w.App.Quota.ReserveLinks(c.Request().Context(), user.UserID, 1)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create url",
+			Success: false,
+		})
+	}
+	if !allowed {
+		return c.JSON(http.StatusTooManyRequests, ErrMessage{
+			Message: fmt.Sprintf("daily link quota exceeded, %d links left today", remaining),
+			Success: false,
+		})
+	}
 
 	for {
-		shortURL := utils.GenerateRandomString()
-		newURL = entity.URL{
-			ShortURL:    shortURL,
-			OriginalURL: createURLRequest.OriginalURL,
-			UserID:      user.UserID,
-		}
-		log.Info().Str("short_url", shortURL).Msg("new url generated")
-
-		if err = w.App.URLPostgres.CreateURL(c.Request().Context(), newURL); err != nil {
-			if isUniqueViolation(err) {
-				log.Info().Str("short_url", shortURL).Msg("duplicate short url, generating a new one")
-				continue
-			}
+		newURL.ShortURL = utils.GenerateRandomString()
+		log.Info().Str("short_url", newURL.ShortURL).Msg("new url generated")
 
+		urls := []entity.URL{newURL}
+		inserted, err := w.App.URLPostgres.CreateURLs(c.Request().Context(), urls)
+		if err != nil {
+			w.App.Quota.ReleaseLinks(c.Request().Context(), user.UserID, 1)
 			return c.JSON(http.StatusInternalServerError, ErrMessage{
 				Message: "failed to create url",
 				Success: false,
 			})
 		}
 
+		if !inserted[0] {
+			log.Info().Str("short_url", newURL.ShortURL).Msg("duplicate short url, generating a new one")
+			continue
+		}
+
+		newURL = urls[0]
 		break
 	}
 
+	w.App.URLHistory.Record(c.Request().Context(), service.Entry(entity.HistoryCreate, nil, newURL, user.UserID, requestID(c)))
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Message: "url created successfully",
 		Success: true,
@@ -640,6 +1031,88 @@ func (w *WebApp) createURL(c echo.Context) error {
 	})
 }
 
+func (w *WebApp) updateURL(c echo.Context) error {
+	var updateURLRequest URLRequest
+	if err := c.Bind(&updateURLRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(updateURLRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to update this url",
+			Success: false,
+		})
+	}
+
+	before := dbURL
+
+	if err := w.applyURLRequest(c.Request().Context(), updateURLRequest, user.UserID, &dbURL); err != nil {
+		var invalid *invalidRequestError
+		if errors.As(err, &invalid) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: invalid.message,
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update url",
+			Success: false,
+		})
+	}
+
+	if err := w.App.URLPostgres.UpdateURL(c.Request().Context(), dbURL); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update url",
+			Success: false,
+		})
+	}
+
+	w.App.URLRedis.DeleteFromCache(c.Request().Context(), shortURL)
+
+	entry := service.Entry(entity.HistoryUpdate, &before, dbURL, user.UserID, requestID(c))
+	if len(entry.Changes) > 0 {
+		w.App.URLHistory.Record(c.Request().Context(), entry)
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "url updated successfully",
+		Success: true,
+		Data: echo.Map{
+			"url": dbURL,
+		},
+	})
+}
+
 func (w *WebApp) deleteURL(c echo.Context) error {
 	shortURL := c.Param("shortURL")
 
@@ -673,8 +1146,20 @@ func (w *WebApp) deleteURL(c echo.Context) error {
 		})
 	}
 
+	deleted := dbURL
+	deletedAt := time.Now()
+	deleted.DeletedAt = &deletedAt
+	w.App.URLHistory.Record(c.Request().Context(), service.Entry(entity.HistoryDelete, &dbURL, deleted, user.UserID, requestID(c)))
+
+	if err := w.App.URLRedis.DeleteFromCache(c.Request().Context(), shortURL); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "url deleted but may still redirect for a while",
+			Success: false,
+		})
+	}
+
 	return c.JSON(http.StatusOK, ResponseOk{
-		Message: "url deleted successfully",
+		Message: "url moved to trash",
 		Success: true,
 	})
 }
@@ -705,10 +1190,79 @@ func (w *WebApp) getURL(c echo.Context) error {
 		})
 	}
 
+	urls := []entity.URL{dbURL}
+	if err := w.withTags(c.Request().Context(), urls); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Success: true,
 		Data: echo.Map{
-			"url": dbURL,
+			"url": urls[0],
+		},
+	})
+}
+
+func (w *WebApp) getURLStats(c echo.Context) error {
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to fetch this url",
+			Success: false,
+		})
+	}
+
+	return w.clickStats(c, entity.ClickFilter{URLID: dbURL.ID})
+}
+
+// clickStats responds with the clicks matching filter grouped by the
+// group_by query param, which defaults to campaign.
+func (w *WebApp) clickStats(c echo.Context, filter entity.ClickFilter) error {
+	groupBy := c.QueryParam("group_by")
+	if groupBy == "" {
+		groupBy = "campaign"
+	}
+
+	if !w.App.Click.IsDimension(groupBy) {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("can not group clicks by %s", groupBy),
+			Success: false,
+		})
+	}
+
+	groups, err := w.App.Click.GroupClicks(c.Request().Context(), filter, groupBy)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch stats",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"group_by": groupBy,
+			"groups":   groups,
 		},
 	})
 }
@@ -716,7 +1270,35 @@ func (w *WebApp) getURL(c echo.Context) error {
 func (w *WebApp) getAllURLs(c echo.Context) error {
 	user := c.Get("user").(*auth.Claims)
 
-	urls, err := w.App.URLPostgres.GetURLsByUserID(c.Request().Context(), user.UserID)
+	filter := entity.URLFilter{
+		UserID:     user.UserID,
+		Subfolders: c.QueryParam("subfolders") == "true",
+	}
+
+	if folderID := c.QueryParam("folder_id"); folderID != "" {
+		ID, err := strconv.Atoi(folderID)
+		if err != nil || ID < 1 {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "invalid folder id",
+				Success: false,
+			})
+		}
+		filter.FolderID = ID
+	}
+
+	for _, tagID := range c.QueryParams()["tag_id"] {
+		ID, err := strconv.Atoi(tagID)
+		if err != nil || ID < 1 {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "invalid tag id",
+				Success: false,
+			})
+		}
+		filter.TagIDs = append(filter.TagIDs, ID)
+	}
+	filter.TagIDs = uniqueIDs(filter.TagIDs)
+
+	urls, err := w.App.URLPostgres.GetURLsByFilter(c.Request().Context(), filter)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrMessage{
 			Message: "failed to fetch urls",
@@ -731,6 +1313,13 @@ func (w *WebApp) getAllURLs(c echo.Context) error {
 		})
 	}
 
+	if err := w.withTags(c.Request().Context(), urls); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch urls",
+			Success: false,
+		})
+	}
+
 	return c.JSON(http.StatusOK, ResponseOk{
 		Success: true,
 		Data: echo.Map{
@@ -742,13 +1331,15 @@ func (w *WebApp) getAllURLs(c echo.Context) error {
 func (w *WebApp) redirectURL(c echo.Context) error {
 	shortURL := c.Param("shortURL")
 
+	// A trailing "+" asks for the preview page instead of the redirect.
+	previewRequested := strings.HasSuffix(shortURL, "+")
+	shortURL = strings.TrimSuffix(shortURL, "+")
+
 	cacheURL, err := w.App.URLRedis.GetFromCacheByShortURL(c.Request().Context(), shortURL)
 	if err == nil {
 		log.Info().Str("short_url", shortURL).Msg("redirected from cache")
 
-		go w.App.URLPostgres.UpdateURLClickCount(context.Background(), shortURL)
-
-		return c.Redirect(http.StatusMovedPermanently, cacheURL.OriginalURL)
+		return w.serveRedirect(c, cacheURL, previewRequested)
 	}
 
 	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
@@ -767,9 +1358,253 @@ func (w *WebApp) redirectURL(c echo.Context) error {
 
 	w.App.URLRedis.SetURLToCache(c.Request().Context(), dbURL)
 
-	go w.App.URLPostgres.UpdateURLClickCount(context.Background(), shortURL)
-
 	log.Info().Str("short_url", shortURL).Msg("redirected from db")
 
-	return c.Redirect(http.StatusMovedPermanently, dbURL.OriginalURL)
+	return w.serveRedirect(c, dbURL, previewRequested)
+}
+
+func (w *WebApp) serveRedirect(c echo.Context, url entity.URL, previewRequested bool) error {
+	if url.Screening == entity.ScreeningReject {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "url is blocked",
+			Success: false,
+		})
+	}
+
+	now := time.Now()
+	if !url.IsActive(now) {
+		return c.JSON(http.StatusNotFound, ErrMessage{
+			Message: "url is not active yet",
+			Success: false,
+		})
+	}
+
+	pathSuffix := c.Param("*")
+	if pathSuffix != "" && !url.ForwardPath {
+		return c.JSON(http.StatusNotFound, ErrMessage{
+			Message: "url not found",
+			Success: false,
+		})
+	}
+
+	if url.PasswordHash != "" && !isUnlocked(c, url) {
+		return w.renderUnlock(c, url, http.StatusUnauthorized, "")
+	}
+
+	redirectRequest := service.RedirectRequest{
+		Query:      c.QueryParams(),
+		PathSuffix: pathSuffix,
+		UserAgent:  useragent.Parse(c.Request().UserAgent()),
+		Country:    w.App.GeoIP.Country(c.RealIP()),
+		Time:       now,
+	}
+	if url.StickyVariants {
+		redirectRequest.Variant = variantFromCookie(c, url.ShortURL)
+	}
+
+	destination, variant := w.App.Redirect.Destination(url, redirectRequest)
+
+	if previewRequested || url.Preview || url.ForcePreview || url.Screening == entity.ScreeningInterstitial || url.Screening == entity.ScreeningReview {
+		return w.renderPreview(c, url, destination)
+	}
+
+	// Only a redirect is a click, showing the preview page is not, and only
+	// the variant actually redirected to sticks.
+	if url.StickyVariants && variant != "" {
+		setVariantCookie(c, url.ShortURL, variant)
+	}
+	go w.recordClick(url, destination, variant, redirectRequest)
+
+	status := url.RedirectStatus
+	if status == 0 {
+		status = config.AppConfig.RedirectDefaultStatus
+	}
+
+	// Permanent redirects are cached by browsers, so bound how long they may
+	// keep them. Temporary ones, and links routing clients to different
+	// destinations, must reach us on every click.
+	switch {
+	case (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) && !url.HasRouting() && !url.Protected:
+		c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(config.AppConfig.RedirectPermanentMaxAge.Seconds())))
+	default:
+		c.Response().Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	}
+
+	return c.Redirect(status, destination)
+}
+
+func (w *WebApp) recordClick(url entity.URL, destination, variant string, req service.RedirectRequest) {
+	ctx := context.Background()
+
+	w.App.URLPostgres.UpdateURLClickCount(ctx, url.ShortURL)
+
+	click := entity.Click{
+		URLID:   url.ID,
+		OS:      req.UserAgent.OS,
+		Device:  req.UserAgent.Device,
+		Bot:     req.UserAgent.Bot,
+		Country: req.Country,
+		Variant: variant,
+	}
+	if u, err := neturl.Parse(destination); err == nil {
+		query := u.Query()
+		click.UTMSource = query.Get("utm_source")
+		click.UTMMedium = query.Get("utm_medium")
+		click.UTMCampaign = query.Get("utm_campaign")
+	}
+
+	w.App.Click.RecordClick(ctx, click)
+}
+
+func (w *WebApp) renderPreview(c echo.Context, url entity.URL, destination string) error {
+	var host string
+	if u, err := neturl.Parse(destination); err == nil {
+		host = u.Hostname()
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	return c.Render(http.StatusOK, "preview.html", echo.Map{
+		"ShortURL":    fmt.Sprintf("%s/%s", w.appURL, url.ShortURL),
+		"Destination": destination,
+		"Host":        host,
+		"CreatedAt":   url.CreatedAt,
+		"Warning":     url.ForcePreview || url.Screening != entity.ScreeningAllow,
+	})
+}
+
+func (w *WebApp) getURLQR(c echo.Context) error {
+	qrRequest := QRRequest{
+		Format:     "png",
+		Size:       256,
+		Level:      "M",
+		Margin:     4,
+		Foreground: "000000",
+		Background: "ffffff",
+	}
+	if err := c.Bind(&qrRequest); err != nil {
+		log.Err(err).Msg("failed to bind request query")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request query",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(&qrRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to fetch this url",
+			Success: false,
+		})
+	}
+
+	image, contentType, err := w.App.QR.Render(c.Request().Context(), dbURL.ShortURL, service.QROptions{
+		Format:     qrRequest.Format,
+		Size:       qrRequest.Size,
+		Level:      qrRequest.Level,
+		Margin:     qrRequest.Margin,
+		Foreground: qrRequest.Foreground,
+		Background: qrRequest.Background,
+	})
+	if err != nil {
+		log.Err(err).Str("short_url", shortURL).Msg("failed to render qr code")
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to render qr code",
+			Success: false,
+		})
+	}
+
+	c.Response().Header().Set("Cache-Control", "private, max-age=86400")
+
+	return c.Blob(http.StatusOK, contentType, image)
+}
+
+func (w *WebApp) getFlaggedURLs(c echo.Context) error {
+	urls, err := w.App.URLPostgres.GetFlaggedURLs(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch urls",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"urls": urls,
+		},
+	})
+}
+
+func (w *WebApp) forcePreview(c echo.Context) error {
+	var forcePreviewRequest ForcePreviewRequest
+	if err := c.Bind(&forcePreviewRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	if err := w.App.URLPostgres.UpdateURLForcePreview(c.Request().Context(), shortURL, forcePreviewRequest.ForcePreview); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update url",
+			Success: false,
+		})
+	}
+
+	w.App.URLRedis.DeleteFromCache(c.Request().Context(), shortURL)
+
+	moderated := dbURL
+	moderated.ForcePreview = forcePreviewRequest.ForcePreview
+	moderator := c.Get("user").(*auth.Claims)
+	w.App.URLHistory.Record(c.Request().Context(), service.Entry(entity.HistoryModerate, &dbURL, moderated, moderator.UserID, requestID(c)))
+	w.audit(c, entity.AuditModerationForcePreview, dbURL.UserID, echo.Map{"short_url": shortURL, "force_preview": forcePreviewRequest.ForcePreview})
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "url updated successfully",
+		Success: true,
+	})
 }
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// urlRepo keeps links in memory, only what updating a link uses is
+// implemented.
+type urlRepo struct {
+	repository.URL
+	urls map[string]entity.URL
+}
+
+func (r *urlRepo) ByShortURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	url, ok := r.urls[shortURL]
+	if !ok {
+		return entity.URL{}, pgx.ErrNoRows
+	}
+	return url, nil
+}
+
+func (r *urlRepo) Update(ctx context.Context, url entity.URL) error {
+	r.urls[url.ShortURL] = url
+	return nil
+}
+
+type urlCache struct {
+	repository.URLRedis
+}
+
+func (urlCache) Delete(ctx context.Context, shortURL string) error {
+	return nil
+}
+
+type historyRepo struct {
+	repository.URLHistory
+	entries []entity.URLHistory
+}
+
+func (h *historyRepo) Save(ctx context.Context, entries []entity.URLHistory) error {
+	h.entries = append(h.entries, entries...)
+	return nil
+}
+
+func newURLTestApp(urls ...entity.URL) (*WebApp, *urlRepo) {
+	repo := &urlRepo{urls: map[string]entity.URL{}}
+	for _, url := range urls {
+		repo.urls[url.ShortURL] = url
+	}
+
+	w := &WebApp{
+		e: echo.New(),
+		App: &service.App{
+			URLPostgres: service.NewURLPostgresService(repo),
+			URLRedis:    service.NewURLRedisService(urlCache{}),
+			URLPolicy:   service.NewURLPolicyService("https://kuchak.test", []string{"http", "https"}, 2048, nil, nil, nil),
+			Screening:   service.NewScreeningService(repo, urlCache{}),
+			URLHistory:  service.NewURLHistoryService(&historyRepo{}),
+		},
+	}
+	w.routes()
+
+	return w, repo
+}
+
+func patchURL(t *testing.T, w *WebApp, shortURL, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPatch, "/urls/update/"+shortURL, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	c := w.e.NewContext(req, rec)
+	c.SetParamNames("shortURL")
+	c.SetParamValues(shortURL)
+	c.Set("user", &auth.Claims{UserID: 1})
+
+	if err := w.updateURL(c); err != nil {
+		t.Fatalf("updateURL: %v", err)
+	}
+	return rec
+}
+
+// routedURL is a link using every routing option.
+func routedURL() entity.URL {
+	bot := false
+	activeFrom := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := activeFrom.Add(24 * time.Hour)
+
+	return entity.URL{
+		ID:             7,
+		ShortURL:       "routed",
+		OriginalURL:    "https://example.com/",
+		Title:          "Old title",
+		Description:    "Kept",
+		MetadataStatus: entity.MetadataDone,
+		UserID:         1,
+		Screening:      entity.ScreeningAllow,
+		Preview:        true,
+		RedirectStatus: http.StatusFound,
+		ForwardQuery:   true,
+		QueryConflict:  "append",
+		ForwardPath:    true,
+		UTM:            &entity.UTM{Source: "newsletter", Medium: "email"},
+		DeviceRules:    []entity.DeviceRule{{OS: "ios", Bot: &bot, Destination: "https://apps.example.com/"}},
+		GeoRules:       []entity.GeoRule{{Countries: []string{"DE", "AT"}, Destination: "https://example.de/"}},
+		Variants: []entity.Variant{
+			{Name: "a", Destination: "https://a.example.com/", Weight: 1},
+			{Name: "b", Destination: "https://b.example.com/", Weight: 3},
+		},
+		StickyVariants: true,
+		ActiveFrom:     &activeFrom,
+		Schedule:       []entity.ScheduleRule{{From: &activeFrom, Until: &until, Destination: "https://sale.example.com/"}},
+	}
+}
+
+func TestUpdateURLKeepsFieldsLeftOut(t *testing.T) {
+	original := routedURL()
+	w, repo := newURLTestApp(original)
+
+	rec := patchURL(t, w, "routed", `{"title": "New title"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	want := original
+	want.Title = "New title"
+	if got := repo.urls["routed"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("after a title-only PATCH the link is\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestUpdateURLClearsFields(t *testing.T) {
+	original := routedURL()
+	w, repo := newURLTestApp(original)
+
+	body := `{
+		"redirect_status": 0,
+		"query_conflict": "",
+		"forward_path": false,
+		"utm_template_id": 0,
+		"device_rules": [],
+		"variants": [],
+		"active_from": ""
+	}`
+	rec := patchURL(t, w, "routed", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	want := original
+	want.RedirectStatus = 0
+	want.QueryConflict = ""
+	want.ForwardPath = false
+	want.UTM = nil
+	want.DeviceRules = nil
+	want.Variants = nil
+	want.ActiveFrom = nil
+	if got := repo.urls["routed"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("after clearing the link is\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestUpdateURLRejectsInvalidFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "redirect status", body: `{"redirect_status": 303}`},
+		{name: "query conflict", body: `{"query_conflict": "merge"}`},
+		{name: "duplicate variants", body: `{"variants": [{"name": "a", "destination": "https://a.example.com/"}, {"name": "a", "destination": "https://b.example.com/"}]}`},
+		{name: "country code", body: `{"geo_rules": [{"countries": ["XX"], "destination": "https://example.de/"}]}`},
+		{name: "schedule order", body: `{"schedule": [{"from": "2026-02-01T00:00:00Z", "until": "2026-01-01T00:00:00Z", "destination": "https://sale.example.com/"}]}`},
+		{name: "active from", body: `{"active_from": "tomorrow"}`},
+		{name: "destination", body: `{"original_url": "ftp://example.com/"}`},
+	}
+
+	for _, tt := range tests {
+		original := routedURL()
+		w, repo := newURLTestApp(original)
+
+		rec := patchURL(t, w, "routed", tt.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: PATCH status = %d, want %d", tt.name, rec.Code, http.StatusBadRequest)
+		}
+		if got := repo.urls["routed"]; !reflect.DeepEqual(got, original) {
+			t.Errorf("%s: rejected PATCH changed the link", tt.name)
+		}
+	}
+}
+
+func TestCreateURLRequiresDestination(t *testing.T) {
+	w, _ := newURLTestApp()
+
+	req := httptest.NewRequest(http.MethodPost, "/urls/create", strings.NewReader(`{"title": "No destination"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := w.e.NewContext(req, rec)
+	c.Set("user", &auth.Claims{UserID: 1})
+
+	if err := w.createURL(c); err != nil {
+		t.Fatalf("createURL: %v", err)
+	}
+
+	var resp ErrMessage
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusBadRequest || resp.Message != "original_url is required" {
+		t.Errorf("create without a destination = %d %q, want 400", rec.Code, resp.Message)
+	}
+}
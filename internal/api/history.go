@@ -0,0 +1,231 @@
+package api
+
+import (
+	"errors"
+	"kuchak/internal/entity"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// snapshotRequest turns a history snapshot back into the request that would
+// set a link up that way. The password is left out so reverting never
+// changes who can open the link.
+func snapshotRequest(snapshot entity.URL) URLRequest {
+	activeFrom := ""
+	if snapshot.ActiveFrom != nil {
+		activeFrom = snapshot.ActiveFrom.Format(time.RFC3339Nano)
+	}
+
+	deviceRules := []DeviceRuleRequest{}
+	for _, rule := range snapshot.DeviceRules {
+		deviceRules = append(deviceRules, DeviceRuleRequest{
+			OS:          rule.OS,
+			Device:      rule.Device,
+			Bot:         rule.Bot,
+			Destination: rule.Destination,
+		})
+	}
+	geoRules := []GeoRuleRequest{}
+	for _, rule := range snapshot.GeoRules {
+		geoRules = append(geoRules, GeoRuleRequest{
+			Countries:   rule.Countries,
+			Destination: rule.Destination,
+		})
+	}
+	variants := []VariantRequest{}
+	for _, variant := range snapshot.Variants {
+		variants = append(variants, VariantRequest{
+			Name:        variant.Name,
+			Destination: variant.Destination,
+			Weight:      variant.Weight,
+		})
+	}
+	schedule := []ScheduleRuleRequest{}
+	for _, rule := range snapshot.Schedule {
+		schedule = append(schedule, ScheduleRuleRequest{
+			From:        rule.From,
+			Until:       rule.Until,
+			Destination: rule.Destination,
+		})
+	}
+
+	// Every field is set, a revert restores the whole link and not only
+	// what the snapshot happens to have.
+	req := URLRequest{
+		OriginalURL:    &snapshot.OriginalURL,
+		Preview:        &snapshot.Preview,
+		RedirectStatus: &snapshot.RedirectStatus,
+		ForwardQuery:   &snapshot.ForwardQuery,
+		QueryConflict:  &snapshot.QueryConflict,
+		ForwardPath:    &snapshot.ForwardPath,
+		DeviceRules:    &deviceRules,
+		GeoRules:       &geoRules,
+		Variants:       &variants,
+		StickyVariants: &snapshot.StickyVariants,
+		ActiveFrom:     &activeFrom,
+		Schedule:       &schedule,
+		Title:          &snapshot.Title,
+		Description:    &snapshot.Description,
+		Notes:          &snapshot.Notes,
+	}
+
+	folderID := 0
+	if snapshot.FolderID != nil {
+		folderID = *snapshot.FolderID
+	}
+	req.FolderID = &folderID
+
+	return req
+}
+
+func (w *WebApp) getURLHistory(c echo.Context) error {
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), c.Param("shortURL"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to this url",
+			Success: false,
+		})
+	}
+
+	history, err := w.App.URLHistory.GetHistory(c.Request().Context(), dbURL.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url history",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"history": history,
+		},
+	})
+}
+
+// revertURL puts a link back the way it was at a version of its history.
+// The password and tags stay as they are, and a folder deleted since then
+// puts the link at the top level.
+func (w *WebApp) revertURL(c echo.Context) error {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid version",
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to update this url",
+			Success: false,
+		})
+	}
+
+	entry, err := w.App.URLHistory.GetVersion(c.Request().Context(), dbURL.ID, version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "version not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url history",
+			Success: false,
+		})
+	}
+
+	req := snapshotRequest(entry.Snapshot)
+	if *req.FolderID != 0 {
+		if err := w.checkFolder(c.Request().Context(), *req.FolderID, user.UserID); err != nil {
+			if !errors.Is(err, errFolderNotFound) {
+				return c.JSON(http.StatusInternalServerError, ErrMessage{
+					Message: "failed to revert url",
+					Success: false,
+				})
+			}
+			*req.FolderID = 0
+		}
+	}
+
+	before := dbURL
+
+	// Destinations go through the policy and screening again, a version
+	// that was fine back then may not be allowed anymore.
+	if err := w.applyURLRequest(c.Request().Context(), req, user.UserID, &dbURL); err != nil {
+		var invalid *invalidRequestError
+		if errors.As(err, &invalid) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: invalid.message,
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to revert url",
+			Success: false,
+		})
+	}
+	dbURL.UTM = entry.Snapshot.UTM
+
+	if err := w.App.URLPostgres.UpdateURL(c.Request().Context(), dbURL); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to revert url",
+			Success: false,
+		})
+	}
+
+	w.App.URLRedis.DeleteFromCache(c.Request().Context(), shortURL)
+
+	w.App.URLHistory.Record(c.Request().Context(), service.Entry(entity.HistoryRevert, &before, dbURL, user.UserID, requestID(c)))
+
+	log.Info().Str("short_url", shortURL).Int("version", version).Msg("url reverted")
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "url reverted successfully",
+		Success: true,
+		Data: echo.Map{
+			"url": dbURL,
+		},
+	})
+}
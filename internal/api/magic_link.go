@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/config"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/rueidis"
+	"github.com/rs/zerolog/log"
+)
+
+// requestMagicLink emails a single use sign in link.
+func (w *WebApp) requestMagicLink(c echo.Context) error {
+	var emailRequest EmailRequest
+	if err := c.Bind(&emailRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(emailRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	allowed, err := w.App.RateLimit.IsMagicLinkAllowed(c.Request().Context(), emailRequest.Email, c.RealIP(),
+		config.AppConfig.MagicLinkLimitPerEmail, config.AppConfig.MagicLinkLimitPerIP, config.AppConfig.MagicLinkLimitWindow)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to send login link",
+			Success: false,
+		})
+	}
+
+	if !allowed {
+		return c.JSON(http.StatusTooManyRequests, ErrMessage{
+			Message: "too many login links requested, please try again later",
+			Success: false,
+		})
+	}
+
+	dbUser, err := w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), emailRequest.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
+	}
+
+	token, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate login token",
+			Success: false,
+		})
+	}
+
+	if err := w.App.AccountRedis.SetMagicLink(c.Request().Context(), dbUser.Email, token, config.AppConfig.MagicLinkTTL); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to send login link",
+			Success: false,
+		})
+	}
+
+	magicLinkURL := fmt.Sprintf("%s/auth/magicLogin/%s", w.appURL, token)
+
+	if err := w.App.EmailSender.SendMagicLinkEmail(dbUser.Email, magicLinkURL, humanDuration(config.AppConfig.MagicLinkTTL)); err != nil {
+		log.Err(err).Int("user_id", dbUser.ID).Msg("failed to send magic link")
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to send login link",
+			Success: false,
+		})
+	}
+
+	w.audit(c, entity.AuditMagicLinkRequested, dbUser.ID, nil)
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "login link successfully sent",
+		Success: true,
+	})
+}
+
+// magicLogin signs in with a token from a login link. The token goes in the
+// body rather than the url so link scanners opening the email can not use
+// it up. Opening the link proves the email is the user's, so it also
+// verifies it.
+func (w *WebApp) magicLogin(c echo.Context) error {
+	var tokenRequest TokenRequest
+	if err := c.Bind(&tokenRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(tokenRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	email, err := w.App.AccountRedis.GetByMagicLinkToken(c.Request().Context(), tokenRequest.Token)
+	if err != nil {
+		if errors.Is(err, rueidis.Nil) {
+			w.audit(c, entity.AuditLoginFailed, 0, echo.Map{"method": "magic_link", "reason": "invalid token"})
+			return c.JSON(http.StatusUnauthorized, ErrMessage{
+				Message: "login token is not valid or expired",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to check login token",
+			Success: false,
+		})
+	}
+
+	dbUser, err := w.App.AccountPostgres.GetUserByEmail(c.Request().Context(), email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "user not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch user",
+			Success: false,
+		})
+	}
+
+	if !dbUser.IsEmailVerified {
+		dbUser.IsEmailVerified = true
+		if err := w.App.AccountPostgres.UpdateUserVerifyEmail(c.Request().Context(), dbUser); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrMessage{
+				Message: "failed to verify email",
+				Success: false,
+			})
+		}
+		w.audit(c, entity.AuditEmailVerified, dbUser.ID, echo.Map{"method": "magic_link"})
+	}
+
+	accessToken, err := w.App.Tokens.GenerateToken(dbUser, auth.AccessToken, auth.AccessTokenExp)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate access token",
+			Success: false,
+		})
+	}
+
+	refreshToken, err := w.App.Tokens.GenerateToken(dbUser, auth.RefreshToken, auth.RefreshTokenExp)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate refresh token",
+			Success: false,
+		})
+	}
+
+	w.audit(c, entity.AuditLoginSucceeded, dbUser.ID, echo.Map{"method": "magic_link"})
+
+	return c.JSON(http.StatusOK, AuthTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
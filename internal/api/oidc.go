@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"kuchak/internal/entity"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"kuchak/pkg/oidc"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+func (w *WebApp) getOIDCProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"providers": w.App.OIDC.Providers(),
+		},
+	})
+}
+
+// oidcStateCookie ties a sign in attempt to the browser that started it,
+// otherwise anyone could get a victim signed in to the attacker's account
+// by sending them the attacker's own callback url.
+const oidcStateCookie = "oidc_state"
+
+// oidcStateHash is what the state cookie holds.
+func oidcStateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// setOIDCStateCookie scopes the cookie to the provider's login and callback
+// routes, a zero expiry clears it.
+func setOIDCStateCookie(c echo.Context, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     path.Dir(c.Request().URL.Path),
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if expires.IsZero() {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expires
+	}
+	c.SetCookie(cookie)
+}
+
+// oidcLogin sends the user to the provider to sign in.
+func (w *WebApp) oidcLogin(c echo.Context) error {
+	authURL, state, err := w.App.OIDC.AuthURL(c.Request().Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		}
+		log.Err(err).Str("provider", c.Param("provider")).Msg("failed to start oidc sign in")
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to start sign in",
+			Success: false,
+		})
+	}
+
+	setOIDCStateCookie(c, oidcStateHash(state), time.Now().Add(service.OIDCStateTTL))
+
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCallback is where the provider sends the user back to, it finishes
+// the sign in and responds with the usual token pair.
+func (w *WebApp) oidcCallback(c echo.Context) error {
+	provider := c.Param("provider")
+	state := c.QueryParam("state")
+
+	cookie, cookieErr := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", time.Time{})
+
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		w.audit(c, entity.AuditLoginFailed, 0, echo.Map{"method": "oidc", "provider": provider, "reason": providerErr})
+		return c.JSON(http.StatusUnauthorized, ErrMessage{
+			Message: "sign in failed at the provider: " + providerErr,
+			Success: false,
+		})
+	}
+
+	if cookieErr != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(oidcStateHash(state))) != 1 {
+		w.audit(c, entity.AuditLoginFailed, 0, echo.Map{"method": "oidc", "provider": provider, "reason": "state cookie does not match"})
+		return c.JSON(http.StatusUnauthorized, ErrMessage{
+			Message: service.ErrInvalidState.Error(),
+			Success: false,
+		})
+	}
+
+	signIn, err := w.App.OIDC.SignIn(c.Request().Context(), provider, state, c.QueryParam("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownProvider):
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		case errors.Is(err, service.ErrInvalidState), errors.Is(err, oidc.ErrInvalidToken):
+			log.Err(err).Str("provider", provider).Msg("oidc sign in rejected")
+			w.audit(c, entity.AuditLoginFailed, 0, echo.Map{"method": "oidc", "provider": provider, "reason": err.Error()})
+			return c.JSON(http.StatusUnauthorized, ErrMessage{
+				Message: service.ErrInvalidState.Error(),
+				Success: false,
+			})
+		case errors.Is(err, service.ErrEmailNotVerified), errors.Is(err, service.ErrNoAccount):
+			w.audit(c, entity.AuditLoginFailed, 0, echo.Map{"method": "oidc", "provider": provider, "reason": err.Error()})
+			return c.JSON(http.StatusForbidden, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		}
+		log.Err(err).Str("provider", provider).Msg("failed to finish oidc sign in")
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to sign in",
+			Success: false,
+		})
+	}
+
+	accessToken, err := w.App.Tokens.GenerateToken(signIn.User, auth.AccessToken, auth.AccessTokenExp)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate access token",
+			Success: false,
+		})
+	}
+
+	refreshToken, err := w.App.Tokens.GenerateToken(signIn.User, auth.RefreshToken, auth.RefreshTokenExp)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to generate refresh token",
+			Success: false,
+		})
+	}
+
+	if signIn.Created {
+		w.audit(c, entity.AuditRegister, signIn.User.ID, echo.Map{"method": "oidc", "provider": provider})
+	}
+	if signIn.Linked {
+		w.audit(c, entity.AuditIdentityLinked, signIn.User.ID, echo.Map{"provider": provider})
+	}
+	w.audit(c, entity.AuditLoginSucceeded, signIn.User.ID, echo.Map{"method": "oidc", "provider": provider})
+
+	return c.JSON(http.StatusOK, AuthTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/labstack/echo/v4"
+)
+
+type templateRenderer struct {
+	templates *template.Template
+}
+
+func newTemplateRenderer(pattern string) *templateRenderer {
+	return &templateRenderer{
+		templates: template.Must(template.ParseGlob(pattern)),
+	}
+}
+
+func (t *templateRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	return t.templates.ExecuteTemplate(w, name, data)
+}
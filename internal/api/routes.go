@@ -1,7 +1,7 @@
 package api
 
 import (
-	"kuchak/internal/config"
+	"kuchak/internal/entity"
 	"kuchak/pkg/auth"
 	"kuchak/pkg/validate"
 	"net/http"
@@ -20,6 +20,8 @@ func (w *WebApp) routes() {
 
 	w.e.Validator = &validate.CustomValidator{Validator: v}
 
+	w.e.Use(middleware.RequestID())
+
 	w.e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{
@@ -43,9 +45,16 @@ func (w *WebApp) routes() {
 	a.POST("/login", w.login)
 	a.POST("/register", w.register)
 	a.POST("/refresh", w.refreshToken)
+	a.POST("/requestMagicLink", w.requestMagicLink)
+	a.POST("/magicLogin", w.magicLogin)
+	a.GET("/oidc", w.getOIDCProviders)
+	a.GET("/oidc/:provider/login", w.oidcLogin)
+	a.GET("/oidc/:provider/callback", w.oidcCallback)
 	a.POST("/requestResetPassword", w.requestResetPassword)
 	a.POST("/resetPassword", w.resetPassword)
 	a.PATCH("/updateEmail", w.updateEmail, w.withAuth())
+	a.GET("/confirmEmail/:token", w.confirmEmail)
+	a.GET("/confirmDelete/:token", w.confirmAccountDeletion)
 	a.PATCH("/updatePassword", w.updatePassword, w.withAuth())
 	a.POST("/requestVerifyEmail", w.requestVerifyEmail)
 	a.GET("/verifyEmail/:token", w.verifyEmail)
@@ -56,13 +65,83 @@ func (w *WebApp) routes() {
 	u.GET("/get/:shortURL", w.getURL)
 	u.GET("/getAll", w.getAllURLs)
 	u.POST("/create", w.createURL)
+	u.POST("/bulk", w.createURLs)
+	u.GET("/export", w.exportURLs)
+	u.POST("/import", w.importURLs)
+	u.PATCH("/update/:shortURL", w.updateURL)
 	u.DELETE("/delete/:shortURL", w.deleteURL)
+	u.GET("/trash", w.getTrash)
+	u.POST("/restore/:shortURL", w.restoreURL)
+	u.DELETE("/purge/:shortURL", w.purgeURL)
+	u.GET("/stats/:shortURL", w.getURLStats)
+	u.GET("/variants/:shortURL", w.getVariantStats)
+	u.GET("/:shortURL/qr", w.getURLQR)
+	u.GET("/:shortURL/history", w.getURLHistory)
+	u.POST("/:shortURL/history/:version/revert", w.revertURL)
+	u.PUT("/tags/:shortURL", w.setURLTags)
+
+	t := w.e.Group("/utm")
+	t.Use(w.rateLimit(100, time.Hour*2))
+	t.Use(w.withAuth())
+	t.GET("/getAll", w.getAllUTMTemplates)
+	t.POST("/create", w.createUTMTemplate)
+	t.PATCH("/update/:id", w.updateUTMTemplate)
+	t.DELETE("/delete/:id", w.deleteUTMTemplate)
+	t.GET("/stats", w.getUTMStats)
+
+	g := w.e.Group("/tags")
+	g.Use(w.rateLimit(100, time.Hour*2))
+	g.Use(w.withAuth())
+	g.GET("/getAll", w.getAllTags)
+	g.POST("/create", w.createTag)
+	g.PATCH("/update/:id", w.updateTag)
+	g.DELETE("/delete/:id", w.deleteTag)
+	g.POST("/retag", w.retagURLs)
+	g.GET("/stats", w.getTagStats)
+	g.GET("/stats/:id", w.getTagClickStats)
+
+	f := w.e.Group("/folders")
+	f.Use(w.rateLimit(100, time.Hour*2))
+	f.Use(w.withAuth())
+	f.GET("/getAll", w.getAllFolders)
+	f.POST("/create", w.createFolder)
+	f.PATCH("/update/:id", w.updateFolder)
+	f.DELETE("/delete/:id", w.deleteFolder)
+
+	ac := w.e.Group("/account")
+	ac.Use(w.rateLimit(100, time.Hour*2))
+	ac.Use(w.withAuth())
+	ac.GET("/audit", w.getAccountAudit)
+	ac.GET("/export", w.exportAccount)
+	ac.POST("/delete", w.requestAccountDeletion)
+	ac.POST("/cancelDelete", w.cancelAccountDeletion)
+
+	ad := w.e.Group("/admin")
+	ad.Use(w.withAuth())
+	ad.Use(w.withRole(entity.RoleAdmin))
+	ad.GET("/audit", w.getAuditEvents)
+
+	m := w.e.Group("/moderation")
+	m.Use(w.withAuth())
+	m.Use(w.withRole(entity.RoleModerator, entity.RoleAdmin))
+	m.GET("/flagged", w.getFlaggedURLs)
+	m.PATCH("/forcePreview/:shortURL", w.forcePreview)
+
+	w.e.POST("/convert/:shortURL", w.recordConversion, w.rateLimit(100, time.Hour*2))
 
 	w.e.GET("/healthz", w.healthz)
+	w.e.GET("/.well-known/jwks.json", w.jwks)
 	w.e.GET("/favicon.ico", func(c echo.Context) error {
 		return c.NoContent(http.StatusNotFound)
 	})
 	w.e.GET("/:shortURL", w.redirectURL)
+	w.e.GET("/:shortURL/*", w.redirectURL)
+	w.e.POST("/:shortURL/unlock", w.unlockURL)
+}
+
+// requestID returns the id the RequestID middleware gave the request.
+func requestID(c echo.Context) string {
+	return c.Response().Header().Get(echo.HeaderXRequestID)
 }
 
 func (w *WebApp) withAuth() echo.MiddlewareFunc {
@@ -75,17 +154,46 @@ func (w *WebApp) withAuth() echo.MiddlewareFunc {
 
 			tokenStr := authHeader[len("Bearer "):]
 
-			claims, err := auth.ValidateToken(tokenStr, config.AppConfig.AccessTokenSecret)
+			claims, err := w.App.Tokens.ValidateToken(tokenStr, auth.AccessToken)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
 			}
 
+			revoked, err := w.App.AccountRedis.IsTokenRevoked(c.Request().Context(), claims)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to check token")
+			}
+			if revoked {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+			}
+
 			c.Set("user", claims)
 			return next(c)
 		}
 	}
 }
 
+func (w *WebApp) withRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := c.Get("user").(*auth.Claims)
+
+			dbUser, err := w.App.AccountPostgres.GetUserByID(c.Request().Context(), claims.UserID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+			}
+
+			for _, role := range roles {
+				if dbUser.Role == role {
+					return next(c)
+				}
+			}
+
+			return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+		}
+	}
+}
+
 func (w *WebApp) rateLimit(limit int, window time.Duration) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"kuchak/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestRoutesAreReserved makes sure no short url can be claimed under a path
+// the app routes itself, those would never redirect.
+func TestRoutesAreReserved(t *testing.T) {
+	w := &WebApp{e: echo.New(), App: &service.App{}}
+	w.routes()
+
+	for _, route := range w.e.Routes() {
+		first, _, _ := strings.Cut(strings.TrimPrefix(route.Path, "/"), "/")
+		if first == "" || strings.HasPrefix(first, ":") || first == "*" {
+			continue
+		}
+		if !service.IsReservedAlias(first) {
+			t.Errorf("%s %s: %q can be taken as a short url", route.Method, route.Path, first)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/config"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var errTagNotFound = errors.New("tag not found")
+
+// checkTags makes sure every one of tagIDs belongs to the user.
+func (w *WebApp) checkTags(ctx context.Context, tagIDs []int, userID int) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+
+	tags, err := w.App.Tag.GetTagsByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	owned := make(map[int]bool, len(tags))
+	for _, tag := range tags {
+		owned[tag.ID] = true
+	}
+
+	for _, ID := range tagIDs {
+		if !owned[ID] {
+			return errTagNotFound
+		}
+	}
+
+	return nil
+}
+
+// withTags fills in the tags of urls.
+func (w *WebApp) withTags(ctx context.Context, urls []entity.URL) error {
+	IDs := make([]int, len(urls))
+	for i, url := range urls {
+		IDs[i] = url.ID
+	}
+
+	tags, err := w.App.Tag.GetTagsByURLIDs(ctx, IDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range urls {
+		urls[i].Tags = tags[urls[i].ID]
+	}
+
+	return nil
+}
+
+// uniqueIDs returns ids sorted and without duplicates.
+func uniqueIDs(ids []int) []int {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+func (w *WebApp) getAllTags(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	tags, err := w.App.Tag.GetTagsByUserID(c.Request().Context(), user.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch tags",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"tags": tags,
+		},
+	})
+}
+
+func (w *WebApp) createTag(c echo.Context) error {
+	var tagRequest TagRequest
+	if err := c.Bind(&tagRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(tagRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	tag, err := w.App.Tag.CreateTag(c.Request().Context(), entity.Tag{
+		UserID: user.UserID,
+		Name:   tagRequest.Name,
+		Color:  tagRequest.Color,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "tag already exists",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create tag",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "tag created successfully",
+		Success: true,
+		Data: echo.Map{
+			"tag": tag,
+		},
+	})
+}
+
+func (w *WebApp) updateTag(c echo.Context) error {
+	var tagRequest TagRequest
+	if err := c.Bind(&tagRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(tagRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid tag id",
+			Success: false,
+		})
+	}
+
+	tag, err := w.App.Tag.GetTagByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "tag not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch tag",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if tag.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to update this tag",
+			Success: false,
+		})
+	}
+
+	tag.Name = tagRequest.Name
+	tag.Color = tagRequest.Color
+
+	if err := w.App.Tag.UpdateTag(c.Request().Context(), tag); err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "tag already exists",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update tag",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "tag updated successfully",
+		Success: true,
+		Data: echo.Map{
+			"tag": tag,
+		},
+	})
+}
+
+func (w *WebApp) deleteTag(c echo.Context) error {
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid tag id",
+			Success: false,
+		})
+	}
+
+	tag, err := w.App.Tag.GetTagByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "tag not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch tag",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if tag.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to delete this tag",
+			Success: false,
+		})
+	}
+
+	if err := w.App.Tag.DeleteTag(c.Request().Context(), tag); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to delete tag",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "tag deleted successfully",
+		Success: true,
+	})
+}
+
+func (w *WebApp) setURLTags(c echo.Context) error {
+	var tagsRequest URLTagsRequest
+	if err := c.Bind(&tagsRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(tagsRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to update this url",
+			Success: false,
+		})
+	}
+
+	tagIDs := uniqueIDs(tagsRequest.TagIDs)
+
+	if err := w.checkTags(c.Request().Context(), tagIDs, user.UserID); err != nil {
+		if errors.Is(err, errTagNotFound) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update url tags",
+			Success: false,
+		})
+	}
+
+	if err := w.App.Tag.SetURLTags(c.Request().Context(), dbURL.ID, tagIDs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update url tags",
+			Success: false,
+		})
+	}
+
+	urls := []entity.URL{dbURL}
+	if err := w.withTags(c.Request().Context(), urls); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url tags",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "url tags updated successfully",
+		Success: true,
+		Data: echo.Map{
+			"url": urls[0],
+		},
+	})
+}
+
+func (w *WebApp) retagURLs(c echo.Context) error {
+	var retagRequest RetagRequest
+	if err := c.Bind(&retagRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(retagRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	if len(retagRequest.ShortURLs) > config.AppConfig.BulkMaxURLs {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: at most %d urls are allowed", config.AppConfig.BulkMaxURLs),
+			Success: false,
+		})
+	}
+
+	if len(retagRequest.Add) == 0 && len(retagRequest.Remove) == 0 {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "failed to validate payload: nothing to add or remove",
+			Success: false,
+		})
+	}
+
+	add, remove := uniqueIDs(retagRequest.Add), uniqueIDs(retagRequest.Remove)
+	for _, ID := range add {
+		if slices.Contains(remove, ID) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: "a tag can not be added and removed at once",
+				Success: false,
+			})
+		}
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if err := w.checkTags(c.Request().Context(), append(slices.Clone(add), remove...), user.UserID); err != nil {
+		if errors.Is(err, errTagNotFound) {
+			return c.JSON(http.StatusBadRequest, ErrMessage{
+				Message: err.Error(),
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to retag urls",
+			Success: false,
+		})
+	}
+
+	updated, err := w.App.Tag.RetagURLs(c.Request().Context(), user.UserID, retagRequest.ShortURLs, add, remove)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to retag urls",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: fmt.Sprintf("%d urls retagged", updated),
+		Success: true,
+		Data: echo.Map{
+			"updated": updated,
+		},
+	})
+}
+
+func (w *WebApp) getTagStats(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	stats, err := w.App.Tag.GetTagStats(c.Request().Context(), user.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch stats",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"tags": stats,
+		},
+	})
+}
+
+func (w *WebApp) getTagClickStats(c echo.Context) error {
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid tag id",
+			Success: false,
+		})
+	}
+
+	tag, err := w.App.Tag.GetTagByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "tag not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch tag",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if tag.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to fetch this tag",
+			Success: false,
+		})
+	}
+
+	return w.clickStats(c, entity.ClickFilter{UserID: user.UserID, TagID: tag.ID})
+}
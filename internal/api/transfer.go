@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var transferContentTypes = map[string]string{
+	service.TransferFormatCSV:    "text/csv; charset=utf-8",
+	service.TransferFormatNDJSON: "application/x-ndjson",
+}
+
+func (w *WebApp) exportURLs(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = service.TransferFormatCSV
+	}
+
+	if !service.IsTransferFormat(format) {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: service.ErrUnknownTransferFormat.Error(),
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	filename := fmt.Sprintf("kuchak-links-%s.%s", time.Now().UTC().Format(time.DateOnly), format)
+	c.Response().Header().Set(echo.HeaderContentType, transferContentTypes[format])
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The status is already sent, a failure can only cut the stream short.
+	if err := w.App.URLTransfer.Export(c.Request().Context(), user.UserID, format, c.Response()); err != nil {
+		log.Err(err).Int("user_id", user.UserID).Msg("failed to export urls")
+	}
+
+	return nil
+}
+
+func (w *WebApp) importURLs(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = service.TransferFormatCSV
+	}
+
+	onConflict := c.QueryParam("on_conflict")
+	if onConflict == "" {
+		onConflict = service.ImportConflictSkip
+	}
+
+	if !service.IsTransferFormat(format) {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: service.ErrUnknownTransferFormat.Error(),
+			Success: false,
+		})
+	}
+
+	if onConflict != service.ImportConflictSkip && onConflict != service.ImportConflictRename {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "on_conflict must be skip or rename",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	report, err := w.App.URLTransfer.Import(c.Request().Context(), user.UserID, format, c.Request().Body, service.ImportOptions{
+		OnConflict:   onConflict,
+		EnforceQuota: true,
+		Unprotect:    c.QueryParam("unprotect") == "true",
+		ActorID:      user.UserID,
+		RequestID:    requestID(c),
+	})
+	if err != nil {
+		log.Err(err).Int("user_id", user.UserID).Msg("failed to import urls")
+
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrQuotaExceeded):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, service.ErrInvalidImport):
+			status = http.StatusBadRequest
+		}
+
+		return c.JSON(status, ResponseOk{
+			Message: fmt.Sprintf("import stopped: %s", err.Error()),
+			Success: false,
+			Data: echo.Map{
+				"report": report,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: fmt.Sprintf("%d urls imported", report.Imported),
+		Success: true,
+		Data: echo.Map{
+			"report": report,
+		},
+	})
+}
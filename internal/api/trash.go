@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"kuchak/internal/entity"
+	"kuchak/internal/service"
+	"kuchak/pkg/auth"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+func (w *WebApp) getTrash(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	urls, err := w.App.URLPostgres.GetDeletedURLsByUserID(c.Request().Context(), user.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch trash",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"urls":      urls,
+			"retention": w.App.Trash.Retention().String(),
+		},
+	})
+}
+
+func (w *WebApp) restoreURL(c echo.Context) error {
+	dbURL, err := w.App.URLPostgres.GetDeletedURLByShortURL(c.Request().Context(), c.Param("shortURL"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found in trash",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to restore this url",
+			Success: false,
+		})
+	}
+
+	before := dbURL
+
+	// Blocklists may have changed while the url was in the trash.
+	screening := w.App.Screening.ScreenAll(dbURL.Destinations())
+	if screening.Verdict != dbURL.Screening {
+		if err := w.App.URLPostgres.UpdateURLScreening(c.Request().Context(), dbURL.ShortURL, screening.Verdict); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrMessage{
+				Message: "failed to restore url",
+				Success: false,
+			})
+		}
+		dbURL.Screening = screening.Verdict
+	}
+
+	if err := w.App.URLPostgres.RestoreURL(c.Request().Context(), dbURL); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to restore url",
+			Success: false,
+		})
+	}
+	dbURL.DeletedAt = nil
+
+	w.App.URLHistory.Record(c.Request().Context(), service.Entry(entity.HistoryRestore, &before, dbURL, user.UserID, requestID(c)))
+
+	log.Info().Str("short_url", dbURL.ShortURL).Msg("url restored")
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "url restored successfully",
+		Success: true,
+		Data: echo.Map{
+			"url": dbURL,
+		},
+	})
+}
+
+func (w *WebApp) purgeURL(c echo.Context) error {
+	dbURL, err := w.App.URLPostgres.GetDeletedURLByShortURL(c.Request().Context(), c.Param("shortURL"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found in trash",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to purge this url",
+			Success: false,
+		})
+	}
+
+	if err := w.App.URLPostgres.PurgeURL(c.Request().Context(), dbURL); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to purge url",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "url deleted permanently",
+		Success: true,
+	})
+}
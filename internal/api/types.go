@@ -1,5 +1,10 @@
 package api
 
+import (
+	"kuchak/internal/entity"
+	"time"
+)
+
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,password"`
@@ -27,13 +32,159 @@ type EmailRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+type EmailChangeRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type PasswordRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
 type AuthTokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
 
+// URLRequest creates a link or changes one. Fields left out keep the link's
+// current value, when creating only OriginalURL is required. Redirect
+// settings and rules are checked by URLPolicyService.NormalizeRules, which
+// imports go through as well.
 type URLRequest struct {
-	OriginalURL string `json:"original_url"`
+	OriginalURL *string `json:"original_url"`
+	Preview     *bool   `json:"preview"`
+	// RedirectStatus and QueryConflict go back to the server default when
+	// set to 0 or "".
+	RedirectStatus *int    `json:"redirect_status"`
+	ForwardQuery   *bool   `json:"forward_query"`
+	QueryConflict  *string `json:"query_conflict"`
+	ForwardPath    *bool   `json:"forward_path"`
+	// UTMTemplateID copies a template's parameters onto the link, 0
+	// removes them.
+	UTMTemplateID *int `json:"utm_template_id" validate:"omitempty,min=0"`
+
+	// Rule lists replace the link's current ones, an empty list removes
+	// them.
+	DeviceRules *[]DeviceRuleRequest `json:"device_rules"`
+	GeoRules    *[]GeoRuleRequest    `json:"geo_rules"`
+
+	Variants       *[]VariantRequest `json:"variants"`
+	StickyVariants *bool             `json:"sticky_variants"`
+
+	// ActiveFrom is an RFC 3339 time, an empty string activates the link
+	// right away.
+	ActiveFrom *string                `json:"active_from"`
+	Schedule   *[]ScheduleRuleRequest `json:"schedule"`
+
+	// Password protects the link when set, an empty string removes the
+	// protection and leaving it out keeps the current one.
+	Password *string `json:"password" validate:"omitempty,max=256"`
+
+	// FolderID moves the link into one of the user's folders, 0 moves it
+	// to the top level and leaving it out keeps the current folder.
+	FolderID *int `json:"folder_id" validate:"omitempty,min=0"`
+
+	// Title, Description and Notes are kept as they are when left out. A
+	// link without a title gets one fetched from its destination.
+	Title       *string `json:"title" validate:"omitempty,max=512"`
+	Description *string `json:"description" validate:"omitempty,max=2048"`
+	Notes       *string `json:"notes" validate:"omitempty,max=10000"`
+}
+
+type BulkURLRequest struct {
+	URLs []BulkURLItem `json:"urls" validate:"required,min=1"`
+}
+
+// BulkURLItem is a URLRequest that may also ask for a custom short url.
+type BulkURLItem struct {
+	URLRequest
+	Alias string `json:"alias"`
+}
+
+type BulkURLResult struct {
+	Index   int         `json:"index"`
+	Success bool        `json:"success"`
+	URL     *entity.URL `json:"url,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type DeviceRuleRequest struct {
+	OS          string `json:"os"`
+	Device      string `json:"device"`
+	Bot         *bool  `json:"bot"`
+	Destination string `json:"destination"`
+}
+
+type GeoRuleRequest struct {
+	Countries   []string `json:"countries"`
+	Destination string   `json:"destination"`
+}
+
+type VariantRequest struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Weight      int    `json:"weight"`
+}
+
+type ScheduleRuleRequest struct {
+	From        *time.Time `json:"from"`
+	Until       *time.Time `json:"until"`
+	Destination string     `json:"destination"`
+}
+
+type UnlockRequest struct {
+	Password string `form:"password"`
+	Next     string `form:"next"`
+}
+
+type QRRequest struct {
+	Format     string `query:"format" validate:"oneof=png svg"`
+	Size       int    `query:"size" validate:"min=64,max=2048"`
+	Level      string `query:"level" validate:"oneof=L M Q H"`
+	Margin     int    `query:"margin" validate:"min=0,max=16"`
+	Foreground string `query:"fg" validate:"hexadecimal,len=6"`
+	Background string `query:"bg" validate:"hexadecimal,len=6"`
+}
+
+type ConversionRequest struct {
+	Variant string `json:"variant" validate:"max=64"`
+}
+
+type UTMTemplateRequest struct {
+	Name     string `json:"name" validate:"required,max=255"`
+	Source   string `json:"source" validate:"max=255"`
+	Medium   string `json:"medium" validate:"max=255"`
+	Campaign string `json:"campaign" validate:"max=255"`
+	Term     string `json:"term" validate:"max=255"`
+	Content  string `json:"content" validate:"max=255"`
+}
+
+type TagRequest struct {
+	Name  string `json:"name" validate:"required,max=64"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+type URLTagsRequest struct {
+	TagIDs []int `json:"tag_ids" validate:"max=50,dive,min=1"`
+}
+
+type RetagRequest struct {
+	ShortURLs []string `json:"short_urls" validate:"required,min=1,dive,required"`
+	Add       []int    `json:"add" validate:"max=50,dive,min=1"`
+	Remove    []int    `json:"remove" validate:"max=50,dive,min=1"`
+}
+
+type FolderRequest struct {
+	Name     string `json:"name" validate:"required,max=255"`
+	ParentID int    `json:"parent_id" validate:"omitempty,min=1"`
+}
+
+type ForcePreviewRequest struct {
+	ForcePreview bool `json:"force_preview"`
 }
 
 type ErrMessage struct {
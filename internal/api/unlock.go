@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/config"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+func unlockCookieName(shortURL string) string {
+	return "unlock_" + shortURL
+}
+
+// unlockMessage is what the unlock cookie signs. Including the password hash
+// invalidates existing cookies once the password changes.
+func unlockMessage(url entity.URL) string {
+	return "unlock:" + url.ShortURL + ":" + url.PasswordHash
+}
+
+func isUnlocked(c echo.Context, url entity.URL) bool {
+	cookie, err := c.Cookie(unlockCookieName(url.ShortURL))
+	if err != nil {
+		return false
+	}
+	return auth.VerifySignature(config.AppConfig.UnlockCookieSecret, unlockMessage(url), cookie.Value) == nil
+}
+
+func (w *WebApp) renderUnlock(c echo.Context, url entity.URL, status int, message string) error {
+	next := c.FormValue("next")
+	if c.Request().Method == http.MethodGet {
+		next = c.Request().URL.RequestURI()
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	return c.Render(status, "unlock.html", echo.Map{
+		"ShortURL": fmt.Sprintf("%s/%s", w.appURL, url.ShortURL),
+		"Action":   fmt.Sprintf("/%s/unlock", url.ShortURL),
+		"Next":     next,
+		"Error":    message,
+	})
+}
+
+func (w *WebApp) unlockURL(c echo.Context) error {
+	var unlockRequest UnlockRequest
+	if err := c.Bind(&unlockRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	if dbURL.PasswordHash == "" {
+		return c.JSON(http.StatusNotFound, ErrMessage{
+			Message: "url is not protected",
+			Success: false,
+		})
+	}
+
+	allowed, err := w.App.RateLimit.IsUnlockAllowed(c.Request().Context(), shortURL, c.RealIP(),
+		config.AppConfig.UnlockLimitPerLink, config.AppConfig.UnlockLimitPerIP, config.AppConfig.UnlockLimitWindow)
+	if err != nil {
+		log.Err(err).Msg("rate limit check failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "rate limit check failed")
+	}
+	if !allowed {
+		return w.renderUnlock(c, dbURL, http.StatusTooManyRequests, "Too many attempts, please try again later.")
+	}
+
+	if err := auth.PasswordVerify(dbURL.PasswordHash, unlockRequest.Password); err != nil {
+		log.Info().Str("short_url", shortURL).Str("ip", c.RealIP()).Msg("wrong link password")
+		return w.renderUnlock(c, dbURL, http.StatusUnauthorized, "Wrong password.")
+	}
+
+	expires := time.Now().Add(config.AppConfig.UnlockCookieTTL)
+	c.SetCookie(&http.Cookie{
+		Name:     unlockCookieName(shortURL),
+		Value:    auth.Sign(config.AppConfig.UnlockCookieSecret, unlockMessage(dbURL), expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Only send the visitor back to this link, never to an arbitrary url.
+	next := "/" + shortURL
+	if rest, ok := strings.CutPrefix(unlockRequest.Next, next); ok && (rest == "" || strings.ContainsAny(rest[:1], "/?+")) {
+		next = unlockRequest.Next
+	}
+
+	return c.Redirect(http.StatusSeeOther, next)
+}
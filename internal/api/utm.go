@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var errUTMTemplateNotFound = errors.New("utm template not found")
+
+// utmFromTemplate returns the parameters of one of the user's templates, or
+// nil when no template was requested.
+func (w *WebApp) utmFromTemplate(ctx context.Context, templateID, userID int) (*entity.UTM, error) {
+	if templateID == 0 {
+		return nil, nil
+	}
+
+	template, err := w.App.UTMTemplate.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errUTMTemplateNotFound
+		}
+		return nil, err
+	}
+
+	if template.UserID != userID {
+		return nil, errUTMTemplateNotFound
+	}
+
+	return &template.UTM, nil
+}
+
+func (w *WebApp) getAllUTMTemplates(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	templates, err := w.App.UTMTemplate.GetTemplatesByUserID(c.Request().Context(), user.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch utm templates",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"templates": templates,
+		},
+	})
+}
+
+func (w *WebApp) createUTMTemplate(c echo.Context) error {
+	var templateRequest UTMTemplateRequest
+	if err := c.Bind(&templateRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(templateRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	template, err := w.App.UTMTemplate.CreateTemplate(c.Request().Context(), entity.UTMTemplate{
+		UserID: user.UserID,
+		Name:   templateRequest.Name,
+		UTM:    templateRequest.utm(),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "utm template already exists",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to create utm template",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "utm template created successfully",
+		Success: true,
+		Data: echo.Map{
+			"template": template,
+		},
+	})
+}
+
+func (w *WebApp) updateUTMTemplate(c echo.Context) error {
+	var templateRequest UTMTemplateRequest
+	if err := c.Bind(&templateRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(templateRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid utm template id",
+			Success: false,
+		})
+	}
+
+	template, err := w.App.UTMTemplate.GetTemplateByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "utm template not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch utm template",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if template.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to update this utm template",
+			Success: false,
+		})
+	}
+
+	template.Name = templateRequest.Name
+	template.UTM = templateRequest.utm()
+
+	if err := w.App.UTMTemplate.UpdateTemplate(c.Request().Context(), template); err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, ErrMessage{
+				Message: "utm template already exists",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to update utm template",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "utm template updated successfully",
+		Success: true,
+		Data: echo.Map{
+			"template": template,
+		},
+	})
+}
+
+func (w *WebApp) deleteUTMTemplate(c echo.Context) error {
+	ID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid utm template id",
+			Success: false,
+		})
+	}
+
+	template, err := w.App.UTMTemplate.GetTemplateByID(c.Request().Context(), ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "utm template not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch utm template",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if template.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to delete this utm template",
+			Success: false,
+		})
+	}
+
+	if err := w.App.UTMTemplate.DeleteTemplate(c.Request().Context(), template); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to delete utm template",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "utm template deleted successfully",
+		Success: true,
+	})
+}
+
+func (w *WebApp) getUTMStats(c echo.Context) error {
+	user := c.Get("user").(*auth.Claims)
+
+	return w.clickStats(c, entity.ClickFilter{UserID: user.UserID})
+}
+
+func (r UTMTemplateRequest) utm() entity.UTM {
+	return entity.UTM{
+		Source:   r.Source,
+		Medium:   r.Medium,
+		Campaign: r.Campaign,
+		Term:     r.Term,
+		Content:  r.Content,
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/pkg/auth"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const variantCookieMaxAge = 30 * 24 * time.Hour
+
+func variantCookieName(shortURL string) string {
+	return "variant_" + shortURL
+}
+
+func variantFromCookie(c echo.Context, shortURL string) string {
+	cookie, err := c.Cookie(variantCookieName(shortURL))
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// setVariantCookie remembers the variant served to the visitor for sticky
+// assignment. It is not used to attribute conversions, those are usually
+// reported from the destination's own site and the cookie is not sent
+// cross-site.
+func setVariantCookie(c echo.Context, shortURL, variant string) {
+	c.SetCookie(&http.Cookie{
+		Name:     variantCookieName(shortURL),
+		Value:    variant,
+		Path:     "/",
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (w *WebApp) recordConversion(c echo.Context) error {
+	var conversionRequest ConversionRequest
+	if err := c.Bind(&conversionRequest); err != nil {
+		log.Err(err).Msg("failed to bind request body")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "invalid request body",
+			Success: false,
+		})
+	}
+
+	if err := c.Validate(&conversionRequest); err != nil {
+		log.Err(err).Msg("failed to validate payload")
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: fmt.Sprintf("failed to validate payload: %s", err.Error()),
+			Success: false,
+		})
+	}
+
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	// Each variant has its own destination, so the landing page knows which
+	// one it is and has to say so.
+	variant := conversionRequest.Variant
+	if variant == "" && len(dbURL.Variants) > 0 {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "variant is required for links with variants",
+			Success: false,
+		})
+	}
+
+	if variant != "" && !hasVariant(dbURL.Variants, variant) {
+		return c.JSON(http.StatusBadRequest, ErrMessage{
+			Message: "unknown variant",
+			Success: false,
+		})
+	}
+
+	if err := w.App.Click.RecordConversion(c.Request().Context(), entity.Conversion{URLID: dbURL.ID, Variant: variant}); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to record conversion",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Message: "conversion recorded successfully",
+		Success: true,
+	})
+}
+
+func (w *WebApp) getVariantStats(c echo.Context) error {
+	shortURL := c.Param("shortURL")
+
+	dbURL, err := w.App.URLPostgres.GetURLByShortURL(c.Request().Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrMessage{
+				Message: "url not found",
+				Success: false,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch url",
+			Success: false,
+		})
+	}
+
+	user := c.Get("user").(*auth.Claims)
+
+	if dbURL.UserID != user.UserID {
+		return c.JSON(http.StatusForbidden, ErrMessage{
+			Message: "not have access to fetch this url",
+			Success: false,
+		})
+	}
+
+	stats, err := w.App.Click.GetVariantStats(c.Request().Context(), dbURL.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrMessage{
+			Message: "failed to fetch stats",
+			Success: false,
+		})
+	}
+
+	return c.JSON(http.StatusOK, ResponseOk{
+		Success: true,
+		Data: echo.Map{
+			"variants": stats,
+		},
+	})
+}
+
+func hasVariant(variants []entity.Variant, name string) bool {
+	for _, v := range variants {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -21,6 +21,7 @@ func NewWebApp(
 	app *service.App,
 ) *WebApp {
 	e := echo.New()
+	e.Renderer = newTemplateRenderer("internal/templates/*.html")
 	wa := &WebApp{
 		App:    app,
 		e:      e,
@@ -1,25 +1,101 @@
 package config
 
 import (
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	ServerAddr         string
-	AccessTokenSecret  string
-	RefreshTokenSecret string
-	PostgresUser       string
-	PostgresPasswrod   string
-	PostgresHost       string
-	PostgresPort       string
-	PostgresDB         string
-	RedisHost          string
-	RedisPort          string
-	SmtpHost           string
-	SmtpPort           string
-	SmtpUsername       string
-	SmtpPassword       string
-	AppURL             string
+	ServerAddr       string
+	PostgresUser     string
+	PostgresPasswrod string
+	PostgresHost     string
+	PostgresPort     string
+	PostgresDB       string
+	RedisHost        string
+	RedisPort        string
+	SmtpHost         string
+	SmtpPort         string
+	SmtpUsername     string
+	SmtpPassword     string
+	AppURL           string
+
+	URLAllowedSchemes   []string
+	URLMaxLength        int
+	URLAllowedDomains   []string
+	URLDeniedDomains    []string
+	URLShortenerDomains []string
+
+	ScreenDomainBlocklists  []string
+	ScreenPatternBlocklists []string
+	ScreenBlocklistAction   string
+	ScreenIPHostAction      string
+	ScreenMaxSubdomains     int
+	ScreenSubdomainAction   string
+	ScreenReloadInterval    time.Duration
+
+	RedirectDefaultStatus   int
+	RedirectPermanentMaxAge time.Duration
+	RedirectQueryConflict   string
+
+	GeoIPDatabasePath   string
+	GeoIPReloadInterval time.Duration
+
+	UnlockCookieSecret string
+	UnlockCookieTTL    time.Duration
+	UnlockLimitPerLink int
+	UnlockLimitPerIP   int
+	UnlockLimitWindow  time.Duration
+
+	BulkMaxURLs   int
+	URLDailyQuota int
+
+	MetadataFetchInterval     time.Duration
+	MetadataFetchBatch        int
+	MetadataFetchTimeout      time.Duration
+	MetadataFetchMaxBytes     int64
+	MetadataFetchMaxRedirects int
+
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	AccountDeletionGrace    time.Duration
+	AccountDeletionInterval time.Duration
+
+	MagicLinkTTL           time.Duration
+	MagicLinkLimitPerEmail int
+	MagicLinkLimitPerIP    int
+	MagicLinkLimitWindow   time.Duration
+
+	OIDCProviders []OIDCProviderConfig
+
+	JWTSigningKey   string
+	JWTSigningKeyID string
+	JWTEphemeralKey bool
+	JWTPreviousKeys []string
+	JWTKeyRotatedAt time.Time
+	JWTKeyOverlap   time.Duration
+	JWTIssuer       string
+	JWTAudience     string
+
+	PasswordArgon2Memory      uint32
+	PasswordArgon2Iterations  uint32
+	PasswordArgon2Parallelism uint8
+	PasswordArgon2SaltLength  uint32
+	PasswordArgon2KeyLength   uint32
+}
+
+// OIDCProviderConfig is an OpenID Connect provider users can sign in with.
+// Each one named in OIDC_PROVIDERS is read from OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	AutoCreate   bool
 }
 
 var AppConfig *Config
@@ -28,21 +104,156 @@ func LoadConfig() {
 	viper.SetConfigFile(".env")
 	viper.ReadInConfig()
 	viper.AutomaticEnv()
+
+	viper.SetDefault("URL_ALLOWED_SCHEMES", "http,https")
+	viper.SetDefault("URL_MAX_LENGTH", 2048)
+	viper.SetDefault("URL_SHORTENER_DOMAINS", "bit.ly,tinyurl.com,t.co,goo.gl,ow.ly,is.gd,buff.ly,rebrand.ly,cutt.ly,shorturl.at")
+	viper.SetDefault("SCREEN_BLOCKLIST_ACTION", "reject")
+	viper.SetDefault("SCREEN_IP_HOST_ACTION", "review")
+	viper.SetDefault("SCREEN_MAX_SUBDOMAINS", 4)
+	viper.SetDefault("SCREEN_SUBDOMAIN_ACTION", "interstitial")
+	viper.SetDefault("SCREEN_RELOAD_INTERVAL", time.Minute)
+	viper.SetDefault("REDIRECT_DEFAULT_STATUS", 301)
+	viper.SetDefault("REDIRECT_PERMANENT_MAX_AGE", time.Hour)
+	viper.SetDefault("REDIRECT_QUERY_CONFLICT", "keep")
+	viper.SetDefault("GEOIP_RELOAD_INTERVAL", time.Minute)
+	viper.SetDefault("UNLOCK_COOKIE_TTL", time.Hour)
+	viper.SetDefault("UNLOCK_LIMIT_PER_LINK", 100)
+	viper.SetDefault("UNLOCK_LIMIT_PER_IP", 10)
+	viper.SetDefault("UNLOCK_LIMIT_WINDOW", 15*time.Minute)
+	viper.SetDefault("BULK_MAX_URLS", 1000)
+	viper.SetDefault("URL_DAILY_QUOTA", 5000)
+	viper.SetDefault("METADATA_FETCH_INTERVAL", 10*time.Second)
+	viper.SetDefault("METADATA_FETCH_BATCH", 20)
+	viper.SetDefault("METADATA_FETCH_TIMEOUT", 5*time.Second)
+	viper.SetDefault("METADATA_FETCH_MAX_BYTES", 512<<10)
+	viper.SetDefault("METADATA_FETCH_MAX_REDIRECTS", 5)
+	viper.SetDefault("TRASH_RETENTION", 30*24*time.Hour)
+	viper.SetDefault("TRASH_PURGE_INTERVAL", time.Hour)
+	viper.SetDefault("ACCOUNT_DELETION_GRACE", 7*24*time.Hour)
+	viper.SetDefault("ACCOUNT_DELETION_INTERVAL", time.Hour)
+	viper.SetDefault("MAGIC_LINK_TTL", 15*time.Minute)
+	viper.SetDefault("MAGIC_LINK_LIMIT_PER_EMAIL", 3)
+	viper.SetDefault("MAGIC_LINK_LIMIT_PER_IP", 10)
+	viper.SetDefault("MAGIC_LINK_LIMIT_WINDOW", time.Hour)
+	viper.SetDefault("JWT_KEY_OVERLAP", 7*24*time.Hour)
+	viper.SetDefault("JWT_AUDIENCE", "kuchak")
+	viper.SetDefault("PASSWORD_ARGON2_MEMORY", 64*1024)
+	viper.SetDefault("PASSWORD_ARGON2_ITERATIONS", 3)
+	viper.SetDefault("PASSWORD_ARGON2_PARALLELISM", 2)
+	viper.SetDefault("PASSWORD_ARGON2_SALT_LENGTH", 16)
+	viper.SetDefault("PASSWORD_ARGON2_KEY_LENGTH", 32)
+
 	AppConfig = &Config{
-		ServerAddr:         viper.GetString("SERVER_ADDR"),
-		AccessTokenSecret:  viper.GetString("ACCESS_TOKEN_SECRET"),
-		RefreshTokenSecret: viper.GetString("REFRESH_TOKEN_SECRET"),
-		PostgresUser:       viper.GetString("DB_APP_USER"),
-		PostgresPasswrod:   viper.GetString("DB_APP_PASSWORD"),
-		PostgresHost:       viper.GetString("POSTGRES_HOST"),
-		PostgresPort:       viper.GetString("POSTGRES_PORT"),
-		PostgresDB:         viper.GetString("POSTGRES_DB"),
-		RedisHost:          viper.GetString("REDIS_HOST"),
-		RedisPort:          viper.GetString("REDIS_PORT"),
-		SmtpHost:           viper.GetString("SMTP_HOST"),
-		SmtpPort:           viper.GetString("SMTP_PORT"),
-		SmtpUsername:       viper.GetString("SMTP_USERNAME"),
-		SmtpPassword:       viper.GetString("SMTP_PASSWORD"),
-		AppURL:             viper.GetString("APP_URL"),
+		ServerAddr:       viper.GetString("SERVER_ADDR"),
+		PostgresUser:     viper.GetString("DB_APP_USER"),
+		PostgresPasswrod: viper.GetString("DB_APP_PASSWORD"),
+		PostgresHost:     viper.GetString("POSTGRES_HOST"),
+		PostgresPort:     viper.GetString("POSTGRES_PORT"),
+		PostgresDB:       viper.GetString("POSTGRES_DB"),
+		RedisHost:        viper.GetString("REDIS_HOST"),
+		RedisPort:        viper.GetString("REDIS_PORT"),
+		SmtpHost:         viper.GetString("SMTP_HOST"),
+		SmtpPort:         viper.GetString("SMTP_PORT"),
+		SmtpUsername:     viper.GetString("SMTP_USERNAME"),
+		SmtpPassword:     viper.GetString("SMTP_PASSWORD"),
+		AppURL:           viper.GetString("APP_URL"),
+
+		URLAllowedSchemes:   splitList(viper.GetString("URL_ALLOWED_SCHEMES")),
+		URLMaxLength:        viper.GetInt("URL_MAX_LENGTH"),
+		URLAllowedDomains:   splitList(viper.GetString("URL_ALLOWED_DOMAINS")),
+		URLDeniedDomains:    splitList(viper.GetString("URL_DENIED_DOMAINS")),
+		URLShortenerDomains: splitList(viper.GetString("URL_SHORTENER_DOMAINS")),
+
+		ScreenDomainBlocklists:  splitList(viper.GetString("SCREEN_DOMAIN_BLOCKLISTS")),
+		ScreenPatternBlocklists: splitList(viper.GetString("SCREEN_PATTERN_BLOCKLISTS")),
+		ScreenBlocklistAction:   viper.GetString("SCREEN_BLOCKLIST_ACTION"),
+		ScreenIPHostAction:      viper.GetString("SCREEN_IP_HOST_ACTION"),
+		ScreenMaxSubdomains:     viper.GetInt("SCREEN_MAX_SUBDOMAINS"),
+		ScreenSubdomainAction:   viper.GetString("SCREEN_SUBDOMAIN_ACTION"),
+		ScreenReloadInterval:    viper.GetDuration("SCREEN_RELOAD_INTERVAL"),
+
+		RedirectDefaultStatus:   viper.GetInt("REDIRECT_DEFAULT_STATUS"),
+		RedirectPermanentMaxAge: viper.GetDuration("REDIRECT_PERMANENT_MAX_AGE"),
+		RedirectQueryConflict:   viper.GetString("REDIRECT_QUERY_CONFLICT"),
+
+		GeoIPDatabasePath:   viper.GetString("GEOIP_DB_PATH"),
+		GeoIPReloadInterval: viper.GetDuration("GEOIP_RELOAD_INTERVAL"),
+
+		UnlockCookieSecret: viper.GetString("UNLOCK_COOKIE_SECRET"),
+		UnlockCookieTTL:    viper.GetDuration("UNLOCK_COOKIE_TTL"),
+		UnlockLimitPerLink: viper.GetInt("UNLOCK_LIMIT_PER_LINK"),
+		UnlockLimitPerIP:   viper.GetInt("UNLOCK_LIMIT_PER_IP"),
+		UnlockLimitWindow:  viper.GetDuration("UNLOCK_LIMIT_WINDOW"),
+
+		BulkMaxURLs:   viper.GetInt("BULK_MAX_URLS"),
+		URLDailyQuota: viper.GetInt("URL_DAILY_QUOTA"),
+
+		MetadataFetchInterval:     viper.GetDuration("METADATA_FETCH_INTERVAL"),
+		MetadataFetchBatch:        viper.GetInt("METADATA_FETCH_BATCH"),
+		MetadataFetchTimeout:      viper.GetDuration("METADATA_FETCH_TIMEOUT"),
+		MetadataFetchMaxBytes:     viper.GetInt64("METADATA_FETCH_MAX_BYTES"),
+		MetadataFetchMaxRedirects: viper.GetInt("METADATA_FETCH_MAX_REDIRECTS"),
+
+		TrashRetention:     viper.GetDuration("TRASH_RETENTION"),
+		TrashPurgeInterval: viper.GetDuration("TRASH_PURGE_INTERVAL"),
+
+		AccountDeletionGrace:    viper.GetDuration("ACCOUNT_DELETION_GRACE"),
+		AccountDeletionInterval: viper.GetDuration("ACCOUNT_DELETION_INTERVAL"),
+
+		MagicLinkTTL:           viper.GetDuration("MAGIC_LINK_TTL"),
+		MagicLinkLimitPerEmail: viper.GetInt("MAGIC_LINK_LIMIT_PER_EMAIL"),
+		MagicLinkLimitPerIP:    viper.GetInt("MAGIC_LINK_LIMIT_PER_IP"),
+		MagicLinkLimitWindow:   viper.GetDuration("MAGIC_LINK_LIMIT_WINDOW"),
+
+		OIDCProviders: oidcProviders(),
+
+		JWTSigningKey:   viper.GetString("JWT_SIGNING_KEY"),
+		JWTSigningKeyID: viper.GetString("JWT_SIGNING_KEY_ID"),
+		JWTEphemeralKey: viper.GetBool("JWT_EPHEMERAL_KEY"),
+		JWTPreviousKeys: splitList(viper.GetString("JWT_PREVIOUS_KEYS")),
+		JWTKeyRotatedAt: viper.GetTime("JWT_KEY_ROTATED_AT"),
+		JWTKeyOverlap:   viper.GetDuration("JWT_KEY_OVERLAP"),
+		JWTIssuer:       viper.GetString("JWT_ISSUER"),
+		JWTAudience:     viper.GetString("JWT_AUDIENCE"),
+
+		PasswordArgon2Memory:      viper.GetUint32("PASSWORD_ARGON2_MEMORY"),
+		PasswordArgon2Iterations:  viper.GetUint32("PASSWORD_ARGON2_ITERATIONS"),
+		PasswordArgon2Parallelism: uint8(viper.GetUint("PASSWORD_ARGON2_PARALLELISM")),
+		PasswordArgon2SaltLength:  viper.GetUint32("PASSWORD_ARGON2_SALT_LENGTH"),
+		PasswordArgon2KeyLength:   viper.GetUint32("PASSWORD_ARGON2_KEY_LENGTH"),
+	}
+}
+
+func oidcProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range splitList(viper.GetString("OIDC_PROVIDERS")) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		scopes := splitList(viper.GetString(prefix + "SCOPES"))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			Scopes:       scopes,
+			AutoCreate:   viper.GetBool(prefix + "AUTO_CREATE"),
+		})
+	}
+	return providers
+}
+
+// splitList parses a comma separated env value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,56 @@
+package entity
+
+import "time"
+
+// Audit events, the security relevant things that happen to an account.
+const (
+	AuditLoginSucceeded           = "login.succeeded"
+	AuditLoginFailed              = "login.failed"
+	AuditMagicLinkRequested       = "login.magic_link_requested"
+	AuditIdentityLinked           = "identity.linked"
+	AuditRegister                 = "account.register"
+	AuditEmailVerified            = "email.verified"
+	AuditEmailChanged             = "email.changed"
+	AuditEmailChangeRequested     = "email.change_requested"
+	AuditEmailChangeFailed        = "email.change_failed"
+	AuditPasswordChanged          = "password.changed"
+	AuditPasswordChangeFailed     = "password.change_failed"
+	AuditPasswordResetRequest     = "password.reset_requested"
+	AuditPasswordReset            = "password.reset"
+	AuditTokenRefreshed           = "token.refreshed"
+	AuditTokenRefreshFailed       = "token.refresh_failed"
+	AuditModerationForcePreview   = "moderation.force_preview"
+	AuditAccountDeletionRequested = "account.deletion_requested"
+	AuditAccountDeletionFailed    = "account.deletion_failed"
+	AuditAccountDeletionScheduled = "account.deletion_scheduled"
+	AuditAccountDeletionCanceled  = "account.deletion_canceled"
+	AuditAccountExported          = "account.exported"
+)
+
+// AuditEvent records one security event. UserID is the account it happened
+// to, nil when it is unknown like a login for an unregistered email, and
+// ActorID is who caused it when that is someone else, like a moderator.
+type AuditEvent struct {
+	ID        int64          `json:"id"`
+	UserID    *int           `json:"user_id"`
+	ActorID   *int           `json:"actor_id,omitempty"`
+	Event     string         `json:"event"`
+	IP        string         `json:"ip"`
+	UserAgent string         `json:"user_agent"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditFilter narrows down audit events, zero fields match anything.
+// Events come newest first and BeforeID pages through them.
+type AuditFilter struct {
+	UserID   int
+	ActorID  int
+	Event    string
+	IP       string
+	From     *time.Time
+	Until    *time.Time
+	BeforeID int64
+	Limit    int
+}
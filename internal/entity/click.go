@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+type Click struct {
+	ID          int64     `json:"id"`
+	URLID       int       `json:"url_id"`
+	UTMSource   string    `json:"utm_source"`
+	UTMMedium   string    `json:"utm_medium"`
+	UTMCampaign string    `json:"utm_campaign"`
+	OS          string    `json:"os"`
+	Device      string    `json:"device"`
+	Bot         bool      `json:"bot"`
+	Country     string    `json:"country"`
+	Variant     string    `json:"variant"`
+	ClickedAt   time.Time `json:"clicked_at"`
+}
+
+type ClickFilter struct {
+	URLID  int
+	UserID int
+	TagID  int
+}
+
+type ClickGroup struct {
+	Key    string `json:"key"`
+	Clicks int    `json:"clicks"`
+}
+
+type Conversion struct {
+	ID          int64     `json:"id"`
+	URLID       int       `json:"url_id"`
+	Variant     string    `json:"variant"`
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+type VariantStats struct {
+	Variant        string  `json:"variant"`
+	Clicks         int     `json:"clicks"`
+	Conversions    int     `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// History actions, one per kind of link mutation.
+const (
+	HistoryCreate   = "create"
+	HistoryUpdate   = "update"
+	HistoryModerate = "moderate"
+	HistoryDelete   = "delete"
+	HistoryRestore  = "restore"
+	HistoryRevert   = "revert"
+)
+
+// URLHistory is one entry of a link's append-only history. Snapshot is the
+// link as it was right after the change.
+type URLHistory struct {
+	ID        int64                  `json:"id"`
+	URLID     int                    `json:"url_id"`
+	Version   int                    `json:"version"`
+	Action    string                 `json:"action"`
+	ActorID   *int                   `json:"actor_id"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	Snapshot  URL                    `json:"snapshot"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// Identity links a user to their account at an OpenID Connect provider.
+type Identity struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCState is what a sign in attempt needs to remember between sending the
+// user to the provider and the callback.
+type OIDCState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+type Tag struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TagStats struct {
+	TagID  int    `json:"tag_id"`
+	Name   string `json:"name"`
+	Links  int    `json:"links"`
+	Clicks int    `json:"clicks"`
+}
+
+// MaxFolderDepth bounds how deep folders can be nested.
+const MaxFolderDepth = 8
+
+// Folder groups links, a nil ParentID puts it at the top level.
+type Folder struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	ParentID  *int      `json:"parent_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
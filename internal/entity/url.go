@@ -3,10 +3,154 @@ package entity
 import "time"
 
 type URL struct {
-	ID          int       `json:"id"`
-	ShortURL    string    `json:"short_url"`
-	OriginalURL string    `json:"original_url"`
-	UserID      int       `json:"user_id"`
-	ClickCount  int       `json:"click_count"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int            `json:"id"`
+	ShortURL       string         `json:"short_url"`
+	OriginalURL    string         `json:"original_url"`
+	Title          string         `json:"title"`
+	Description    string         `json:"description"`
+	Notes          string         `json:"notes"`
+	MetadataStatus string         `json:"metadata_status,omitempty"`
+	UserID         int            `json:"user_id"`
+	ClickCount     int            `json:"click_count"`
+	Screening      string         `json:"screening"`
+	Preview        bool           `json:"preview"`
+	ForcePreview   bool           `json:"force_preview"`
+	RedirectStatus int            `json:"redirect_status"`
+	ForwardQuery   bool           `json:"forward_query"`
+	QueryConflict  string         `json:"query_conflict"`
+	ForwardPath    bool           `json:"forward_path"`
+	UTM            *UTM           `json:"utm,omitempty"`
+	DeviceRules    []DeviceRule   `json:"device_rules,omitempty"`
+	GeoRules       []GeoRule      `json:"geo_rules,omitempty"`
+	Variants       []Variant      `json:"variants,omitempty"`
+	StickyVariants bool           `json:"sticky_variants"`
+	ActiveFrom     *time.Time     `json:"active_from,omitempty"`
+	Schedule       []ScheduleRule `json:"schedule,omitempty"`
+	PasswordHash   string         `json:"-"`
+	Protected      bool           `json:"protected"`
+	FolderID       *int           `json:"folder_id"`
+	Tags           []Tag          `json:"tags,omitempty"`
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
 }
+
+// Metadata statuses track the background fetch of a link's title and
+// description.
+const (
+	MetadataPending = "pending"
+	MetadataDone    = "done"
+	MetadataFailed  = "failed"
+)
+
+// URLFilter narrows down a user's urls. A zero FolderID matches every
+// folder, Subfolders also matches the folders nested below it and every one
+// of TagIDs has to be on a url for it to match.
+type URLFilter struct {
+	UserID     int
+	FolderID   int
+	Subfolders bool
+	TagIDs     []int
+}
+
+// DeviceRule sends clicks from matching user agents to Destination. Empty
+// fields match anything.
+type DeviceRule struct {
+	OS          string `json:"os,omitempty"`
+	Device      string `json:"device,omitempty"`
+	Bot         *bool  `json:"bot,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// GeoRule sends clicks from any of Countries (ISO 3166-1 alpha-2 codes) to
+// Destination.
+type GeoRule struct {
+	Countries   []string `json:"countries"`
+	Destination string   `json:"destination"`
+}
+
+// Variant is one of the destinations a link rotates between, picked with a
+// probability proportional to its Weight.
+type Variant struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Weight      int    `json:"weight"`
+}
+
+// ScheduleRule sends clicks made between From and Until to Destination. A
+// nil bound leaves that side of the window open.
+type ScheduleRule struct {
+	From        *time.Time `json:"from,omitempty"`
+	Until       *time.Time `json:"until,omitempty"`
+	Destination string     `json:"destination"`
+}
+
+// Contains reports whether t falls inside the rule's window.
+func (r ScheduleRule) Contains(t time.Time) bool {
+	if r.From != nil && t.Before(*r.From) {
+		return false
+	}
+	if r.Until != nil && !t.Before(*r.Until) {
+		return false
+	}
+	return true
+}
+
+// IsActive reports whether the link is live at t.
+func (u URL) IsActive(t time.Time) bool {
+	return u.ActiveFrom == nil || !t.Before(*u.ActiveFrom)
+}
+
+// NextBoundary returns the first time after t at which the link's
+// activation or schedule changes where it leads.
+func (u URL) NextBoundary(t time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	consider := func(b *time.Time) {
+		if b != nil && b.After(t) && (!found || b.Before(next)) {
+			next = *b
+			found = true
+		}
+	}
+
+	consider(u.ActiveFrom)
+	for _, rule := range u.Schedule {
+		consider(rule.From)
+		consider(rule.Until)
+	}
+
+	return next, found
+}
+
+// HasRouting reports whether clicks on the link may be sent to different
+// destinations depending on the visitor or the time.
+func (u URL) HasRouting() bool {
+	return len(u.DeviceRules) > 0 || len(u.GeoRules) > 0 || len(u.Variants) > 0 ||
+		len(u.Schedule) > 0 || u.ActiveFrom != nil
+}
+
+// Destinations returns every url a click on the link may be sent to.
+func (u URL) Destinations() []string {
+	destinations := []string{u.OriginalURL}
+	for _, rule := range u.DeviceRules {
+		destinations = append(destinations, rule.Destination)
+	}
+	for _, rule := range u.GeoRules {
+		destinations = append(destinations, rule.Destination)
+	}
+	for _, variant := range u.Variants {
+		destinations = append(destinations, variant.Destination)
+	}
+	for _, rule := range u.Schedule {
+		destinations = append(destinations, rule.Destination)
+	}
+	return destinations
+}
+
+// Screening verdicts, ordered from least to most severe. Links waiting for
+// review redirect through the interstitial until a moderator decides.
+const (
+	ScreeningAllow        = "allow"
+	ScreeningInterstitial = "interstitial"
+	ScreeningReview       = "review"
+	ScreeningReject       = "reject"
+)
@@ -3,9 +3,19 @@ package entity
 import "time"
 
 type User struct {
-	ID              int       `json:"id"`
-	Email           string    `json:"email"`
-	Password        string    `json:"_"`
-	IsEmailVerified bool      `json:"is_email_verified"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              int    `json:"id"`
+	Email           string `json:"email"`
+	Password        string `json:"-"`
+	IsEmailVerified bool   `json:"is_email_verified"`
+	Role            string `json:"role"`
+	// DeletionScheduledAt is when the account gets deleted, nil unless the
+	// user asked for it.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
 }
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+type UTMTemplate struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	UTM       UTM       `json:"utm"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"kuchak/internal/entity"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -24,9 +25,9 @@ func NewAccountPostgresRepository(session *pgxpool.Pool) *AccountPostgresReposit
 }
 
 func (a *AccountPostgresRepository) ByID(ctx context.Context, ID int) (entity.User, error) {
-	query := `SELECT id, email, password, is_email_verified, created_at FROM users WHERE id = $1`
+	query := `SELECT id, email, password, is_email_verified, role, deletion_scheduled_at, created_at FROM users WHERE id = $1`
 	var user entity.User
-	err := a.session.QueryRow(ctx, query, ID).Scan(&user.ID, &user.Email, &user.Password, &user.IsEmailVerified, &user.CreatedAt)
+	err := a.session.QueryRow(ctx, query, ID).Scan(&user.ID, &user.Email, &user.Password, &user.IsEmailVerified, &user.Role, &user.DeletionScheduledAt, &user.CreatedAt)
 	if err != nil {
 		log.Err(err).Int("id", ID).Msg("failed to fetch user by id")
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -39,10 +40,10 @@ func (a *AccountPostgresRepository) ByID(ctx context.Context, ID int) (entity.Us
 }
 
 func (a *AccountPostgresRepository) ByEmail(ctx context.Context, email string) (entity.User, error) {
-	query := `SELECT id, email, password, is_email_verified, created_at FROM users WHERE email = $1`
+	query := `SELECT id, email, password, is_email_verified, role, deletion_scheduled_at, created_at FROM users WHERE email = $1`
 
 	var user entity.User
-	err := a.session.QueryRow(ctx, query, email).Scan(&user.ID, &user.Email, &user.Password, &user.IsEmailVerified, &user.CreatedAt)
+	err := a.session.QueryRow(ctx, query, email).Scan(&user.ID, &user.Email, &user.Password, &user.IsEmailVerified, &user.Role, &user.DeletionScheduledAt, &user.CreatedAt)
 	if err != nil {
 		log.Err(err).Str("email", email).Msg("failed to fetch user by email")
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -82,10 +83,10 @@ func (a *AccountPostgresRepository) Delete(ctx context.Context, user entity.User
 
 func (a *AccountPostgresRepository) UpdateEmail(ctx context.Context, user entity.User) error {
 	query := `UPDATE users
-			  SET email = $1, is_email_verifed = false
-			  WHERE id = $2`
+			  SET email = $1, is_email_verified = $2
+			  WHERE id = $3`
 
-	_, err := a.session.Exec(ctx, query, user.Email, user.ID)
+	_, err := a.session.Exec(ctx, query, user.Email, user.IsEmailVerified, user.ID)
 	if err != nil {
 		log.Err(err).Interface("user", user).Msg("failed to update email")
 		return fmt.Errorf("failed to update email: %w", err)
@@ -121,3 +122,52 @@ func (a *AccountPostgresRepository) UpdateVerifyEmail(ctx context.Context, user
 
 	return nil
 }
+
+// ScheduleDeletion sets when the account of user gets deleted, a nil
+// user.DeletionScheduledAt cancels it.
+func (a *AccountPostgresRepository) ScheduleDeletion(ctx context.Context, user entity.User) error {
+	query := `UPDATE users
+			  SET deletion_scheduled_at = $1
+			  WHERE id = $2`
+
+	_, err := a.session.Exec(ctx, query, user.DeletionScheduledAt, user.ID)
+	if err != nil {
+		log.Err(err).Int("user_id", user.ID).Msg("failed to schedule user deletion")
+		return fmt.Errorf("failed to schedule user deletion: %w", err)
+	}
+
+	return nil
+}
+
+// DueForDeletion returns the users whose deletion is scheduled before t.
+func (a *AccountPostgresRepository) DueForDeletion(ctx context.Context, t time.Time) ([]entity.User, error) {
+	query := `SELECT id, email, password, is_email_verified, role, deletion_scheduled_at, created_at
+			  FROM users
+			  WHERE deletion_scheduled_at <= $1
+			  ORDER BY deletion_scheduled_at`
+
+	var users []entity.User
+
+	rows, err := a.session.Query(ctx, query, t)
+	if err != nil {
+		log.Err(err).Msg("failed to fetch users due for deletion")
+		return nil, fmt.Errorf("failed to fetch users due for deletion: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user entity.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.IsEmailVerified, &user.Role, &user.DeletionScheduledAt, &user.CreatedAt); err != nil {
+			log.Err(err).Msg("failed to scan user row")
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate user rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return users, nil
+}
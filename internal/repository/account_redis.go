@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"kuchak/internal/entity"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/rueidis"
@@ -118,3 +122,224 @@ func (a *AccountRedisRepository) ByResetEmail(ctx context.Context, email string)
 
 	return result, nil
 }
+
+// SaveEmailChange keeps the request of userID to move to email until token
+// is confirmed. A user has at most one pending change.
+func (a *AccountRedisRepository) SaveEmailChange(ctx context.Context, userID int, email, token string, ttl time.Duration) error {
+	keyUser := "email_change:user:" + strconv.Itoa(userID)
+	keyToken := "email_change:token:" + token
+
+	if err := a.client.Do(ctx, a.client.B().Set().Key(keyUser).Value(email).Nx().Px(ttl).Build()).Error(); err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to set email change user in redis")
+		return fmt.Errorf("failed to set email change user in redis: %w", err)
+	}
+
+	value := strconv.Itoa(userID) + ":" + email
+	if err := a.client.Do(ctx, a.client.B().Set().Key(keyToken).Value(value).Nx().Px(ttl).Build()).Error(); err != nil {
+		a.client.Do(ctx, a.client.B().Del().Key(keyUser).Build())
+		log.Err(err).Msg("failed to set email change token in redis")
+		return fmt.Errorf("failed to set email change token in redis: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AccountRedisRepository) ByEmailChangeUser(ctx context.Context, userID int) (string, error) {
+	key := "email_change:user:" + strconv.Itoa(userID)
+	cmd := a.client.B().Get().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch email change from redis")
+		if rueidis.IsRedisNil(err) {
+			return "", fmt.Errorf("email change is not pending: %w", err)
+		}
+		return "", fmt.Errorf("failed to fetch email change from redis: %w", err)
+	}
+
+	return result, nil
+}
+
+// ByEmailChangeToken consumes token and returns the user and the address
+// they asked to move to.
+func (a *AccountRedisRepository) ByEmailChangeToken(ctx context.Context, token string) (int, string, error) {
+	key := "email_change:token:" + token
+	cmd := a.client.B().Getdel().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		log.Err(err).Msg("failed to fetch email change token from redis")
+		if rueidis.IsRedisNil(err) {
+			return 0, "", fmt.Errorf("email change token is not valid or expired: %w", err)
+		}
+		return 0, "", fmt.Errorf("failed to fetch email change token from redis: %w", err)
+	}
+
+	rawID, email, _ := strings.Cut(result, ":")
+	userID, err := strconv.Atoi(rawID)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid email change token value: %w", err)
+	}
+
+	a.client.Do(ctx, a.client.B().Del().Key("email_change:user:"+rawID).Build())
+
+	return userID, email, nil
+}
+
+func (a *AccountRedisRepository) SaveDeletion(ctx context.Context, userID int, token string, ttl time.Duration) error {
+	keyUser := "account_deletion:user:" + strconv.Itoa(userID)
+	keyToken := "account_deletion:token:" + token
+
+	if err := a.client.Do(ctx, a.client.B().Set().Key(keyUser).Value(token).Nx().Px(ttl).Build()).Error(); err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to set account deletion user in redis")
+		return fmt.Errorf("failed to set account deletion user in redis: %w", err)
+	}
+
+	if err := a.client.Do(ctx, a.client.B().Set().Key(keyToken).Value(strconv.Itoa(userID)).Nx().Px(ttl).Build()).Error(); err != nil {
+		a.client.Do(ctx, a.client.B().Del().Key(keyUser).Build())
+		log.Err(err).Msg("failed to set account deletion token in redis")
+		return fmt.Errorf("failed to set account deletion token in redis: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AccountRedisRepository) ByDeletionUser(ctx context.Context, userID int) (string, error) {
+	key := "account_deletion:user:" + strconv.Itoa(userID)
+	cmd := a.client.B().Get().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch account deletion from redis")
+		if rueidis.IsRedisNil(err) {
+			return "", fmt.Errorf("account deletion is not pending: %w", err)
+		}
+		return "", fmt.Errorf("failed to fetch account deletion from redis: %w", err)
+	}
+
+	return result, nil
+}
+
+// ByDeletionToken consumes token and returns the user who asked for their
+// account to be deleted.
+func (a *AccountRedisRepository) ByDeletionToken(ctx context.Context, token string) (int, error) {
+	key := "account_deletion:token:" + token
+	cmd := a.client.B().Getdel().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		log.Err(err).Msg("failed to fetch account deletion token from redis")
+		if rueidis.IsRedisNil(err) {
+			return 0, fmt.Errorf("account deletion token is not valid or expired: %w", err)
+		}
+		return 0, fmt.Errorf("failed to fetch account deletion token from redis: %w", err)
+	}
+
+	userID, err := strconv.Atoi(result)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account deletion token value: %w", err)
+	}
+
+	a.client.Do(ctx, a.client.B().Del().Key("account_deletion:user:"+result).Build())
+
+	return userID, nil
+}
+
+// RevokeTokens invalidates every token of userID issued up to at. ttl
+// should outlive the longest lived token.
+func (a *AccountRedisRepository) RevokeTokens(ctx context.Context, userID int, at time.Time, ttl time.Duration) error {
+	key := "tokens_revoked:user:" + strconv.Itoa(userID)
+	cmd := a.client.B().Set().Key(key).Value(strconv.FormatInt(at.Unix(), 10)).Px(ttl).Build()
+
+	if err := a.client.Do(ctx, cmd).Error(); err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to revoke tokens in redis")
+		return fmt.Errorf("failed to revoke tokens in redis: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AccountRedisRepository) TokensRevokedAt(ctx context.Context, userID int) (time.Time, error) {
+	key := "tokens_revoked:user:" + strconv.Itoa(userID)
+	cmd := a.client.B().Get().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).AsInt64()
+	if err != nil {
+		if rueidis.IsRedisNil(err) {
+			return time.Time{}, fmt.Errorf("tokens are not revoked: %w", err)
+		}
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch token revocation from redis")
+		return time.Time{}, fmt.Errorf("failed to fetch token revocation from redis: %w", err)
+	}
+
+	return time.Unix(result, 0), nil
+}
+
+// SaveMagicLink keeps a sign in token for email. Unlike verify and reset
+// tokens several can be pending at once, they are rate limited instead.
+func (a *AccountRedisRepository) SaveMagicLink(ctx context.Context, email, token string, ttl time.Duration) error {
+	key := "magic_link:token:" + token
+
+	if err := a.client.Do(ctx, a.client.B().Set().Key(key).Value(email).Nx().Px(ttl).Build()).Error(); err != nil {
+		log.Err(err).Str("email", email).Msg("failed to set magic link token in redis")
+		return fmt.Errorf("failed to set magic link token in redis: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AccountRedisRepository) ByMagicLinkToken(ctx context.Context, token string) (string, error) {
+	key := "magic_link:token:" + token
+	cmd := a.client.B().Getdel().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		log.Err(err).Msg("failed to fetch magic link token from redis")
+		if rueidis.IsRedisNil(err) {
+			return "", fmt.Errorf("magic link token is not valid or expired: %w", err)
+		}
+		return "", fmt.Errorf("failed to fetch magic link token from redis: %w", err)
+	}
+
+	return result, nil
+}
+
+func (a *AccountRedisRepository) SaveOIDCState(ctx context.Context, state string, value entity.OIDCState, ttl time.Duration) error {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		log.Err(err).Msg("failed to serialize oidc state")
+		return fmt.Errorf("failed to serialize oidc state: %w", err)
+	}
+
+	key := "oidc:state:" + state
+
+	if err := a.client.Do(ctx, a.client.B().Set().Key(key).Value(string(jsonData)).Nx().Px(ttl).Build()).Error(); err != nil {
+		log.Err(err).Str("provider", value.Provider).Msg("failed to set oidc state in redis")
+		return fmt.Errorf("failed to set oidc state in redis: %w", err)
+	}
+
+	return nil
+}
+
+// ByOIDCState consumes state, each sign in attempt has one callback.
+func (a *AccountRedisRepository) ByOIDCState(ctx context.Context, state string) (entity.OIDCState, error) {
+	key := "oidc:state:" + state
+	cmd := a.client.B().Getdel().Key(key).Build()
+
+	result, err := a.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		log.Err(err).Msg("failed to fetch oidc state from redis")
+		if rueidis.IsRedisNil(err) {
+			return entity.OIDCState{}, fmt.Errorf("oidc state is not valid or expired: %w", err)
+		}
+		return entity.OIDCState{}, fmt.Errorf("failed to fetch oidc state from redis: %w", err)
+	}
+
+	var value entity.OIDCState
+	if err := json.Unmarshal([]byte(result), &value); err != nil {
+		log.Err(err).Msg("failed to deserialize oidc state")
+		return entity.OIDCState{}, fmt.Errorf("failed to deserialize oidc state: %w", err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ Audit = &AuditPostgresRepository{}
+
+type AuditPostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewAuditPostgresRepository(session *pgxpool.Pool) *AuditPostgresRepository {
+	return &AuditPostgresRepository{
+		session: session,
+	}
+}
+
+const auditColumns = `id, user_id, actor_id, event, ip, user_agent, request_id, details, created_at`
+
+func scanAuditEvent(row pgx.Row) (entity.AuditEvent, error) {
+	var e entity.AuditEvent
+	err := row.Scan(&e.ID, &e.UserID, &e.ActorID, &e.Event, &e.IP, &e.UserAgent, &e.RequestID, &e.Details, &e.CreatedAt)
+	return e, err
+}
+
+func (a *AuditPostgresRepository) Save(ctx context.Context, event entity.AuditEvent) error {
+	query := `INSERT INTO audit_events (user_id, actor_id, event, ip, user_agent, request_id, details)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := a.session.Exec(ctx, query, event.UserID, event.ActorID, event.Event, event.IP, event.UserAgent, event.RequestID, event.Details)
+	if err != nil {
+		log.Err(err).Str("event", event.Event).Msg("failed to save audit event")
+		return fmt.Errorf("failed to save audit event: %w", err)
+	}
+
+	return nil
+}
+
+// ByFilter returns the events matching filter, newest first.
+func (a *AuditPostgresRepository) ByFilter(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	query := `SELECT ` + auditColumns + `
+			  FROM audit_events
+			  WHERE ($1 = 0 OR user_id = $1)
+			  AND ($2 = 0 OR actor_id = $2)
+			  AND ($3 = '' OR event = $3)
+			  AND ($4 = '' OR ip = $4)
+			  AND ($5::timestamptz IS NULL OR created_at >= $5)
+			  AND ($6::timestamptz IS NULL OR created_at < $6)
+			  AND ($7 = 0 OR id < $7)
+			  ORDER BY id DESC
+			  LIMIT $8`
+
+	var events []entity.AuditEvent
+
+	rows, err := a.session.Query(ctx, query, filter.UserID, filter.ActorID, filter.Event, filter.IP,
+		filter.From, filter.Until, filter.BeforeID, filter.Limit)
+	if err != nil {
+		log.Err(err).Interface("filter", filter).Msg("failed to fetch audit events")
+		return nil, fmt.Errorf("failed to fetch audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan audit event row")
+			return nil, fmt.Errorf("failed to scan audit event row: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate audit event rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return events, nil
+}
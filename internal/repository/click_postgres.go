@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"kuchak/internal/entity"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ Click = &ClickPostgresRepository{}
+
+// clickDimensions maps the dimensions clicks can be grouped by to their column.
+var clickDimensions = map[string]string{
+	"source":   "c.utm_source",
+	"medium":   "c.utm_medium",
+	"campaign": "c.utm_campaign",
+	"os":       "c.os",
+	"device":   "c.device",
+	"bot":      "c.bot::text",
+	"country":  "c.country",
+	"variant":  "c.variant",
+}
+
+func IsClickDimension(dimension string) bool {
+	_, ok := clickDimensions[dimension]
+	return ok
+}
+
+// ClickDimensions lists the dimensions clicks can be grouped by, sorted.
+func ClickDimensions() []string {
+	dimensions := make([]string, 0, len(clickDimensions))
+	for dimension := range clickDimensions {
+		dimensions = append(dimensions, dimension)
+	}
+	slices.Sort(dimensions)
+	return dimensions
+}
+
+type ClickPostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewClickPostgresRepository(session *pgxpool.Pool) *ClickPostgresRepository {
+	return &ClickPostgresRepository{
+		session: session,
+	}
+}
+
+func (cl *ClickPostgresRepository) Save(ctx context.Context, click entity.Click) error {
+	query := `INSERT INTO clicks (url_id, utm_source, utm_medium, utm_campaign, os, device, bot, country, variant)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := cl.session.Exec(ctx, query, click.URLID, click.UTMSource, click.UTMMedium, click.UTMCampaign,
+		click.OS, click.Device, click.Bot, click.Country, click.Variant)
+	if err != nil {
+		log.Err(err).Interface("click", click).Msg("failed to save click")
+		return fmt.Errorf("failed to save click: %w", err)
+	}
+
+	return nil
+}
+
+func (cl *ClickPostgresRepository) GroupBy(ctx context.Context, filter entity.ClickFilter, dimension string) ([]entity.ClickGroup, error) {
+	column, ok := clickDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown click dimension: %s", dimension)
+	}
+
+	query := `SELECT ` + column + `, count(*)
+			  FROM clicks c
+			  JOIN urls u ON u.id = c.url_id
+			  WHERE ($1 = 0 OR c.url_id = $1) AND ($2 = 0 OR u.user_id = $2)
+			  AND ($3 = 0 OR c.url_id IN (SELECT url_id FROM url_tags WHERE tag_id = $3))
+			  GROUP BY 1
+			  ORDER BY 2 DESC`
+
+	var groups []entity.ClickGroup
+
+	rows, err := cl.session.Query(ctx, query, filter.URLID, filter.UserID, filter.TagID)
+	if err != nil {
+		log.Err(err).Interface("filter", filter).Str("dimension", dimension).Msg("failed to group clicks")
+		return nil, fmt.Errorf("failed to group clicks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group entity.ClickGroup
+		if err := rows.Scan(&group.Key, &group.Clicks); err != nil {
+			log.Err(err).Msg("failed to scan click group row")
+			return nil, fmt.Errorf("failed to scan click group row: %w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate click group rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return groups, nil
+}
+
+func (cl *ClickPostgresRepository) SaveConversion(ctx context.Context, conversion entity.Conversion) error {
+	query := `INSERT INTO conversions (url_id, variant)
+			  VALUES ($1, $2)`
+
+	_, err := cl.session.Exec(ctx, query, conversion.URLID, conversion.Variant)
+	if err != nil {
+		log.Err(err).Interface("conversion", conversion).Msg("failed to save conversion")
+		return fmt.Errorf("failed to save conversion: %w", err)
+	}
+
+	return nil
+}
+
+func (cl *ClickPostgresRepository) VariantStats(ctx context.Context, urlID int) ([]entity.VariantStats, error) {
+	query := `SELECT coalesce(c.variant, v.variant), coalesce(c.clicks, 0), coalesce(v.conversions, 0)
+			  FROM (SELECT variant, count(*) AS clicks FROM clicks WHERE url_id = $1 GROUP BY variant) c
+			  FULL JOIN (SELECT variant, count(*) AS conversions FROM conversions WHERE url_id = $1 GROUP BY variant) v
+			  ON v.variant = c.variant
+			  ORDER BY 1`
+
+	var stats []entity.VariantStats
+
+	rows, err := cl.session.Query(ctx, query, urlID)
+	if err != nil {
+		log.Err(err).Int("url_id", urlID).Msg("failed to fetch variant stats")
+		return nil, fmt.Errorf("failed to fetch variant stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s entity.VariantStats
+		if err := rows.Scan(&s.Variant, &s.Clicks, &s.Conversions); err != nil {
+			log.Err(err).Msg("failed to scan variant stats row")
+			return nil, fmt.Errorf("failed to scan variant stats row: %w", err)
+		}
+		if s.Clicks > 0 {
+			s.ConversionRate = float64(s.Conversions) / float64(s.Clicks)
+		}
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate variant stats rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return stats, nil
+}
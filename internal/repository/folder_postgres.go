@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ Folder = &FolderPostgresRepository{}
+
+type FolderPostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewFolderPostgresRepository(session *pgxpool.Pool) *FolderPostgresRepository {
+	return &FolderPostgresRepository{
+		session: session,
+	}
+}
+
+const folderColumns = `id, user_id, parent_id, name, created_at`
+
+func scanFolder(row pgx.Row) (entity.Folder, error) {
+	var f entity.Folder
+	err := row.Scan(&f.ID, &f.UserID, &f.ParentID, &f.Name, &f.CreatedAt)
+	return f, err
+}
+
+func (f *FolderPostgresRepository) ByID(ctx context.Context, ID int) (entity.Folder, error) {
+	query := `SELECT ` + folderColumns + `
+			  FROM folders
+			  WHERE id = $1`
+
+	folder, err := scanFolder(f.session.QueryRow(ctx, query, ID))
+	if err != nil {
+		log.Err(err).Int("id", ID).Msg("failed to fetch folder by id")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Folder{}, fmt.Errorf("folder not found: %w", pgx.ErrNoRows)
+		}
+		return entity.Folder{}, fmt.Errorf("failed to fetch folder by id: %w", err)
+	}
+
+	return folder, nil
+}
+
+func (f *FolderPostgresRepository) ByUserID(ctx context.Context, userID int) ([]entity.Folder, error) {
+	query := `SELECT ` + folderColumns + `
+			  FROM folders
+			  WHERE user_id = $1
+			  ORDER BY name`
+
+	var folders []entity.Folder
+
+	rows, err := f.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch folders by user id")
+		return nil, fmt.Errorf("failed to fetch folders by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan folder row")
+			return nil, fmt.Errorf("failed to scan folder row: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate folder rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return folders, nil
+}
+
+// AncestorIDs returns the id of the folder followed by the ids of the
+// folders it is nested in, innermost first. The walk stops one level past
+// entity.MaxFolderDepth so a cycle left by concurrent moves can not loop.
+func (f *FolderPostgresRepository) AncestorIDs(ctx context.Context, ID int) ([]int, error) {
+	query := `WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id, 0 AS depth FROM folders WHERE id = $1
+				UNION ALL
+				SELECT f.id, f.parent_id, a.depth + 1 FROM folders f JOIN ancestors a ON f.id = a.parent_id
+				WHERE a.depth < $2
+			  )
+			  SELECT id FROM ancestors ORDER BY depth`
+
+	var ids []int
+
+	rows, err := f.session.Query(ctx, query, ID, entity.MaxFolderDepth+1)
+	if err != nil {
+		log.Err(err).Int("id", ID).Msg("failed to fetch folder ancestors")
+		return nil, fmt.Errorf("failed to fetch folder ancestors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			log.Err(err).Msg("failed to scan folder ancestor row")
+			return nil, fmt.Errorf("failed to scan folder ancestor row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate folder ancestor rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return ids, nil
+}
+
+// SubtreeHeight returns how many levels of subfolders sit below the folder,
+// 0 when it has none. Like AncestorIDs the walk is bounded.
+func (f *FolderPostgresRepository) SubtreeHeight(ctx context.Context, ID int) (int, error) {
+	query := `WITH RECURSIVE descendants AS (
+				SELECT id, 0 AS depth FROM folders WHERE id = $1
+				UNION ALL
+				SELECT f.id, d.depth + 1 FROM folders f JOIN descendants d ON f.parent_id = d.id
+				WHERE d.depth < $2
+			  )
+			  SELECT COALESCE(MAX(depth), 0) FROM descendants`
+
+	var height int
+	if err := f.session.QueryRow(ctx, query, ID, entity.MaxFolderDepth+1).Scan(&height); err != nil {
+		log.Err(err).Int("id", ID).Msg("failed to fetch folder subtree height")
+		return 0, fmt.Errorf("failed to fetch folder subtree height: %w", err)
+	}
+
+	return height, nil
+}
+
+func (f *FolderPostgresRepository) Save(ctx context.Context, folder entity.Folder) (entity.Folder, error) {
+	query := `INSERT INTO folders (user_id, parent_id, name)
+			  VALUES ($1, $2, $3)
+			  RETURNING ` + folderColumns
+
+	saved, err := scanFolder(f.session.QueryRow(ctx, query, folder.UserID, folder.ParentID, folder.Name))
+	if err != nil {
+		log.Err(err).Interface("folder", folder).Msg("failed to create folder")
+		return entity.Folder{}, fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	return saved, nil
+}
+
+func (f *FolderPostgresRepository) Update(ctx context.Context, folder entity.Folder) error {
+	query := `UPDATE folders
+			  SET parent_id = $1, name = $2
+			  WHERE id = $3`
+
+	_, err := f.session.Exec(ctx, query, folder.ParentID, folder.Name, folder.ID)
+	if err != nil {
+		log.Err(err).Interface("folder", folder).Msg("failed to update folder")
+		return fmt.Errorf("failed to update folder: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the folder and moves its links and subfolders up into its
+// parent.
+func (f *FolderPostgresRepository) Delete(ctx context.Context, folder entity.Folder) error {
+	tx, err := f.session.Begin(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to start transcation on deleting folder")
+		return fmt.Errorf("failed to start transcation on deleting folder: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE urls SET folder_id = $1 WHERE folder_id = $2`, folder.ParentID, folder.ID)
+	if err != nil {
+		log.Err(err).Interface("folder", folder).Msg("failed to move folder urls")
+		return fmt.Errorf("failed to move folder urls: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE folders SET parent_id = $1 WHERE parent_id = $2`, folder.ParentID, folder.ID)
+	if err != nil {
+		log.Err(err).Interface("folder", folder).Msg("failed to move subfolders")
+		return fmt.Errorf("failed to move subfolders: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM folders WHERE id = $1`, folder.ID)
+	if err != nil {
+		log.Err(err).Interface("folder", folder).Msg("failed to delete folder")
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to commit delete folder transcation")
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ Identity = &IdentityPostgresRepository{}
+
+type IdentityPostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewIdentityPostgresRepository(session *pgxpool.Pool) *IdentityPostgresRepository {
+	return &IdentityPostgresRepository{
+		session: session,
+	}
+}
+
+const identityColumns = `id, user_id, provider, subject, email, created_at`
+
+func scanIdentity(row pgx.Row) (entity.Identity, error) {
+	var i entity.Identity
+	err := row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt)
+	return i, err
+}
+
+func (i *IdentityPostgresRepository) ByProviderSubject(ctx context.Context, provider, subject string) (entity.Identity, error) {
+	query := `SELECT ` + identityColumns + `
+			  FROM user_identities
+			  WHERE provider = $1 AND subject = $2`
+
+	identity, err := scanIdentity(i.session.QueryRow(ctx, query, provider, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Identity{}, fmt.Errorf("identity not found: %w", pgx.ErrNoRows)
+		}
+		log.Err(err).Str("provider", provider).Msg("failed to fetch identity")
+		return entity.Identity{}, fmt.Errorf("failed to fetch identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+func (i *IdentityPostgresRepository) ByUserID(ctx context.Context, userID int) ([]entity.Identity, error) {
+	query := `SELECT ` + identityColumns + `
+			  FROM user_identities
+			  WHERE user_id = $1
+			  ORDER BY id`
+
+	var identities []entity.Identity
+
+	rows, err := i.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch identities")
+		return nil, fmt.Errorf("failed to fetch identities: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan identity row")
+			return nil, fmt.Errorf("failed to scan identity row: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate identity rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return identities, nil
+}
+
+func (i *IdentityPostgresRepository) Save(ctx context.Context, identity entity.Identity) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email)
+			  VALUES ($1, $2, $3, $4)`
+
+	_, err := i.session.Exec(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		log.Err(err).Int("user_id", identity.UserID).Str("provider", identity.Provider).Msg("failed to save identity")
+		return fmt.Errorf("failed to save identity: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/rueidis"
+	"github.com/rs/zerolog/log"
+)
+
+var _ QRRedis = &QRRedisRepository{}
+
+type QRRedisRepository struct {
+	client rueidis.Client
+}
+
+func NewQRRedisRepository(redisClient rueidis.Client) *QRRedisRepository {
+	return &QRRedisRepository{client: redisClient}
+}
+
+func (q *QRRedisRepository) Get(ctx context.Context, key string) ([]byte, error) {
+	cmd := q.client.B().Get().Key("qr:" + key).Build()
+
+	image, err := q.client.Do(ctx, cmd).AsBytes()
+	if err != nil {
+		if rueidis.IsRedisNil(err) {
+			return nil, fmt.Errorf("qr code not found")
+		}
+		log.Err(err).Str("key", key).Msg("failed to fetch qr code from redis")
+		return nil, fmt.Errorf("failed to fetch qr code from redis: %w", err)
+	}
+
+	return image, nil
+}
+
+func (q *QRRedisRepository) Save(ctx context.Context, key string, image []byte, ttl time.Duration) error {
+	cmd := q.client.B().Set().Key("qr:" + key).Value(rueidis.BinaryString(image)).Px(ttl).Build()
+
+	if err := q.client.Do(ctx, cmd).Error(); err != nil {
+		log.Err(err).Str("key", key).Msg("failed to set qr code in redis")
+		return fmt.Errorf("failed to set qr code in redis: %w", err)
+	}
+
+	return nil
+}
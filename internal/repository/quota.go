@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/rueidis"
+	"github.com/rs/zerolog/log"
+)
+
+var _ Quota = &QuotaRepository{}
+
+type QuotaRepository struct {
+	client rueidis.Client
+}
+
+func NewQuotaRepository(redisClient rueidis.Client) *QuotaRepository {
+	return &QuotaRepository{client: redisClient}
+}
+
+// Reserve takes n units from the counter at key if that keeps it within
+// limit, and returns how many units are left.
+func (q *QuotaRepository) Reserve(ctx context.Context, key string, n, limit int, ttl time.Duration) (bool, int, error) {
+	key = "quota:" + key
+
+	resp := q.client.DoMulti(ctx,
+		q.client.B().Incrby().Key(key).Increment(int64(n)).Build(),
+		q.client.B().Expire().Key(key).Seconds(int64(ttl.Seconds())).Nx().Build(),
+	)
+	used, err := resp[0].AsInt64()
+	if err != nil {
+		log.Err(err).Str("key", key).Msg("failed to reserve quota")
+		return false, 0, fmt.Errorf("failed to reserve quota: %w", err)
+	}
+
+	if used > int64(limit) {
+		q.client.Do(ctx, q.client.B().Decrby().Key(key).Decrement(int64(n)).Build())
+		return false, max(limit-int(used)+n, 0), nil
+	}
+
+	return true, limit - int(used), nil
+}
+
+// Release gives back n units taken by Reserve that ended up unused.
+func (q *QuotaRepository) Release(ctx context.Context, key string, n int) error {
+	key = "quota:" + key
+
+	if err := q.client.Do(ctx, q.client.B().Decrby().Key(key).Decrement(int64(n)).Build()).Error(); err != nil {
+		log.Err(err).Str("key", key).Msg("failed to release quota")
+		return fmt.Errorf("failed to release quota: %w", err)
+	}
+
+	return nil
+}
@@ -20,8 +20,10 @@ func NewRateLimiterRepository(redisClient rueidis.Client) *RateLimitRepository {
 	return &RateLimitRepository{client: redisClient}
 }
 
-func (r *RateLimitRepository) IsAllowed(ctx context.Context, ip string, limit int, window time.Duration) (bool, int, time.Time, error) {
-	key := fmt.Sprintf("ratelimit:ip:%s", ip)
+// IsAllowed records a hit for subject within scope (e.g. "ip", "1.2.3.4")
+// and reports whether it stays within limit hits per window.
+func (r *RateLimitRepository) IsAllowed(ctx context.Context, scope, subject string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	key := fmt.Sprintf("ratelimit:%s:%s", scope, subject)
 	now := time.Now()
 	windowStart := now.Add(-window)
 
@@ -35,7 +37,7 @@ func (r *RateLimitRepository) IsAllowed(ctx context.Context, ip string, limit in
 		r.client.B().Zadd().
 			Key(key).
 			ScoreMember().
-			ScoreMember(float64(now.Unix()), strconv.FormatInt(now.Unix(), 10)).
+			ScoreMember(float64(now.Unix()), strconv.FormatInt(now.UnixNano(), 10)).
 			Build(),
 
 		r.client.B().Zcard().
@@ -14,15 +14,86 @@ type Account interface {
 	UpdateEmail(ctx context.Context, user entity.User) error
 	UpdatePassword(ctx context.Context, user entity.User) error
 	UpdateVerifyEmail(ctx context.Context, user entity.User) error
+	ScheduleDeletion(ctx context.Context, user entity.User) error
+	DueForDeletion(ctx context.Context, t time.Time) ([]entity.User, error)
+}
+
+type Identity interface {
+	ByProviderSubject(ctx context.Context, provider, subject string) (entity.Identity, error)
+	ByUserID(ctx context.Context, userID int) ([]entity.Identity, error)
+	Save(ctx context.Context, identity entity.Identity) error
 }
 
 type URL interface {
 	ByID(ctx context.Context, ID int) (entity.URL, error)
 	ByShortURL(ctx context.Context, shortURL string) (entity.URL, error)
 	ByUserID(ctx context.Context, userID int) ([]entity.URL, error)
+	ByFilter(ctx context.Context, filter entity.URLFilter) ([]entity.URL, error)
 	Save(ctx context.Context, url entity.URL) error
+	SaveBatch(ctx context.Context, urls []entity.URL) ([]bool, error)
+	Update(ctx context.Context, url entity.URL) error
+	UpdateScreening(ctx context.Context, shortURL, screening string) error
+	UpdateForcePreview(ctx context.Context, shortURL string, forcePreview bool) error
 	UpdateClickCount(ctx context.Context, shortURL string) error
+	PendingMetadata(ctx context.Context, limit int) ([]entity.URL, error)
+	UpdateMetadata(ctx context.Context, ID int, title, description, status string) error
 	Delete(ctx context.Context, url entity.URL) error
+	DeletedByShortURL(ctx context.Context, shortURL string) (entity.URL, error)
+	DeletedByUserID(ctx context.Context, userID int) ([]entity.URL, error)
+	Restore(ctx context.Context, url entity.URL) error
+	Purge(ctx context.Context, url entity.URL) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	Flagged(ctx context.Context) ([]entity.URL, error)
+	ListAfterID(ctx context.Context, afterID, limit int) ([]entity.URL, error)
+	EachByUserID(ctx context.Context, userID int, fn func(url entity.URL) error) error
+}
+
+type UTMTemplate interface {
+	ByID(ctx context.Context, ID int) (entity.UTMTemplate, error)
+	ByUserID(ctx context.Context, userID int) ([]entity.UTMTemplate, error)
+	Save(ctx context.Context, template entity.UTMTemplate) (entity.UTMTemplate, error)
+	Update(ctx context.Context, template entity.UTMTemplate) error
+	Delete(ctx context.Context, template entity.UTMTemplate) error
+}
+
+type Tag interface {
+	ByID(ctx context.Context, ID int) (entity.Tag, error)
+	ByUserID(ctx context.Context, userID int) ([]entity.Tag, error)
+	ByURLIDs(ctx context.Context, urlIDs []int) (map[int][]entity.Tag, error)
+	Save(ctx context.Context, tag entity.Tag) (entity.Tag, error)
+	Update(ctx context.Context, tag entity.Tag) error
+	Delete(ctx context.Context, tag entity.Tag) error
+	SetURLTags(ctx context.Context, urlID int, tagIDs []int) error
+	Retag(ctx context.Context, userID int, shortURLs []string, add, remove []int) (int, error)
+	Stats(ctx context.Context, userID int) ([]entity.TagStats, error)
+}
+
+type Folder interface {
+	ByID(ctx context.Context, ID int) (entity.Folder, error)
+	ByUserID(ctx context.Context, userID int) ([]entity.Folder, error)
+	AncestorIDs(ctx context.Context, ID int) ([]int, error)
+	SubtreeHeight(ctx context.Context, ID int) (int, error)
+	Save(ctx context.Context, folder entity.Folder) (entity.Folder, error)
+	Update(ctx context.Context, folder entity.Folder) error
+	Delete(ctx context.Context, folder entity.Folder) error
+}
+
+type URLHistory interface {
+	Save(ctx context.Context, entries []entity.URLHistory) error
+	ByURLID(ctx context.Context, urlID int) ([]entity.URLHistory, error)
+	ByVersion(ctx context.Context, urlID, version int) (entity.URLHistory, error)
+}
+
+type Audit interface {
+	Save(ctx context.Context, event entity.AuditEvent) error
+	ByFilter(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error)
+}
+
+type Click interface {
+	Save(ctx context.Context, click entity.Click) error
+	GroupBy(ctx context.Context, filter entity.ClickFilter, dimension string) ([]entity.ClickGroup, error)
+	SaveConversion(ctx context.Context, conversion entity.Conversion) error
+	VariantStats(ctx context.Context, urlID int) ([]entity.VariantStats, error)
 }
 
 type AccountRedis interface {
@@ -33,13 +104,41 @@ type AccountRedis interface {
 	ByResetEmail(ctx context.Context, token string) (string, error)
 	ByResetToken(ctx context.Context, email string) (string, error)
 	SaveReset(ctx context.Context, email, token string, ttl time.Duration) error
+
+	ByEmailChangeUser(ctx context.Context, userID int) (string, error)
+	ByEmailChangeToken(ctx context.Context, token string) (int, string, error)
+	SaveEmailChange(ctx context.Context, userID int, email, token string, ttl time.Duration) error
+
+	ByDeletionUser(ctx context.Context, userID int) (string, error)
+	ByDeletionToken(ctx context.Context, token string) (int, error)
+	SaveDeletion(ctx context.Context, userID int, token string, ttl time.Duration) error
+
+	ByOIDCState(ctx context.Context, state string) (entity.OIDCState, error)
+	SaveOIDCState(ctx context.Context, state string, value entity.OIDCState, ttl time.Duration) error
+
+	ByMagicLinkToken(ctx context.Context, token string) (string, error)
+	SaveMagicLink(ctx context.Context, email, token string, ttl time.Duration) error
+
+	RevokeTokens(ctx context.Context, userID int, at time.Time, ttl time.Duration) error
+	TokensRevokedAt(ctx context.Context, userID int) (time.Time, error)
 }
 
 type URLRedis interface {
 	ByShortURL(ctx context.Context, shortURL string) (entity.URL, error)
-	Save(ctx context.Context, url entity.URL) error
+	Save(ctx context.Context, url entity.URL, ttl time.Duration) error
+	Delete(ctx context.Context, shortURL string) error
+}
+
+type QRRedis interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Save(ctx context.Context, key string, image []byte, ttl time.Duration) error
+}
+
+type Quota interface {
+	Reserve(ctx context.Context, key string, n, limit int, ttl time.Duration) (bool, int, error)
+	Release(ctx context.Context, key string, n int) error
 }
 
 type RateLimiter interface {
-	IsAllowed(ctx context.Context, ip string, limit int, window time.Duration) (bool, int, time.Time, error)
+	IsAllowed(ctx context.Context, scope, subject string, limit int, window time.Duration) (bool, int, time.Time, error)
 }
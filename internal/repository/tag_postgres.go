@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ Tag = &TagPostgresRepository{}
+
+type TagPostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewTagPostgresRepository(session *pgxpool.Pool) *TagPostgresRepository {
+	return &TagPostgresRepository{
+		session: session,
+	}
+}
+
+const tagColumns = `id, user_id, name, color, created_at`
+
+func scanTag(row pgx.Row) (entity.Tag, error) {
+	var t entity.Tag
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.CreatedAt)
+	return t, err
+}
+
+func (t *TagPostgresRepository) ByID(ctx context.Context, ID int) (entity.Tag, error) {
+	query := `SELECT ` + tagColumns + `
+			  FROM tags
+			  WHERE id = $1`
+
+	tag, err := scanTag(t.session.QueryRow(ctx, query, ID))
+	if err != nil {
+		log.Err(err).Int("id", ID).Msg("failed to fetch tag by id")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Tag{}, fmt.Errorf("tag not found: %w", pgx.ErrNoRows)
+		}
+		return entity.Tag{}, fmt.Errorf("failed to fetch tag by id: %w", err)
+	}
+
+	return tag, nil
+}
+
+func (t *TagPostgresRepository) ByUserID(ctx context.Context, userID int) ([]entity.Tag, error) {
+	query := `SELECT ` + tagColumns + `
+			  FROM tags
+			  WHERE user_id = $1
+			  ORDER BY name`
+
+	var tags []entity.Tag
+
+	rows, err := t.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch tags by user id")
+		return nil, fmt.Errorf("failed to fetch tags by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan tag row")
+			return nil, fmt.Errorf("failed to scan tag row: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate tag rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return tags, nil
+}
+
+// ByURLIDs returns the tags of each of the urls, keyed by url id.
+func (t *TagPostgresRepository) ByURLIDs(ctx context.Context, urlIDs []int) (map[int][]entity.Tag, error) {
+	query := `SELECT ut.url_id, t.id, t.user_id, t.name, t.color, t.created_at
+			  FROM url_tags ut
+			  JOIN tags t ON t.id = ut.tag_id
+			  WHERE ut.url_id = ANY($1)
+			  ORDER BY t.name`
+
+	tags := map[int][]entity.Tag{}
+
+	rows, err := t.session.Query(ctx, query, urlIDs)
+	if err != nil {
+		log.Err(err).Ints("url_ids", urlIDs).Msg("failed to fetch tags by url ids")
+		return nil, fmt.Errorf("failed to fetch tags by url ids: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var urlID int
+		var tag entity.Tag
+		if err := rows.Scan(&urlID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			log.Err(err).Msg("failed to scan url tag row")
+			return nil, fmt.Errorf("failed to scan url tag row: %w", err)
+		}
+		tags[urlID] = append(tags[urlID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url tag rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (t *TagPostgresRepository) Save(ctx context.Context, tag entity.Tag) (entity.Tag, error) {
+	query := `INSERT INTO tags (user_id, name, color)
+			  VALUES ($1, $2, $3)
+			  RETURNING ` + tagColumns
+
+	saved, err := scanTag(t.session.QueryRow(ctx, query, tag.UserID, tag.Name, tag.Color))
+	if err != nil {
+		log.Err(err).Interface("tag", tag).Msg("failed to create tag")
+		return entity.Tag{}, fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	return saved, nil
+}
+
+func (t *TagPostgresRepository) Update(ctx context.Context, tag entity.Tag) error {
+	query := `UPDATE tags
+			  SET name = $1, color = $2
+			  WHERE id = $3`
+
+	_, err := t.session.Exec(ctx, query, tag.Name, tag.Color, tag.ID)
+	if err != nil {
+		log.Err(err).Interface("tag", tag).Msg("failed to update tag")
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TagPostgresRepository) Delete(ctx context.Context, tag entity.Tag) error {
+	query := `DELETE FROM tags
+			  WHERE id = $1`
+
+	_, err := t.session.Exec(ctx, query, tag.ID)
+	if err != nil {
+		log.Err(err).Interface("tag", tag).Msg("failed to delete tag")
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	return nil
+}
+
+// SetURLTags replaces the tags of a url with tagIDs.
+func (t *TagPostgresRepository) SetURLTags(ctx context.Context, urlID int, tagIDs []int) error {
+	tx, err := t.session.Begin(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to start transcation on setting url tags")
+		return fmt.Errorf("failed to start transcation on setting url tags: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM url_tags WHERE url_id = $1`, urlID)
+	if err != nil {
+		log.Err(err).Int("url_id", urlID).Msg("failed to clear url tags")
+		return fmt.Errorf("failed to clear url tags: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO url_tags (url_id, tag_id)
+			  SELECT $1, unnest($2::int[])
+			  ON CONFLICT DO NOTHING`, urlID, tagIDs)
+	if err != nil {
+		log.Err(err).Int("url_id", urlID).Ints("tag_ids", tagIDs).Msg("failed to set url tags")
+		return fmt.Errorf("failed to set url tags: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to commit set url tags transcation")
+		return fmt.Errorf("failed to set url tags: %w", err)
+	}
+
+	return nil
+}
+
+// Retag adds and removes tags on those of shortURLs that belong to userID
+// and returns how many urls matched.
+func (t *TagPostgresRepository) Retag(ctx context.Context, userID int, shortURLs []string, add, remove []int) (int, error) {
+	tx, err := t.session.Begin(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to start transcation on retagging urls")
+		return 0, fmt.Errorf("failed to start transcation on retagging urls: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var matched int
+	err = tx.QueryRow(ctx, `SELECT count(*) FROM urls WHERE user_id = $1 AND short_url = ANY($2) AND deleted_at IS NULL`, userID, shortURLs).Scan(&matched)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to count urls to retag")
+		return 0, fmt.Errorf("failed to count urls to retag: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM url_tags
+			  WHERE tag_id = ANY($3)
+			  AND url_id IN (SELECT id FROM urls WHERE user_id = $1 AND short_url = ANY($2) AND deleted_at IS NULL)`, userID, shortURLs, remove)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Ints("tag_ids", remove).Msg("failed to remove url tags")
+		return 0, fmt.Errorf("failed to remove url tags: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO url_tags (url_id, tag_id)
+			  SELECT u.id, t.id
+			  FROM urls u CROSS JOIN unnest($3::int[]) AS t(id)
+			  WHERE u.user_id = $1 AND u.short_url = ANY($2) AND u.deleted_at IS NULL
+			  ON CONFLICT DO NOTHING`, userID, shortURLs, add)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Ints("tag_ids", add).Msg("failed to add url tags")
+		return 0, fmt.Errorf("failed to add url tags: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to commit retag urls transcation")
+		return 0, fmt.Errorf("failed to retag urls: %w", err)
+	}
+
+	return matched, nil
+}
+
+// Stats returns how many links carry each of the user's tags and how often
+// those links were clicked.
+func (t *TagPostgresRepository) Stats(ctx context.Context, userID int) ([]entity.TagStats, error) {
+	query := `SELECT t.id, t.name, count(u.id), coalesce(sum(u.click_count), 0)
+			  FROM tags t
+			  LEFT JOIN url_tags ut ON ut.tag_id = t.id
+			  LEFT JOIN urls u ON u.id = ut.url_id AND u.deleted_at IS NULL
+			  WHERE t.user_id = $1
+			  GROUP BY t.id, t.name
+			  ORDER BY 4 DESC, t.name`
+
+	var stats []entity.TagStats
+
+	rows, err := t.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch tag stats")
+		return nil, fmt.Errorf("failed to fetch tag stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s entity.TagStats
+		if err := rows.Scan(&s.TagID, &s.Name, &s.Links, &s.Clicks); err != nil {
+			log.Err(err).Msg("failed to scan tag stats row")
+			return nil, fmt.Errorf("failed to scan tag stats row: %w", err)
+		}
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate tag stats rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ URLHistory = &URLHistoryPostgresRepository{}
+
+type URLHistoryPostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewURLHistoryPostgresRepository(session *pgxpool.Pool) *URLHistoryPostgresRepository {
+	return &URLHistoryPostgresRepository{
+		session: session,
+	}
+}
+
+const urlHistoryColumns = `id, url_id, version, action, actor_id, request_id, changes, snapshot, created_at`
+
+func scanURLHistory(row pgx.Row) (entity.URLHistory, error) {
+	var h entity.URLHistory
+	err := row.Scan(&h.ID, &h.URLID, &h.Version, &h.Action, &h.ActorID, &h.RequestID, &h.Changes, &h.Snapshot, &h.CreatedAt)
+	return h, err
+}
+
+// Save appends entries in a single round trip, each one getting the next
+// version of its url.
+func (h *URLHistoryPostgresRepository) Save(ctx context.Context, entries []entity.URLHistory) error {
+	query := `INSERT INTO url_history (url_id, version, action, actor_id, request_id, changes, snapshot)
+			  VALUES ($1, (SELECT coalesce(max(version), 0) + 1 FROM url_history WHERE url_id = $1), $2, $3, $4, $5, $6)`
+
+	batch := &pgx.Batch{}
+	for _, entry := range entries {
+		batch.Queue(query, entry.URLID, entry.Action, entry.ActorID, entry.RequestID, entry.Changes, entry.Snapshot)
+	}
+
+	results := h.session.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, entry := range entries {
+		if _, err := results.Exec(); err != nil {
+			log.Err(err).Int("url_id", entry.URLID).Str("action", entry.Action).Msg("failed to save url history")
+			return fmt.Errorf("failed to save url history: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *URLHistoryPostgresRepository) ByURLID(ctx context.Context, urlID int) ([]entity.URLHistory, error) {
+	query := `SELECT ` + urlHistoryColumns + `
+			  FROM url_history
+			  WHERE url_id = $1
+			  ORDER BY version DESC`
+
+	var history []entity.URLHistory
+
+	rows, err := h.session.Query(ctx, query, urlID)
+	if err != nil {
+		log.Err(err).Int("url_id", urlID).Msg("failed to fetch url history")
+		return nil, fmt.Errorf("failed to fetch url history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanURLHistory(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url history row")
+			return nil, fmt.Errorf("failed to scan url history row: %w", err)
+		}
+		history = append(history, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url history rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return history, nil
+}
+
+func (h *URLHistoryPostgresRepository) ByVersion(ctx context.Context, urlID, version int) (entity.URLHistory, error) {
+	query := `SELECT ` + urlHistoryColumns + `
+			  FROM url_history
+			  WHERE url_id = $1 AND version = $2`
+
+	entry, err := scanURLHistory(h.session.QueryRow(ctx, query, urlID, version))
+	if err != nil {
+		log.Err(err).Int("url_id", urlID).Int("version", version).Msg("failed to fetch url history version")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.URLHistory{}, fmt.Errorf("url history version not found: %w", pgx.ErrNoRows)
+		}
+		return entity.URLHistory{}, fmt.Errorf("failed to fetch url history version: %w", err)
+	}
+
+	return entry, nil
+}
@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"kuchak/internal/entity"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/rs/zerolog/log"
@@ -22,13 +25,25 @@ func NewURLPostgresRepository(session *pgxpool.Pool) *URLPostgresRepository {
 	}
 }
 
+const urlColumns = `id, short_url, original_url, title, description, notes, metadata_status, user_id, click_count, screening, preview, force_preview, redirect_status,
+	forward_query, query_conflict, forward_path, utm, device_rules, geo_rules,
+	variants, sticky_variants, active_from, schedule, password_hash, folder_id, deleted_at, created_at`
+
+func scanURL(row pgx.Row) (entity.URL, error) {
+	var url entity.URL
+	err := row.Scan(&url.ID, &url.ShortURL, &url.OriginalURL, &url.Title, &url.Description, &url.Notes, &url.MetadataStatus, &url.UserID, &url.ClickCount, &url.Screening, &url.Preview, &url.ForcePreview, &url.RedirectStatus,
+		&url.ForwardQuery, &url.QueryConflict, &url.ForwardPath, &url.UTM, &url.DeviceRules, &url.GeoRules,
+		&url.Variants, &url.StickyVariants, &url.ActiveFrom, &url.Schedule, &url.PasswordHash, &url.FolderID, &url.DeletedAt, &url.CreatedAt)
+	url.Protected = url.PasswordHash != ""
+	return url, err
+}
+
 func (u *URLPostgresRepository) ByID(ctx context.Context, ID int) (entity.URL, error) {
-	query := `SELECT id, short_url, original_url, user_id, click_count, created_at
+	query := `SELECT ` + urlColumns + `
 			  FROM urls
-			  WHERE id = $1`
+			  WHERE id = $1 AND deleted_at IS NULL`
 
-	var url entity.URL
-	err := u.session.QueryRow(ctx, query, ID).Scan(&url.ID, &url.ShortURL, &url.OriginalURL, &url.UserID, &url.ClickCount, &url.CreatedAt)
+	url, err := scanURL(u.session.QueryRow(ctx, query, ID))
 	if err != nil {
 		log.Err(err).Int("id", ID).Msg("failed to fetch url by id")
 		return entity.URL{}, fmt.Errorf("failed to fetch url by id: %w", err)
@@ -38,12 +53,11 @@ func (u *URLPostgresRepository) ByID(ctx context.Context, ID int) (entity.URL, e
 }
 
 func (u *URLPostgresRepository) ByShortURL(ctx context.Context, shortURL string) (entity.URL, error) {
-	query := `SELECT id, short_url, original_url, user_id, click_count, created_at
+	query := `SELECT ` + urlColumns + `
 			  FROM urls
-			  WHERE short_url = $1`
+			  WHERE short_url = $1 AND deleted_at IS NULL`
 
-	var url entity.URL
-	err := u.session.QueryRow(ctx, query, shortURL).Scan(&url.ID, &url.ShortURL, &url.OriginalURL, &url.UserID, &url.ClickCount, &url.CreatedAt)
+	url, err := scanURL(u.session.QueryRow(ctx, query, shortURL))
 	if err != nil {
 		log.Err(err).Str("short_url", shortURL).Msg("failed to fetch url by short_url")
 		return entity.URL{}, fmt.Errorf("failed to fetch url by short_url: %w", err)
@@ -53,9 +67,9 @@ func (u *URLPostgresRepository) ByShortURL(ctx context.Context, shortURL string)
 }
 
 func (u *URLPostgresRepository) ByUserID(ctx context.Context, userID int) ([]entity.URL, error) {
-	query := `SELECT id, short_url, original_url, user_id, click_count, created_at
+	query := `SELECT ` + urlColumns + `
 			  FROM urls
-			  WHERE user_id = $1`
+			  WHERE user_id = $1 AND deleted_at IS NULL`
 
 	var urls []entity.URL
 
@@ -67,8 +81,8 @@ func (u *URLPostgresRepository) ByUserID(ctx context.Context, userID int) ([]ent
 	defer rows.Close()
 
 	for rows.Next() {
-		var url entity.URL
-		if err := rows.Scan(&url.ID, &url.ShortURL, &url.OriginalURL, &url.UserID, &url.ClickCount, &url.CreatedAt); err != nil {
+		url, err := scanURL(rows)
+		if err != nil {
 			log.Err(err).Msg("failed to scan url row")
 			return nil, fmt.Errorf("failed to scan url row: %w", err)
 		}
@@ -83,11 +97,67 @@ func (u *URLPostgresRepository) ByUserID(ctx context.Context, userID int) ([]ent
 	return urls, nil
 }
 
-func (u *URLPostgresRepository) Save(ctx context.Context, url entity.URL) error {
-	query := `INSERT INTO urls (short_url, original_url, user_id)
-			  VALUES ($1, $2, $3)
+// ByFilter returns the urls of filter.UserID that are in the requested
+// folder and carry all of the requested tags.
+func (u *URLPostgresRepository) ByFilter(ctx context.Context, filter entity.URLFilter) ([]entity.URL, error) {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE user_id = $1 AND deleted_at IS NULL
+			  AND ($2 = 0 OR folder_id = $2 OR ($3 AND folder_id IN (
+				WITH RECURSIVE nested AS (
+					SELECT id FROM folders WHERE parent_id = $2
+					UNION ALL
+					SELECT f.id FROM folders f JOIN nested n ON f.parent_id = n.id
+				)
+				SELECT id FROM nested
+			  )))
+			  AND (coalesce(cardinality($4::int[]), 0) = 0 OR id IN (
+				SELECT url_id FROM url_tags
+				WHERE tag_id = ANY($4)
+				GROUP BY url_id
+				HAVING count(*) = cardinality($4::int[])
+			  ))
+			  ORDER BY id`
+
+	var urls []entity.URL
+
+	rows, err := u.session.Query(ctx, query, filter.UserID, filter.FolderID, filter.Subfolders, filter.TagIDs)
+	if err != nil {
+		log.Err(err).Interface("filter", filter).Msg("failed to fetch urls by filter")
+		return nil, fmt.Errorf("failed to fetch urls by filter: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url row")
+			return nil, fmt.Errorf("failed to scan url row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+const insertURLQuery = `INSERT INTO urls (short_url, original_url, user_id, screening, preview, redirect_status,
+			  forward_query, query_conflict, forward_path, utm, device_rules, geo_rules, variants, sticky_variants,
+			  active_from, schedule, password_hash, folder_id, title, description, notes, metadata_status, force_preview)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 			  ON CONFLICT (short_url) DO NOTHING`
 
+func insertURLArgs(url entity.URL) []any {
+	return []any{url.ShortURL, url.OriginalURL, url.UserID, url.Screening, url.Preview, url.RedirectStatus,
+		url.ForwardQuery, url.QueryConflict, url.ForwardPath, url.UTM, url.DeviceRules, url.GeoRules, url.Variants, url.StickyVariants,
+		url.ActiveFrom, url.Schedule, url.PasswordHash, url.FolderID, url.Title, url.Description, url.Notes, url.MetadataStatus, url.ForcePreview}
+}
+
+func (u *URLPostgresRepository) Save(ctx context.Context, url entity.URL) error {
 	tx, err := u.session.Begin(ctx)
 	if err != nil {
 		log.Err(err).Msg("failed to start transcation on creating url")
@@ -96,7 +166,7 @@ func (u *URLPostgresRepository) Save(ctx context.Context, url entity.URL) error
 
 	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx, query, url.ShortURL, url.OriginalURL, url.UserID)
+	_, err = tx.Exec(ctx, insertURLQuery, insertURLArgs(url)...)
 	if err != nil {
 		log.Err(err).Interface("url", url).Msg("failed to create url")
 		return fmt.Errorf("failed to create url: %w", err)
@@ -111,9 +181,90 @@ func (u *URLPostgresRepository) Save(ctx context.Context, url entity.URL) error
 	return nil
 }
 
+// SaveBatch inserts urls in a single round trip. It fills in the id and
+// creation time of the inserted ones and reports, per url, whether it was
+// inserted or its short url was already taken.
+func (u *URLPostgresRepository) SaveBatch(ctx context.Context, urls []entity.URL) ([]bool, error) {
+	query := insertURLQuery + `
+			  RETURNING id, created_at`
+
+	batch := &pgx.Batch{}
+	for _, url := range urls {
+		batch.Queue(query, insertURLArgs(url)...)
+	}
+
+	results := u.session.SendBatch(ctx, batch)
+	defer results.Close()
+
+	inserted := make([]bool, len(urls))
+	for i := range urls {
+		err := results.QueryRow().Scan(&urls[i].ID, &urls[i].CreatedAt)
+		switch {
+		case err == nil:
+			inserted[i] = true
+		case errors.Is(err, pgx.ErrNoRows):
+		default:
+			log.Err(err).Interface("url", urls[i]).Msg("failed to create url in batch")
+			return nil, fmt.Errorf("failed to create url in batch: %w", err)
+		}
+	}
+
+	return inserted, nil
+}
+
+func (u *URLPostgresRepository) Update(ctx context.Context, url entity.URL) error {
+	query := `UPDATE urls
+			  SET original_url = $1, screening = $2, preview = $3, redirect_status = $4,
+			  forward_query = $5, query_conflict = $6, forward_path = $7, utm = $8, device_rules = $9, geo_rules = $10,
+			  variants = $11, sticky_variants = $12, active_from = $13, schedule = $14,
+			  password_hash = $15, folder_id = $16, title = $17, description = $18, notes = $19, metadata_status = $20
+			  WHERE short_url = $21 AND deleted_at IS NULL`
+
+	_, err := u.session.Exec(ctx, query, url.OriginalURL, url.Screening, url.Preview, url.RedirectStatus,
+		url.ForwardQuery, url.QueryConflict, url.ForwardPath, url.UTM, url.DeviceRules, url.GeoRules,
+		url.Variants, url.StickyVariants, url.ActiveFrom, url.Schedule, url.PasswordHash, url.FolderID, url.Title, url.Description, url.Notes, url.MetadataStatus, url.ShortURL)
+	if err != nil {
+		log.Err(err).Interface("url", url).Msg("failed to update url")
+		return fmt.Errorf("failed to update url: %w", err)
+	}
+
+	return nil
+}
+
+func (u *URLPostgresRepository) UpdateScreening(ctx context.Context, shortURL, screening string) error {
+	query := `UPDATE urls
+			  SET screening = $1
+			  WHERE short_url = $2`
+
+	_, err := u.session.Exec(ctx, query, screening, shortURL)
+	if err != nil {
+		log.Err(err).Str("short_url", shortURL).Msg("failed to update url screening")
+		return fmt.Errorf("failed to update url screening: %w", err)
+	}
+
+	return nil
+}
+
+func (u *URLPostgresRepository) UpdateForcePreview(ctx context.Context, shortURL string, forcePreview bool) error {
+	query := `UPDATE urls
+			  SET force_preview = $1
+			  WHERE short_url = $2`
+
+	_, err := u.session.Exec(ctx, query, forcePreview, shortURL)
+	if err != nil {
+		log.Err(err).Str("short_url", shortURL).Msg("failed to update url force preview")
+		return fmt.Errorf("failed to update url force preview: %w", err)
+	}
+
+	return nil
+}
+
+// Delete moves the url to the trash. The row stays, so its short url remains
+// taken until the url is purged.
 func (u *URLPostgresRepository) Delete(ctx context.Context, url entity.URL) error {
-	query := `DELETE FROM urls
-			  WHERE short_url = $1`
+	query := `UPDATE urls
+			  SET deleted_at = now()
+			  WHERE short_url = $1 AND deleted_at IS NULL`
 	_, err := u.session.Exec(ctx, query, url.ShortURL)
 	if err != nil {
 		log.Err(err).Interface("url", url).Msg("failed to delete url")
@@ -123,6 +274,94 @@ func (u *URLPostgresRepository) Delete(ctx context.Context, url entity.URL) erro
 	return nil
 }
 
+func (u *URLPostgresRepository) DeletedByShortURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE short_url = $1 AND deleted_at IS NOT NULL`
+
+	url, err := scanURL(u.session.QueryRow(ctx, query, shortURL))
+	if err != nil {
+		log.Err(err).Str("short_url", shortURL).Msg("failed to fetch deleted url by short_url")
+		return entity.URL{}, fmt.Errorf("failed to fetch deleted url by short_url: %w", err)
+	}
+
+	return url, nil
+}
+
+// DeletedByUserID returns the user's trash, most recently deleted first.
+func (u *URLPostgresRepository) DeletedByUserID(ctx context.Context, userID int) ([]entity.URL, error) {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE user_id = $1 AND deleted_at IS NOT NULL
+			  ORDER BY deleted_at DESC`
+
+	var urls []entity.URL
+
+	rows, err := u.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch deleted urls by user id")
+		return nil, fmt.Errorf("failed to fetch deleted urls by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url row")
+			return nil, fmt.Errorf("failed to scan url row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+// Restore takes the url out of the trash.
+func (u *URLPostgresRepository) Restore(ctx context.Context, url entity.URL) error {
+	query := `UPDATE urls
+			  SET deleted_at = NULL
+			  WHERE short_url = $1 AND deleted_at IS NOT NULL`
+	_, err := u.session.Exec(ctx, query, url.ShortURL)
+	if err != nil {
+		log.Err(err).Interface("url", url).Msg("failed to restore url")
+		return fmt.Errorf("failed to restore url: %w", err)
+	}
+
+	return nil
+}
+
+// Purge removes a url in the trash for good, along with its clicks.
+func (u *URLPostgresRepository) Purge(ctx context.Context, url entity.URL) error {
+	query := `DELETE FROM urls
+			  WHERE short_url = $1 AND deleted_at IS NOT NULL`
+	_, err := u.session.Exec(ctx, query, url.ShortURL)
+	if err != nil {
+		log.Err(err).Interface("url", url).Msg("failed to purge url")
+		return fmt.Errorf("failed to purge url: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeDeleted removes every url deleted before the given time and returns
+// how many were removed.
+func (u *URLPostgresRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM urls
+			  WHERE deleted_at < $1`
+	tag, err := u.session.Exec(ctx, query, before)
+	if err != nil {
+		log.Err(err).Time("before", before).Msg("failed to purge deleted urls")
+		return 0, fmt.Errorf("failed to purge deleted urls: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 func (u *URLPostgresRepository) UpdateClickCount(ctx context.Context, shortURL string) error {
 	query := `UPDATE urls
 			  SET click_count = click_count + 1
@@ -135,3 +374,159 @@ func (u *URLPostgresRepository) UpdateClickCount(ctx context.Context, shortURL s
 	}
 	return nil
 }
+
+// Flagged returns the urls that screening did not plainly allow or that a
+// moderator forced into preview mode.
+func (u *URLPostgresRepository) Flagged(ctx context.Context) ([]entity.URL, error) {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE (screening <> 'allow' OR force_preview) AND deleted_at IS NULL
+			  ORDER BY id DESC`
+
+	var urls []entity.URL
+
+	rows, err := u.session.Query(ctx, query)
+	if err != nil {
+		log.Err(err).Msg("failed to fetch flagged urls")
+		return nil, fmt.Errorf("failed to fetch flagged urls: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url row")
+			return nil, fmt.Errorf("failed to scan url row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+// PendingMetadata returns up to limit urls whose metadata still has to be
+// fetched, oldest first.
+func (u *URLPostgresRepository) PendingMetadata(ctx context.Context, limit int) ([]entity.URL, error) {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE metadata_status = 'pending' AND deleted_at IS NULL
+			  ORDER BY id
+			  LIMIT $1`
+
+	var urls []entity.URL
+
+	rows, err := u.session.Query(ctx, query, limit)
+	if err != nil {
+		log.Err(err).Msg("failed to fetch urls with pending metadata")
+		return nil, fmt.Errorf("failed to fetch urls with pending metadata: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url row")
+			return nil, fmt.Errorf("failed to scan url row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+// UpdateMetadata stores fetched metadata. Title and description only fill
+// fields that are still empty so values the user set meanwhile are kept, and
+// nothing changes unless the fetch is still pending.
+func (u *URLPostgresRepository) UpdateMetadata(ctx context.Context, ID int, title, description, status string) error {
+	query := `UPDATE urls
+			  SET title = CASE WHEN title = '' THEN $1 ELSE title END,
+			  description = CASE WHEN description = '' THEN $2 ELSE description END,
+			  metadata_status = $3
+			  WHERE id = $4 AND metadata_status = 'pending'`
+
+	_, err := u.session.Exec(ctx, query, title, description, status, ID)
+	if err != nil {
+		log.Err(err).Int("id", ID).Msg("failed to update url metadata")
+		return fmt.Errorf("failed to update url metadata: %w", err)
+	}
+
+	return nil
+}
+
+// ListAfterID pages through all urls ordered by id, starting after afterID.
+func (u *URLPostgresRepository) ListAfterID(ctx context.Context, afterID, limit int) ([]entity.URL, error) {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE id > $1 AND deleted_at IS NULL
+			  ORDER BY id
+			  LIMIT $2`
+
+	var urls []entity.URL
+
+	rows, err := u.session.Query(ctx, query, afterID, limit)
+	if err != nil {
+		log.Err(err).Int("after_id", afterID).Msg("failed to list urls")
+		return nil, fmt.Errorf("failed to list urls: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url row")
+			return nil, fmt.Errorf("failed to scan url row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+// EachByUserID streams the urls of a user, or of every user when userID is
+// 0, in id order without loading them all in memory.
+func (u *URLPostgresRepository) EachByUserID(ctx context.Context, userID int, fn func(url entity.URL) error) error {
+	query := `SELECT ` + urlColumns + `
+			  FROM urls
+			  WHERE ($1 = 0 OR user_id = $1) AND deleted_at IS NULL
+			  ORDER BY id`
+
+	rows, err := u.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to stream urls")
+		return fmt.Errorf("failed to stream urls: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan url row")
+			return fmt.Errorf("failed to scan url row: %w", err)
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate url rows")
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return nil
+}
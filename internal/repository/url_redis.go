@@ -17,12 +17,19 @@ type URLRedisRepository struct {
 	client rueidis.Client
 }
 
+// cachedURL keeps the fields entity.URL hides from json, so protected
+// links stay protected when served from the cache.
+type cachedURL struct {
+	entity.URL
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
 func NewURLRedisRepository(redisClient rueidis.Client) *URLRedisRepository {
 	return &URLRedisRepository{client: redisClient}
 }
 
-func (u *URLRedisRepository) Save(ctx context.Context, url entity.URL) error {
-	jsonData, err := json.Marshal(url)
+func (u *URLRedisRepository) Save(ctx context.Context, url entity.URL, ttl time.Duration) error {
+	jsonData, err := json.Marshal(cachedURL{URL: url, PasswordHash: url.PasswordHash})
 	if err != nil {
 		log.Err(err).Msg("failed to serialize url")
 		return fmt.Errorf("failed to serialize url: %w", err)
@@ -30,7 +37,7 @@ func (u *URLRedisRepository) Save(ctx context.Context, url entity.URL) error {
 
 	key := "url:" + url.ShortURL
 
-	cmd := u.client.B().Set().Key(key).Value(string(jsonData)).Ex(time.Second * 3600).Build()
+	cmd := u.client.B().Set().Key(key).Value(string(jsonData)).Px(ttl).Build()
 
 	err = u.client.Do(ctx, cmd).Error()
 	if err != nil {
@@ -54,12 +61,27 @@ func (u *URLRedisRepository) ByShortURL(ctx context.Context, shortURL string) (e
 		return entity.URL{}, fmt.Errorf("failed to fetch url from redis: %w", err)
 	}
 
-	var url entity.URL
-	err = json.Unmarshal([]byte(jsonData), &url)
+	var cached cachedURL
+	err = json.Unmarshal([]byte(jsonData), &cached)
 	if err != nil {
 		log.Err(err).Str("short_url", shortURL).Msg("failed to deserialize url")
 		return entity.URL{}, fmt.Errorf("failed to deserialize url: %w", err)
 	}
 
+	url := cached.URL
+	url.PasswordHash = cached.PasswordHash
+
 	return url, nil
 }
+
+func (u *URLRedisRepository) Delete(ctx context.Context, shortURL string) error {
+	key := "url:" + shortURL
+
+	err := u.client.Do(ctx, u.client.B().Del().Key(key).Build()).Error()
+	if err != nil {
+		log.Err(err).Str("short_url", shortURL).Msg("failed to delete url from redis")
+		return fmt.Errorf("failed to delete url from redis: %w", err)
+	}
+
+	return nil
+}
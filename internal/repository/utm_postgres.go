@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var _ UTMTemplate = &UTMTemplatePostgresRepository{}
+
+type UTMTemplatePostgresRepository struct {
+	session *pgxpool.Pool
+}
+
+func NewUTMTemplatePostgresRepository(session *pgxpool.Pool) *UTMTemplatePostgresRepository {
+	return &UTMTemplatePostgresRepository{
+		session: session,
+	}
+}
+
+const utmTemplateColumns = `id, user_id, name, source, medium, campaign, term, content, created_at`
+
+func scanUTMTemplate(row pgx.Row) (entity.UTMTemplate, error) {
+	var t entity.UTMTemplate
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.UTM.Source, &t.UTM.Medium, &t.UTM.Campaign, &t.UTM.Term, &t.UTM.Content, &t.CreatedAt)
+	return t, err
+}
+
+func (u *UTMTemplatePostgresRepository) ByID(ctx context.Context, ID int) (entity.UTMTemplate, error) {
+	query := `SELECT ` + utmTemplateColumns + `
+			  FROM utm_templates
+			  WHERE id = $1`
+
+	t, err := scanUTMTemplate(u.session.QueryRow(ctx, query, ID))
+	if err != nil {
+		log.Err(err).Int("id", ID).Msg("failed to fetch utm template by id")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.UTMTemplate{}, fmt.Errorf("utm template not found: %w", pgx.ErrNoRows)
+		}
+		return entity.UTMTemplate{}, fmt.Errorf("failed to fetch utm template by id: %w", err)
+	}
+
+	return t, nil
+}
+
+func (u *UTMTemplatePostgresRepository) ByUserID(ctx context.Context, userID int) ([]entity.UTMTemplate, error) {
+	query := `SELECT ` + utmTemplateColumns + `
+			  FROM utm_templates
+			  WHERE user_id = $1
+			  ORDER BY name`
+
+	var templates []entity.UTMTemplate
+
+	rows, err := u.session.Query(ctx, query, userID)
+	if err != nil {
+		log.Err(err).Int("user_id", userID).Msg("failed to fetch utm templates by user id")
+		return nil, fmt.Errorf("failed to fetch utm templates by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanUTMTemplate(rows)
+		if err != nil {
+			log.Err(err).Msg("failed to scan utm template row")
+			return nil, fmt.Errorf("failed to scan utm template row: %w", err)
+		}
+		templates = append(templates, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Err(err).Msg("failed to iterate utm template rows")
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return templates, nil
+}
+
+func (u *UTMTemplatePostgresRepository) Save(ctx context.Context, t entity.UTMTemplate) (entity.UTMTemplate, error) {
+	query := `INSERT INTO utm_templates (user_id, name, source, medium, campaign, term, content)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  RETURNING ` + utmTemplateColumns
+
+	saved, err := scanUTMTemplate(u.session.QueryRow(ctx, query, t.UserID, t.Name, t.UTM.Source, t.UTM.Medium, t.UTM.Campaign, t.UTM.Term, t.UTM.Content))
+	if err != nil {
+		log.Err(err).Interface("utm_template", t).Msg("failed to create utm template")
+		return entity.UTMTemplate{}, fmt.Errorf("failed to create utm template: %w", err)
+	}
+
+	return saved, nil
+}
+
+func (u *UTMTemplatePostgresRepository) Update(ctx context.Context, t entity.UTMTemplate) error {
+	query := `UPDATE utm_templates
+			  SET name = $1, source = $2, medium = $3, campaign = $4, term = $5, content = $6
+			  WHERE id = $7`
+
+	_, err := u.session.Exec(ctx, query, t.Name, t.UTM.Source, t.UTM.Medium, t.UTM.Campaign, t.UTM.Term, t.UTM.Content, t.ID)
+	if err != nil {
+		log.Err(err).Interface("utm_template", t).Msg("failed to update utm template")
+		return fmt.Errorf("failed to update utm template: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UTMTemplatePostgresRepository) Delete(ctx context.Context, t entity.UTMTemplate) error {
+	query := `DELETE FROM utm_templates
+			  WHERE id = $1`
+
+	_, err := u.session.Exec(ctx, query, t.ID)
+	if err != nil {
+		log.Err(err).Interface("utm_template", t).Msg("failed to delete utm template")
+		return fmt.Errorf("failed to delete utm template: %w", err)
+	}
+
+	return nil
+}
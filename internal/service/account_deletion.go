@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/pkg/auth"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AccountDeletionService deletes accounts once the grace period after the
+// user confirmed the deletion is over. Until then the deletion can be
+// canceled.
+type AccountDeletionService struct {
+	accounts repository.Account
+	tokens   repository.AccountRedis
+	urls     repository.URL
+	urlCache repository.URLRedis
+	grace    time.Duration
+}
+
+func NewAccountDeletionService(accounts repository.Account, tokens repository.AccountRedis, urls repository.URL, urlCache repository.URLRedis, grace time.Duration) *AccountDeletionService {
+	return &AccountDeletionService{
+		accounts: accounts,
+		tokens:   tokens,
+		urls:     urls,
+		urlCache: urlCache,
+		grace:    grace,
+	}
+}
+
+// Grace is how long a deletion can still be canceled.
+func (a *AccountDeletionService) Grace() time.Duration {
+	return a.grace
+}
+
+// Schedule sets the account of user to be deleted once the grace period is
+// over and signs them out everywhere.
+func (a *AccountDeletionService) Schedule(ctx context.Context, user entity.User) (time.Time, error) {
+	at := time.Now().Add(a.grace)
+	user.DeletionScheduledAt = &at
+
+	if err := a.accounts.ScheduleDeletion(ctx, user); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := a.tokens.RevokeTokens(ctx, user.ID, time.Now(), auth.RefreshTokenExp); err != nil {
+		return time.Time{}, err
+	}
+
+	return at, nil
+}
+
+func (a *AccountDeletionService) Cancel(ctx context.Context, user entity.User) error {
+	user.DeletionScheduledAt = nil
+	return a.accounts.ScheduleDeletion(ctx, user)
+}
+
+// Run deletes the accounts that are due every interval. It blocks until
+// ctx is done.
+func (a *AccountDeletionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := a.DeleteDue(ctx); err != nil {
+			log.Err(err).Msg("failed to delete accounts")
+		}
+	}
+}
+
+func (a *AccountDeletionService) DeleteDue(ctx context.Context) error {
+	users, err := a.accounts.DueForDeletion(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := a.delete(ctx, user); err != nil {
+			log.Err(err).Int("user_id", user.ID).Msg("failed to delete account")
+			continue
+		}
+		log.Info().Int("user_id", user.ID).Msg("account deleted")
+	}
+
+	return nil
+}
+
+// delete removes user, everything they own goes with it through the
+// foreign keys. Their links are dropped from the cache afterwards so none
+// of them keeps redirecting.
+func (a *AccountDeletionService) delete(ctx context.Context, user entity.User) error {
+	urls, err := a.urls.ByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := a.urls.DeletedByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.accounts.Delete(ctx, user); err != nil {
+		return err
+	}
+
+	for _, url := range append(urls, deleted...) {
+		if err := a.urlCache.Delete(ctx, url.ShortURL); err != nil {
+			log.Err(err).Str("short_url", url.ShortURL).Msg("failed to delete url of deleted account from cache")
+		}
+	}
+
+	if err := a.tokens.RevokeTokens(ctx, user.ID, time.Now(), auth.RefreshTokenExp); err != nil {
+		log.Err(err).Int("user_id", user.ID).Msg("failed to revoke tokens of deleted account")
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"time"
+)
+
+// AccountExportService packs everything stored about an account into a
+// zip archive, one json file per kind of data.
+type AccountExportService struct {
+	accounts     repository.Account
+	urls         repository.URL
+	tags         repository.Tag
+	folders      repository.Folder
+	utmTemplates repository.UTMTemplate
+	clicks       repository.Click
+	audit        repository.Audit
+	identities   repository.Identity
+}
+
+func NewAccountExportService(accounts repository.Account, urls repository.URL, tags repository.Tag, folders repository.Folder,
+	utmTemplates repository.UTMTemplate, clicks repository.Click, audit repository.Audit, identities repository.Identity) *AccountExportService {
+	return &AccountExportService{
+		accounts:     accounts,
+		urls:         urls,
+		tags:         tags,
+		folders:      folders,
+		utmTemplates: utmTemplates,
+		clicks:       clicks,
+		audit:        audit,
+		identities:   identities,
+	}
+}
+
+// exportProfile is the account as exported, without the password hash.
+type exportProfile struct {
+	ID                  int        `json:"id"`
+	Email               string     `json:"email"`
+	IsEmailVerified     bool       `json:"is_email_verified"`
+	Role                string     `json:"role"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	ExportedAt          time.Time  `json:"exported_at"`
+}
+
+// Export writes the archive of userID to w. Links in the trash are
+// included, clicks are exported as totals per dimension.
+func (a *AccountExportService) Export(ctx context.Context, userID int, w io.Writer) error {
+	user, err := a.accounts.ByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	urls, err := a.urls.ByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := a.urls.DeletedByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	urls = append(urls, deleted...)
+
+	urlIDs := make([]int, len(urls))
+	for i, url := range urls {
+		urlIDs[i] = url.ID
+	}
+
+	urlTags, err := a.tags.ByURLIDs(ctx, urlIDs)
+	if err != nil {
+		return err
+	}
+	for i := range urls {
+		urls[i].Tags = urlTags[urls[i].ID]
+	}
+
+	tags, err := a.tags.ByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	folders, err := a.folders.ByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	utmTemplates, err := a.utmTemplates.ByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	clicks := map[string][]entity.ClickGroup{}
+	for _, dimension := range repository.ClickDimensions() {
+		groups, err := a.clicks.GroupBy(ctx, entity.ClickFilter{UserID: userID}, dimension)
+		if err != nil {
+			return err
+		}
+		clicks[dimension] = groups
+	}
+
+	identities, err := a.identities.ByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var events []entity.AuditEvent
+	filter := entity.AuditFilter{UserID: userID, Limit: MaxAuditLimit}
+	for {
+		page, err := a.audit.ByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		events = append(events, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.BeforeID = page[len(page)-1].ID
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", exportProfile{
+			ID:                  user.ID,
+			Email:               user.Email,
+			IsEmailVerified:     user.IsEmailVerified,
+			Role:                user.Role,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+			CreatedAt:           user.CreatedAt,
+			ExportedAt:          time.Now().UTC(),
+		}},
+		{"links.json", urls},
+		{"tags.json", tags},
+		{"folders.json", folders},
+		{"utm_templates.json", utmTemplates},
+		{"clicks.json", clicks},
+		{"identities.json", identities},
+		{"audit_events.json", events},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to export: %w", file.name, err)
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return fmt.Errorf("failed to write %s to export: %w", file.name, err)
+		}
+	}
+
+	return zw.Close()
+}
@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"kuchak/internal/repository"
+	"kuchak/pkg/auth"
 	"time"
+
+	"github.com/redis/rueidis"
 )
 
 type AccountRedisService struct {
@@ -37,3 +41,55 @@ func (a *AccountRedisService) GetByResetPasswordToken(ctx context.Context, token
 func (a *AccountRedisService) GetByResetPasswordEmail(ctx context.Context, email string) (string, error) {
 	return a.repo.ByResetEmail(ctx, email)
 }
+
+func (a *AccountRedisService) SetEmailChange(ctx context.Context, userID int, email, token string) error {
+	return a.repo.SaveEmailChange(ctx, userID, email, token, time.Minute*30)
+}
+
+func (a *AccountRedisService) GetEmailChangeByUser(ctx context.Context, userID int) (string, error) {
+	return a.repo.ByEmailChangeUser(ctx, userID)
+}
+
+func (a *AccountRedisService) GetByEmailChangeToken(ctx context.Context, token string) (int, string, error) {
+	return a.repo.ByEmailChangeToken(ctx, token)
+}
+
+func (a *AccountRedisService) SetAccountDeletion(ctx context.Context, userID int, token string) error {
+	return a.repo.SaveDeletion(ctx, userID, token, time.Minute*30)
+}
+
+func (a *AccountRedisService) GetAccountDeletionByUser(ctx context.Context, userID int) (string, error) {
+	return a.repo.ByDeletionUser(ctx, userID)
+}
+
+func (a *AccountRedisService) GetByAccountDeletionToken(ctx context.Context, token string) (int, error) {
+	return a.repo.ByDeletionToken(ctx, token)
+}
+
+func (a *AccountRedisService) SetMagicLink(ctx context.Context, email, token string, ttl time.Duration) error {
+	return a.repo.SaveMagicLink(ctx, email, token, ttl)
+}
+
+func (a *AccountRedisService) GetByMagicLinkToken(ctx context.Context, token string) (string, error) {
+	return a.repo.ByMagicLinkToken(ctx, token)
+}
+
+// RevokeTokens logs userID out everywhere. The revocation is kept for as
+// long as a refresh token lives.
+func (a *AccountRedisService) RevokeTokens(ctx context.Context, userID int) error {
+	return a.repo.RevokeTokens(ctx, userID, time.Now(), auth.RefreshTokenExp)
+}
+
+// IsTokenRevoked reports whether claims were issued before the tokens of
+// their user got revoked.
+func (a *AccountRedisService) IsTokenRevoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	revokedAt, err := a.repo.TokensRevokedAt(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, rueidis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return claims.IssuedAt == nil || !claims.IssuedAt.After(revokedAt), nil
+}
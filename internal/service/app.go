@@ -1,5 +1,7 @@
 package service
 
+import "kuchak/pkg/auth"
+
 type App struct {
 	AccountPostgres *AccountPostgresService
 	URLPostgres     *URLPostgresService
@@ -7,6 +9,24 @@ type App struct {
 	URLRedis        *URLRedisService
 	RateLimit       *RateLimitService
 	EmailSender     *EmailService
+	URLPolicy       *URLPolicyService
+	Screening       *ScreeningService
+	Redirect        *RedirectService
+	UTMTemplate     *UTMTemplatePostgresService
+	Click           *ClickPostgresService
+	GeoIP           *GeoIPService
+	QR              *QRService
+	Quota           *QuotaService
+	URLTransfer     *URLTransferService
+	Tag             *TagPostgresService
+	Folder          *FolderPostgresService
+	Trash           *TrashService
+	URLHistory      *URLHistoryService
+	Audit           *AuditService
+	AccountDeletion *AccountDeletionService
+	AccountExport   *AccountExportService
+	OIDC            *OIDCService
+	Tokens          *auth.KeySet
 }
 
 func NewApp(
@@ -16,6 +36,24 @@ func NewApp(
 	URLRedis *URLRedisService,
 	RateLimit *RateLimitService,
 	EmailSender *EmailService,
+	URLPolicy *URLPolicyService,
+	Screening *ScreeningService,
+	Redirect *RedirectService,
+	UTMTemplate *UTMTemplatePostgresService,
+	Click *ClickPostgresService,
+	GeoIP *GeoIPService,
+	QR *QRService,
+	Quota *QuotaService,
+	URLTransfer *URLTransferService,
+	Tag *TagPostgresService,
+	Folder *FolderPostgresService,
+	Trash *TrashService,
+	URLHistory *URLHistoryService,
+	Audit *AuditService,
+	AccountDeletion *AccountDeletionService,
+	AccountExport *AccountExportService,
+	OIDC *OIDCService,
+	Tokens *auth.KeySet,
 ) *App {
-	return &App{AccountPostgres: AccountPostgres, URLPostgres: URLPostgres, AccountRedis: AccountRedis, URLRedis: URLRedis, RateLimit: RateLimit, EmailSender: EmailSender}
+	return &App{AccountPostgres: AccountPostgres, URLPostgres: URLPostgres, AccountRedis: AccountRedis, URLRedis: URLRedis, RateLimit: RateLimit, EmailSender: EmailSender, URLPolicy: URLPolicy, Screening: Screening, Redirect: Redirect, UTMTemplate: UTMTemplate, Click: Click, GeoIP: GeoIP, QR: QR, Quota: Quota, URLTransfer: URLTransfer, Tag: Tag, Folder: Folder, Trash: Trash, URLHistory: URLHistory, Audit: Audit, AccountDeletion: AccountDeletion, AccountExport: AccountExport, OIDC: OIDC, Tokens: Tokens}
 }
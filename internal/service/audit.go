@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"strings"
+)
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 500
+)
+
+type AuditService struct {
+	repo repository.Audit
+}
+
+func NewAuditService(repo repository.Audit) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record saves event. The repository logs failures and callers carry on,
+// a missing audit entry should not fail the request that caused it.
+func (a *AuditService) Record(ctx context.Context, event entity.AuditEvent) error {
+	if len(event.UserAgent) > 512 {
+		event.UserAgent = strings.ToValidUTF8(event.UserAgent[:512], "")
+	}
+	return a.repo.Save(ctx, event)
+}
+
+// GetEvents returns the events matching filter, its limit is clamped to
+// MaxAuditLimit and defaults to DefaultAuditLimit.
+func (a *AuditService) GetEvents(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, MaxAuditLimit)
+
+	return a.repo.ByFilter(ctx, filter)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+)
+
+type ClickPostgresService struct {
+	repo repository.Click
+}
+
+func NewClickPostgresService(repo repository.Click) *ClickPostgresService {
+	return &ClickPostgresService{repo: repo}
+}
+
+func (cl *ClickPostgresService) RecordClick(ctx context.Context, click entity.Click) error {
+	return cl.repo.Save(ctx, click)
+}
+
+func (cl *ClickPostgresService) GroupClicks(ctx context.Context, filter entity.ClickFilter, dimension string) ([]entity.ClickGroup, error) {
+	return cl.repo.GroupBy(ctx, filter, dimension)
+}
+
+func (cl *ClickPostgresService) RecordConversion(ctx context.Context, conversion entity.Conversion) error {
+	return cl.repo.SaveConversion(ctx, conversion)
+}
+
+func (cl *ClickPostgresService) GetVariantStats(ctx context.Context, urlID int) ([]entity.VariantStats, error) {
+	return cl.repo.VariantStats(ctx, urlID)
+}
+
+func (cl *ClickPostgresService) IsDimension(dimension string) bool {
+	return repository.IsClickDimension(dimension)
+}
@@ -73,6 +73,112 @@ func (e *EmailService) SendResetPasswordEmail(to, url string) error {
 	return e.sendEmail(to, subject, bodyText, bodyHTML.String())
 }
 
+func (e *EmailService) SendEmailChangeConfirmation(to, url string) error {
+	subject := "Confirm Your New Email"
+	bodyText := fmt.Sprintf("Click the following link to use %s for your account: \n%s\n", to, url)
+
+	tmpl, err := template.New("confirm_email_change.html").ParseFiles("internal/templates/confirm_email_change.html")
+	if err != nil {
+		return fmt.Errorf("template parse error: %v", err)
+	}
+
+	var bodyHTML bytes.Buffer
+	data := struct {
+		URL   string
+		Email string
+	}{
+		URL:   url,
+		Email: to,
+	}
+
+	err = tmpl.Execute(&bodyHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return e.sendEmail(to, subject, bodyText, bodyHTML.String())
+}
+
+// SendEmailChangeNotice tells the current address that the account is
+// about to move to newEmail.
+func (e *EmailService) SendEmailChangeNotice(to, newEmail string) error {
+	subject := "Your Email Is Being Changed"
+	bodyText := fmt.Sprintf("Someone asked to change the email of your account to %s. If it wasn't you, reset your password right away.\n", newEmail)
+
+	tmpl, err := template.New("email_change_notice.html").ParseFiles("internal/templates/email_change_notice.html")
+	if err != nil {
+		return fmt.Errorf("template parse error: %v", err)
+	}
+
+	var bodyHTML bytes.Buffer
+	data := struct {
+		Email string
+	}{
+		Email: newEmail,
+	}
+
+	err = tmpl.Execute(&bodyHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return e.sendEmail(to, subject, bodyText, bodyHTML.String())
+}
+
+// SendAccountDeletionConfirmation asks to confirm deleting the account,
+// grace describes how long it can still be canceled afterwards.
+func (e *EmailService) SendAccountDeletionConfirmation(to, url, grace string) error {
+	subject := "Confirm Account Deletion"
+	bodyText := fmt.Sprintf("Click the following link to delete your account, it is deleted %s after you confirm: \n%s\n", grace, url)
+
+	tmpl, err := template.New("confirm_account_deletion.html").ParseFiles("internal/templates/confirm_account_deletion.html")
+	if err != nil {
+		return fmt.Errorf("template parse error: %v", err)
+	}
+
+	var bodyHTML bytes.Buffer
+	data := struct {
+		URL   string
+		Grace string
+	}{
+		URL:   url,
+		Grace: grace,
+	}
+
+	err = tmpl.Execute(&bodyHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return e.sendEmail(to, subject, bodyText, bodyHTML.String())
+}
+
+func (e *EmailService) SendMagicLinkEmail(to, url, expiresIn string) error {
+	subject := "Sign In to Kuchak"
+	bodyText := fmt.Sprintf("Click the following link to sign in, it expires in %s and works once: \n%s\n", expiresIn, url)
+
+	tmpl, err := template.New("magic_link.html").ParseFiles("internal/templates/magic_link.html")
+	if err != nil {
+		return fmt.Errorf("template parse error: %v", err)
+	}
+
+	var bodyHTML bytes.Buffer
+	data := struct {
+		URL       string
+		ExpiresIn string
+	}{
+		URL:       url,
+		ExpiresIn: expiresIn,
+	}
+
+	err = tmpl.Execute(&bodyHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return e.sendEmail(to, subject, bodyText, bodyHTML.String())
+}
+
 func (e *EmailService) sendEmail(to, subject, bodyText, bodyHTML string) error {
 	auth := smtp.PlainAuth("", e.username, e.password, e.host)
 
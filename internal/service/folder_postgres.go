@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+)
+
+type FolderPostgresService struct {
+	repo repository.Folder
+}
+
+func NewFolderPostgresService(repo repository.Folder) *FolderPostgresService {
+	return &FolderPostgresService{repo: repo}
+}
+
+func (f *FolderPostgresService) GetFolderByID(ctx context.Context, ID int) (entity.Folder, error) {
+	return f.repo.ByID(ctx, ID)
+}
+
+func (f *FolderPostgresService) GetFoldersByUserID(ctx context.Context, userID int) ([]entity.Folder, error) {
+	return f.repo.ByUserID(ctx, userID)
+}
+
+func (f *FolderPostgresService) GetAncestorIDs(ctx context.Context, ID int) ([]int, error) {
+	return f.repo.AncestorIDs(ctx, ID)
+}
+
+func (f *FolderPostgresService) GetSubtreeHeight(ctx context.Context, ID int) (int, error) {
+	return f.repo.SubtreeHeight(ctx, ID)
+}
+
+func (f *FolderPostgresService) CreateFolder(ctx context.Context, folder entity.Folder) (entity.Folder, error) {
+	return f.repo.Save(ctx, folder)
+}
+
+func (f *FolderPostgresService) UpdateFolder(ctx context.Context, folder entity.Folder) error {
+	return f.repo.Update(ctx, folder)
+}
+
+func (f *FolderPostgresService) DeleteFolder(ctx context.Context, folder entity.Folder) error {
+	return f.repo.Delete(ctx, folder)
+}
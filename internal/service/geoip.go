@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kuchak/pkg/geoip"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GeoIPService resolves client addresses to countries using a local mmdb
+// file that is reloaded whenever it changes on disk. With no file configured
+// every lookup returns "".
+type GeoIPService struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *geoip.Reader
+	modTime time.Time
+}
+
+func NewGeoIPService(path string) (*GeoIPService, error) {
+	g := &GeoIPService{path: path}
+	if path == "" {
+		return g, nil
+	}
+
+	if _, err := g.Reload(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code for ip, or "" when it is unknown.
+func (g *GeoIPService) Country(ip string) string {
+	g.mu.RLock()
+	reader := g.reader
+	g.mu.RUnlock()
+
+	if reader == nil {
+		return ""
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+
+	country, err := reader.Country(addr)
+	if err != nil {
+		log.Err(err).Str("ip", ip).Msg("failed to lookup country")
+		return ""
+	}
+
+	return country
+}
+
+// Reload re-reads the database if the file changed since the last load.
+func (g *GeoIPService) Reload() (bool, error) {
+	if g.path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat geoip database %s: %w", g.path, err)
+	}
+
+	g.mu.RLock()
+	unchanged := info.ModTime().Equal(g.modTime)
+	g.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	reader, err := geoip.Open(g.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to load geoip database %s: %w", g.path, err)
+	}
+
+	g.mu.Lock()
+	g.reader = reader
+	g.modTime = info.ModTime()
+	g.mu.Unlock()
+
+	log.Info().Str("path", g.path).Str("type", reader.DatabaseType).Msg("geoip database loaded")
+
+	return true, nil
+}
+
+// Run reloads the database every interval. It blocks until ctx is done.
+func (g *GeoIPService) Run(ctx context.Context, interval time.Duration) {
+	if g.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := g.Reload(); err != nil {
+			log.Err(err).Msg("failed to reload geoip database")
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/pkg/oidc"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/rueidis"
+)
+
+// OIDCStateTTL is how long a user has to sign in at the provider.
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider  = errors.New("unknown sign in provider")
+	ErrInvalidState     = errors.New("sign in attempt is not valid or expired")
+	ErrEmailNotVerified = errors.New("provider did not verify the email")
+	ErrNoAccount        = errors.New("no account with this email")
+)
+
+type OIDCProvider struct {
+	Name string
+	// AutoCreate creates an account for verified emails that do not have
+	// one yet, otherwise only existing accounts can sign in.
+	AutoCreate bool
+	Provider   *oidc.Provider
+}
+
+// OIDCSignIn is the outcome of a sign in. Linked is set the first time an
+// identity is tied to an existing account, Created when the account was
+// made for it.
+type OIDCSignIn struct {
+	User    entity.User
+	Linked  bool
+	Created bool
+}
+
+type OIDCService struct {
+	providers  map[string]OIDCProvider
+	accounts   repository.Account
+	identities repository.Identity
+	states     repository.AccountRedis
+}
+
+func NewOIDCService(providers []OIDCProvider, accounts repository.Account, identities repository.Identity, states repository.AccountRedis) *OIDCService {
+	byName := make(map[string]OIDCProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+	}
+
+	return &OIDCService{
+		providers:  byName,
+		accounts:   accounts,
+		identities: identities,
+		states:     states,
+	}
+}
+
+// Providers returns the names of the configured providers, sorted.
+func (o *OIDCService) Providers() []string {
+	names := make([]string, 0, len(o.providers))
+	for name := range o.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// AuthURL starts a sign in with the provider name and returns where to
+// send the user along with the state the callback has to come back with,
+// so the caller can tie the attempt to the browser that started it.
+func (o *OIDCService) AuthURL(ctx context.Context, name string) (string, string, error) {
+	provider, ok := o.providers[name]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate oidc state: %w", err)
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.Provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := o.states.SaveOIDCState(ctx, state, entity.OIDCState{
+		Provider: name,
+		Verifier: verifier,
+		Nonce:    nonce,
+	}, OIDCStateTTL); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// SignIn finishes a sign in with the code the provider redirected back
+// with. Identities seen before sign in to their account, new ones are tied
+// to the account with the same verified email.
+func (o *OIDCService) SignIn(ctx context.Context, name, state, code string) (OIDCSignIn, error) {
+	provider, ok := o.providers[name]
+	if !ok {
+		return OIDCSignIn{}, ErrUnknownProvider
+	}
+
+	attempt, err := o.states.ByOIDCState(ctx, state)
+	if err != nil {
+		if errors.Is(err, rueidis.Nil) {
+			return OIDCSignIn{}, ErrInvalidState
+		}
+		return OIDCSignIn{}, err
+	}
+
+	if attempt.Provider != name {
+		return OIDCSignIn{}, ErrInvalidState
+	}
+
+	rawIDToken, err := provider.Provider.Exchange(ctx, code, attempt.Verifier)
+	if err != nil {
+		return OIDCSignIn{}, err
+	}
+
+	claims, err := provider.Provider.Verify(ctx, rawIDToken, attempt.Nonce)
+	if err != nil {
+		return OIDCSignIn{}, err
+	}
+
+	identity, err := o.identities.ByProviderSubject(ctx, name, claims.Subject)
+	if err == nil {
+		user, err := o.accounts.ByID(ctx, identity.UserID)
+		return OIDCSignIn{User: user}, err
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return OIDCSignIn{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return OIDCSignIn{}, ErrEmailNotVerified
+	}
+
+	signIn := OIDCSignIn{Linked: true}
+
+	user, err := o.accounts.ByEmail(ctx, claims.Email)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return OIDCSignIn{}, err
+		}
+		if !provider.AutoCreate {
+			return OIDCSignIn{}, ErrNoAccount
+		}
+
+		// Accounts made for an identity have no password, the reset flow
+		// can set one.
+		if err := o.accounts.Save(ctx, entity.User{Email: claims.Email}); err != nil {
+			return OIDCSignIn{}, err
+		}
+		if user, err = o.accounts.ByEmail(ctx, claims.Email); err != nil {
+			return OIDCSignIn{}, err
+		}
+		signIn.Created = true
+	}
+
+	if !user.IsEmailVerified {
+		// Whoever registered the unverified account did not prove they own
+		// the email, so their password must not keep working once the
+		// real owner signs in.
+		user.Password = ""
+		if err := o.accounts.UpdatePassword(ctx, user); err != nil {
+			return OIDCSignIn{}, err
+		}
+		user.IsEmailVerified = true
+		if err := o.accounts.UpdateVerifyEmail(ctx, user); err != nil {
+			return OIDCSignIn{}, err
+		}
+	}
+
+	if err := o.identities.Save(ctx, entity.Identity{
+		UserID:   user.ID,
+		Provider: name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return OIDCSignIn{}, err
+	}
+
+	signIn.User = user
+	return signIn, nil
+}
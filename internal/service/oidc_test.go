@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/pkg/oidc"
+	"kuchak/pkg/oidc/oidctest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/rueidis"
+)
+
+// oidcAccounts, oidcIdentities and oidcStates keep what a sign in touches
+// in memory.
+type oidcAccounts struct {
+	repository.Account
+	users  map[int]entity.User
+	nextID int
+}
+
+func (a *oidcAccounts) ByID(ctx context.Context, ID int) (entity.User, error) {
+	user, ok := a.users[ID]
+	if !ok {
+		return entity.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (a *oidcAccounts) ByEmail(ctx context.Context, email string) (entity.User, error) {
+	for _, user := range a.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return entity.User{}, pgx.ErrNoRows
+}
+
+func (a *oidcAccounts) Save(ctx context.Context, user entity.User) error {
+	a.nextID++
+	user.ID = a.nextID
+	a.users[user.ID] = user
+	return nil
+}
+
+func (a *oidcAccounts) UpdatePassword(ctx context.Context, user entity.User) error {
+	stored := a.users[user.ID]
+	stored.Password = user.Password
+	a.users[user.ID] = stored
+	return nil
+}
+
+func (a *oidcAccounts) UpdateVerifyEmail(ctx context.Context, user entity.User) error {
+	stored := a.users[user.ID]
+	stored.IsEmailVerified = user.IsEmailVerified
+	a.users[user.ID] = stored
+	return nil
+}
+
+type oidcIdentities struct {
+	repository.Identity
+	identities []entity.Identity
+}
+
+func (i *oidcIdentities) ByProviderSubject(ctx context.Context, provider, subject string) (entity.Identity, error) {
+	for _, identity := range i.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return entity.Identity{}, pgx.ErrNoRows
+}
+
+func (i *oidcIdentities) Save(ctx context.Context, identity entity.Identity) error {
+	i.identities = append(i.identities, identity)
+	return nil
+}
+
+type oidcStates struct {
+	repository.AccountRedis
+	states map[string]entity.OIDCState
+}
+
+func (s *oidcStates) SaveOIDCState(ctx context.Context, state string, value entity.OIDCState, ttl time.Duration) error {
+	s.states[state] = value
+	return nil
+}
+
+func (s *oidcStates) ByOIDCState(ctx context.Context, state string) (entity.OIDCState, error) {
+	value, ok := s.states[state]
+	if !ok {
+		return entity.OIDCState{}, fmt.Errorf("oidc state is not valid or expired: %w", rueidis.Nil)
+	}
+	delete(s.states, state)
+	return value, nil
+}
+
+type oidcTest struct {
+	idp        *oidctest.Provider
+	accounts   *oidcAccounts
+	identities *oidcIdentities
+	service    *OIDCService
+}
+
+// newOIDCTest sets up "open", which creates accounts, and "closed", which
+// does not, both backed by the same stand-in provider.
+func newOIDCTest(t *testing.T) *oidcTest {
+	idp := oidctest.NewProvider()
+	t.Cleanup(idp.Close)
+
+	provider := func() *oidc.Provider {
+		return oidc.NewProvider(oidc.Config{
+			Issuer:       idp.URL,
+			ClientID:     oidctest.ClientID,
+			ClientSecret: oidctest.ClientSecret,
+			RedirectURL:  "https://kuchak.test/callback",
+			Scopes:       []string{"openid", "email"},
+		})
+	}
+
+	accounts := &oidcAccounts{users: map[int]entity.User{}}
+	identities := &oidcIdentities{}
+	states := &oidcStates{states: map[string]entity.OIDCState{}}
+
+	return &oidcTest{
+		idp:        idp,
+		accounts:   accounts,
+		identities: identities,
+		service: NewOIDCService([]OIDCProvider{
+			{Name: "open", AutoCreate: true, Provider: provider()},
+			{Name: "closed", Provider: provider()},
+		}, accounts, identities, states),
+	}
+}
+
+// signIn goes through a whole sign in with name as subject, claims are
+// added to the ID token.
+func (o *oidcTest) signIn(t *testing.T, name, subject string, claims jwt.MapClaims) (OIDCSignIn, error) {
+	t.Helper()
+
+	authURL, state, err := o.service.AuthURL(context.Background(), name)
+	if err != nil {
+		t.Fatalf("AuthURL: %v", err)
+	}
+
+	idpState, code, err := o.idp.Authorize(authURL, subject, claims)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if idpState != state {
+		t.Fatalf("provider got state %q, want %q", idpState, state)
+	}
+
+	return o.service.SignIn(context.Background(), name, state, code)
+}
+
+func verifiedEmail(email string) jwt.MapClaims {
+	return jwt.MapClaims{"email": email, "email_verified": true}
+}
+
+func TestOIDCSignInCreatesAccount(t *testing.T) {
+	o := newOIDCTest(t)
+
+	signIn, err := o.signIn(t, "open", "alice", verifiedEmail("alice@example.com"))
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if !signIn.Created || !signIn.Linked {
+		t.Errorf("SignIn = %+v, want a created and linked account", signIn)
+	}
+
+	user, err := o.accounts.ByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("account was not created: %v", err)
+	}
+	if !user.IsEmailVerified || user.Password != "" || signIn.User.ID != user.ID {
+		t.Errorf("created account = %+v, signed in as %+v", user, signIn.User)
+	}
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != user.ID {
+		t.Errorf("identities = %+v, want one for user %d", o.identities.identities, user.ID)
+	}
+
+	// The identity signs in to the same account from now on.
+	signIn, err = o.signIn(t, "open", "alice", jwt.MapClaims{"email": "alice@elsewhere.example"})
+	if err != nil {
+		t.Fatalf("second SignIn: %v", err)
+	}
+	if signIn.Created || signIn.Linked || signIn.User.ID != user.ID {
+		t.Errorf("second SignIn = %+v, want user %d", signIn, user.ID)
+	}
+}
+
+func TestOIDCSignInWithoutAutoCreate(t *testing.T) {
+	o := newOIDCTest(t)
+
+	if _, err := o.signIn(t, "closed", "alice", verifiedEmail("alice@example.com")); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("SignIn error = %v, want %v", err, ErrNoAccount)
+	}
+	if len(o.accounts.users) != 0 || len(o.identities.identities) != 0 {
+		t.Errorf("SignIn without auto create stored %d users and %d identities", len(o.accounts.users), len(o.identities.identities))
+	}
+}
+
+func TestOIDCSignInLinksExistingAccount(t *testing.T) {
+	tests := []struct {
+		name         string
+		verified     bool
+		wantPassword string
+	}{
+		{name: "verified", verified: true, wantPassword: "hash"},
+		{name: "unverified", verified: false, wantPassword: ""},
+	}
+
+	for _, tt := range tests {
+		o := newOIDCTest(t)
+		o.accounts.Save(context.Background(), entity.User{Email: "bob@example.com", Password: "hash", IsEmailVerified: tt.verified})
+
+		signIn, err := o.signIn(t, "closed", "bob", verifiedEmail("bob@example.com"))
+		if err != nil {
+			t.Fatalf("%s: SignIn: %v", tt.name, err)
+		}
+		if signIn.Created || !signIn.Linked || signIn.User.ID != 1 {
+			t.Errorf("%s: SignIn = %+v, want account 1 linked", tt.name, signIn)
+		}
+
+		user := o.accounts.users[1]
+		if user.Password != tt.wantPassword || !user.IsEmailVerified {
+			t.Errorf("%s: account after linking = %+v, want password %q and a verified email", tt.name, user, tt.wantPassword)
+		}
+	}
+}
+
+func TestOIDCSignInRequiresVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{name: "not verified", claims: jwt.MapClaims{"email": "carol@example.com", "email_verified": false}},
+		{name: "not verified string", claims: jwt.MapClaims{"email": "carol@example.com", "email_verified": "false"}},
+		{name: "verification missing", claims: jwt.MapClaims{"email": "carol@example.com"}},
+		{name: "no email", claims: jwt.MapClaims{"email_verified": true}},
+	}
+
+	for _, tt := range tests {
+		o := newOIDCTest(t)
+		o.accounts.Save(context.Background(), entity.User{Email: "carol@example.com", Password: "hash", IsEmailVerified: true})
+
+		if _, err := o.signIn(t, "open", "carol", tt.claims); !errors.Is(err, ErrEmailNotVerified) {
+			t.Errorf("%s: SignIn error = %v, want %v", tt.name, err, ErrEmailNotVerified)
+		}
+		if len(o.identities.identities) != 0 {
+			t.Errorf("%s: identity was linked", tt.name)
+		}
+	}
+}
+
+func TestOIDCSignInRejectsTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{name: "nonce mismatch", claims: jwt.MapClaims{"nonce": "replayed"}},
+		{name: "other audience", claims: jwt.MapClaims{"aud": "someone-else"}},
+		{name: "issued to another party", claims: jwt.MapClaims{"aud": []string{oidctest.ClientID, "someone-else"}, "azp": "someone-else"}},
+		{name: "issuer mismatch", claims: jwt.MapClaims{"iss": "https://evil.example"}},
+	}
+
+	for _, tt := range tests {
+		o := newOIDCTest(t)
+		for name, value := range verifiedEmail("dave@example.com") {
+			tt.claims[name] = value
+		}
+
+		if _, err := o.signIn(t, "open", "dave", tt.claims); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Errorf("%s: SignIn error = %v, want %v", tt.name, err, oidc.ErrInvalidToken)
+		}
+		if len(o.accounts.users) != 0 {
+			t.Errorf("%s: account was created", tt.name)
+		}
+	}
+}
+
+func TestOIDCSignInState(t *testing.T) {
+	o := newOIDCTest(t)
+	ctx := context.Background()
+
+	if _, _, err := o.service.AuthURL(ctx, "missing"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("AuthURL of an unknown provider error = %v, want %v", err, ErrUnknownProvider)
+	}
+
+	authURL, state, err := o.service.AuthURL(ctx, "open")
+	if err != nil {
+		t.Fatalf("AuthURL: %v", err)
+	}
+	_, code, err := o.idp.Authorize(authURL, "erin", verifiedEmail("erin@example.com"))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	if _, err := o.service.SignIn(ctx, "open", "forged", code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("SignIn with an unknown state error = %v, want %v", err, ErrInvalidState)
+	}
+
+	// A state started with one provider can not finish at another, and it
+	// is used up by the attempt.
+	if _, err := o.service.SignIn(ctx, "closed", state, code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("SignIn at another provider error = %v, want %v", err, ErrInvalidState)
+	}
+	if _, err := o.service.SignIn(ctx, "open", state, code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("SignIn with a used state error = %v, want %v", err, ErrInvalidState)
+	}
+}
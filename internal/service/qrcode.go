@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kuchak/internal/repository"
+	"kuchak/pkg/qrcode"
+	"strings"
+	"time"
+)
+
+const qrCacheTTL = 24 * time.Hour
+
+type QROptions struct {
+	Format     string
+	Size       int
+	Level      string
+	Margin     int
+	Foreground string
+	Background string
+}
+
+type QRService struct {
+	appURL string
+	cache  repository.QRRedis
+}
+
+func NewQRService(appURL string, cache repository.QRRedis) *QRService {
+	return &QRService{
+		appURL: strings.TrimSuffix(appURL, "/"),
+		cache:  cache,
+	}
+}
+
+// Render returns the QR code of the short link as a png or svg image along
+// with its content type.
+func (q *QRService) Render(ctx context.Context, shortURL string, opts QROptions) ([]byte, string, error) {
+	contentType := "image/png"
+	if opts.Format == "svg" {
+		contentType = "image/svg+xml"
+	}
+
+	key := fmt.Sprintf("%s:%s:%d:%s:%d:%s:%s", shortURL, opts.Format, opts.Size, opts.Level, opts.Margin,
+		strings.ToLower(opts.Foreground), strings.ToLower(opts.Background))
+
+	if image, err := q.cache.Get(ctx, key); err == nil {
+		return image, contentType, nil
+	}
+
+	level, err := qrcode.ParseLevel(opts.Level)
+	if err != nil {
+		return nil, "", err
+	}
+	fg, err := qrcode.ParseColor(opts.Foreground)
+	if err != nil {
+		return nil, "", err
+	}
+	bg, err := qrcode.ParseColor(opts.Background)
+	if err != nil {
+		return nil, "", err
+	}
+
+	code, err := qrcode.Encode([]byte(q.appURL+"/"+shortURL), level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	renderOpts := qrcode.Options{Size: opts.Size, Margin: opts.Margin, Foreground: fg, Background: bg}
+
+	var image []byte
+	if opts.Format == "svg" {
+		image = code.SVG(renderOpts)
+	} else if image, err = code.PNG(renderOpts); err != nil {
+		return nil, "", err
+	}
+
+	q.cache.Save(ctx, key, image, qrCacheTTL)
+
+	return image, contentType, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kuchak/internal/repository"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("daily link quota exceeded")
+
+// QuotaService enforces how many links a user may create per day. A zero
+// limit disables it.
+type QuotaService struct {
+	repo       repository.Quota
+	dailyLinks int
+}
+
+func NewQuotaService(repo repository.Quota, dailyLinks int) *QuotaService {
+	return &QuotaService{repo: repo, dailyLinks: dailyLinks}
+}
+
+func linkQuotaKey(userID int) string {
+	return fmt.Sprintf("links:%d:%s", userID, time.Now().UTC().Format(time.DateOnly))
+}
+
+// ReserveLinks takes n links from the user's daily quota. It reports whether
+// they fit and how many links are left today.
+func (q *QuotaService) ReserveLinks(ctx context.Context, userID, n int) (bool, int, error) {
+	if q.dailyLinks <= 0 {
+		return true, -1, nil
+	}
+	return q.repo.Reserve(ctx, linkQuotaKey(userID), n, q.dailyLinks, 48*time.Hour)
+}
+
+// ReleaseLinks returns n reserved links that were not created.
+func (q *QuotaService) ReleaseLinks(ctx context.Context, userID, n int) error {
+	if q.dailyLinks <= 0 || n <= 0 {
+		return nil
+	}
+	return q.repo.Release(ctx, linkQuotaKey(userID), n)
+}
@@ -15,5 +15,37 @@ func NewRateLimitService(repo repository.RateLimiter) *RateLimitService {
 }
 
 func (r *RateLimitService) IsAllowed(ctx context.Context, ip string, limit int, window time.Duration) (bool, int, time.Time, error) {
-	return r.repo.IsAllowed(ctx, ip, limit, window)
+	return r.repo.IsAllowed(ctx, "ip", ip, limit, window)
+}
+
+// IsUnlockAllowed limits password attempts on a link, both per client and
+// across all clients.
+func (r *RateLimitService) IsUnlockAllowed(ctx context.Context, shortURL, ip string, perLink, perIP int, window time.Duration) (bool, error) {
+	allowed, _, _, err := r.repo.IsAllowed(ctx, "unlock:ip", ip, perIP, window)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	allowed, _, _, err = r.repo.IsAllowed(ctx, "unlock:url", shortURL, perLink, window)
+	if err != nil {
+		return false, err
+	}
+
+	return allowed, nil
+}
+
+// IsMagicLinkAllowed limits how many sign in links are sent, both per
+// client and per email so a mailbox can not be flooded.
+func (r *RateLimitService) IsMagicLinkAllowed(ctx context.Context, email, ip string, perEmail, perIP int, window time.Duration) (bool, error) {
+	allowed, _, _, err := r.repo.IsAllowed(ctx, "magic_link:ip", ip, perIP, window)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	allowed, _, _, err = r.repo.IsAllowed(ctx, "magic_link:email", email, perEmail, window)
+	if err != nil {
+		return false, err
+	}
+
+	return allowed, nil
 }
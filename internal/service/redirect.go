@@ -0,0 +1,203 @@
+package service
+
+import (
+	"kuchak/internal/entity"
+	"kuchak/pkg/useragent"
+	"math/rand/v2"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Query conflict policies decide what happens when an incoming query
+// parameter is already present on the destination.
+const (
+	QueryConflictKeep     = "keep"
+	QueryConflictOverride = "override"
+	QueryConflictAppend   = "append"
+)
+
+func IsQueryConflictPolicy(policy string) bool {
+	switch policy {
+	case QueryConflictKeep, QueryConflictOverride, QueryConflictAppend:
+		return true
+	}
+	return false
+}
+
+// RedirectRequest carries the parts of an incoming click that can influence
+// where it is sent.
+type RedirectRequest struct {
+	Query      url.Values
+	PathSuffix string
+	UserAgent  useragent.UserAgent
+	Country    string
+	// Variant is the variant the visitor was assigned before, if any.
+	Variant string
+	Time    time.Time
+}
+
+type RedirectService struct {
+	defaultQueryConflict string
+}
+
+func NewRedirectService(defaultQueryConflict string) *RedirectService {
+	return &RedirectService{defaultQueryConflict: defaultQueryConflict}
+}
+
+// Destination returns the url a click on link should be redirected to and
+// the name of the variant served, if any. Schedule rules are checked first,
+// then device rules, geo rules and variants; the first match wins.
+func (r *RedirectService) Destination(link entity.URL, req RedirectRequest) (string, string) {
+	destination := link.OriginalURL
+	var variant string
+	if rule, ok := matchScheduleRule(link.Schedule, req.Time); ok {
+		destination = rule.Destination
+	} else if rule, ok := matchDeviceRule(link.DeviceRules, req.UserAgent); ok {
+		destination = rule.Destination
+	} else if rule, ok := matchGeoRule(link.GeoRules, req.Country); ok {
+		destination = rule.Destination
+	} else if v, ok := pickVariant(link.Variants, req.Variant); ok {
+		destination = v.Destination
+		variant = v.Name
+	}
+
+	forwardQuery := link.ForwardQuery && len(req.Query) > 0
+	forwardPath := link.ForwardPath && req.PathSuffix != ""
+	if !forwardQuery && !forwardPath && link.UTM == nil {
+		return destination, variant
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination, variant
+	}
+
+	if forwardPath {
+		// Cleaning against the root keeps the suffix from climbing above
+		// the destination path.
+		suffix := path.Clean("/" + req.PathSuffix)
+		if strings.HasSuffix(req.PathSuffix, "/") && suffix != "/" {
+			suffix += "/"
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + suffix
+		u.RawPath = ""
+	}
+
+	if link.UTM != nil || forwardQuery {
+		query := u.Query()
+		if link.UTM != nil {
+			appendUTM(query, *link.UTM)
+		}
+		if forwardQuery {
+			conflict := link.QueryConflict
+			if conflict == "" {
+				conflict = r.defaultQueryConflict
+			}
+			query = mergeQuery(query, req.Query, conflict)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String(), variant
+}
+
+// appendUTM adds the utm parameters that the destination does not set itself.
+func appendUTM(query url.Values, utm entity.UTM) {
+	params := map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	}
+	for key, value := range params {
+		if value != "" && query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+}
+
+func mergeQuery(destination, incoming url.Values, conflict string) url.Values {
+	for key, values := range incoming {
+		_, exists := destination[key]
+		switch {
+		case !exists:
+			destination[key] = values
+		case conflict == QueryConflictOverride:
+			destination[key] = values
+		case conflict == QueryConflictAppend:
+			destination[key] = append(destination[key], values...)
+		}
+	}
+	return destination
+}
+
+// matchScheduleRule returns the first rule whose window contains t.
+func matchScheduleRule(rules []entity.ScheduleRule, t time.Time) (entity.ScheduleRule, bool) {
+	for _, rule := range rules {
+		if rule.Contains(t) {
+			return rule, true
+		}
+	}
+	return entity.ScheduleRule{}, false
+}
+
+// matchDeviceRule returns the first rule matching the user agent.
+func matchDeviceRule(rules []entity.DeviceRule, ua useragent.UserAgent) (entity.DeviceRule, bool) {
+	for _, rule := range rules {
+		if rule.OS != "" && rule.OS != ua.OS {
+			continue
+		}
+		if rule.Device != "" && rule.Device != ua.Device {
+			continue
+		}
+		if rule.Bot != nil && *rule.Bot != ua.Bot {
+			continue
+		}
+		return rule, true
+	}
+	return entity.DeviceRule{}, false
+}
+
+// matchGeoRule returns the first rule listing country.
+func matchGeoRule(rules []entity.GeoRule, country string) (entity.GeoRule, bool) {
+	if country == "" {
+		return entity.GeoRule{}, false
+	}
+	for _, rule := range rules {
+		for _, c := range rule.Countries {
+			if strings.EqualFold(c, country) {
+				return rule, true
+			}
+		}
+	}
+	return entity.GeoRule{}, false
+}
+
+// pickVariant returns the variant named preferred if it is still served,
+// otherwise one chosen at random according to the weights.
+func pickVariant(variants []entity.Variant, preferred string) (entity.Variant, bool) {
+	total := 0
+	for _, v := range variants {
+		if preferred != "" && v.Name == preferred && v.Weight > 0 {
+			return v, true
+		}
+		total += v.Weight
+	}
+
+	if total <= 0 {
+		return entity.Variant{}, false
+	}
+
+	n := rand.IntN(total)
+	for _, v := range variants {
+		if n < v.Weight {
+			return v, true
+		}
+		n -= v.Weight
+	}
+
+	return entity.Variant{}, false
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+)
+
+type TagPostgresService struct {
+	repo repository.Tag
+}
+
+func NewTagPostgresService(repo repository.Tag) *TagPostgresService {
+	return &TagPostgresService{repo: repo}
+}
+
+func (t *TagPostgresService) GetTagByID(ctx context.Context, ID int) (entity.Tag, error) {
+	return t.repo.ByID(ctx, ID)
+}
+
+func (t *TagPostgresService) GetTagsByUserID(ctx context.Context, userID int) ([]entity.Tag, error) {
+	return t.repo.ByUserID(ctx, userID)
+}
+
+func (t *TagPostgresService) GetTagsByURLIDs(ctx context.Context, urlIDs []int) (map[int][]entity.Tag, error) {
+	return t.repo.ByURLIDs(ctx, urlIDs)
+}
+
+func (t *TagPostgresService) CreateTag(ctx context.Context, tag entity.Tag) (entity.Tag, error) {
+	return t.repo.Save(ctx, tag)
+}
+
+func (t *TagPostgresService) UpdateTag(ctx context.Context, tag entity.Tag) error {
+	return t.repo.Update(ctx, tag)
+}
+
+func (t *TagPostgresService) DeleteTag(ctx context.Context, tag entity.Tag) error {
+	return t.repo.Delete(ctx, tag)
+}
+
+func (t *TagPostgresService) SetURLTags(ctx context.Context, urlID int, tagIDs []int) error {
+	return t.repo.SetURLTags(ctx, urlID, tagIDs)
+}
+
+func (t *TagPostgresService) RetagURLs(ctx context.Context, userID int, shortURLs []string, add, remove []int) (int, error) {
+	return t.repo.Retag(ctx, userID, shortURLs, add, remove)
+}
+
+func (t *TagPostgresService) GetTagStats(ctx context.Context, userID int) ([]entity.TagStats, error) {
+	return t.repo.Stats(ctx, userID)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrAliasInvalid  = errors.New("alias must be 3 to 32 letters, digits, '-' or '_'")
+	ErrAliasReserved = errors.New("alias is reserved")
+)
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// reservedAliases can not be used as short urls because they are, or may
+// become, routes of the app itself.
+var reservedAliases = map[string]struct{}{
+	".well-known": {}, "account": {}, "admin": {}, "api": {}, "auth": {}, "convert": {},
+	"favicon.ico": {}, "folders": {}, "healthz": {}, "login": {}, "moderation": {},
+	"static": {}, "tags": {}, "urls": {}, "utm": {},
+}
+
+// IsReservedAlias reports whether alias is taken by the app's own routes.
+func IsReservedAlias(alias string) bool {
+	_, ok := reservedAliases[strings.ToLower(alias)]
+	return ok
+}
+
+// ValidateAlias checks a short url chosen by a user rather than generated.
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return ErrAliasInvalid
+	}
+	if IsReservedAlias(alias) {
+		return ErrAliasReserved
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"kuchak/internal/entity"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlocklistScreener matches destinations against domain lists (hosts-file or
+// one domain per line) and url pattern lists (one regular expression per
+// line) loaded from disk.
+type BlocklistScreener struct {
+	domainFiles  []string
+	patternFiles []string
+	verdict      string
+
+	mu       sync.RWMutex
+	domains  map[string]struct{}
+	patterns []*regexp.Regexp
+	modTimes map[string]time.Time
+}
+
+func NewBlocklistScreener(domainFiles, patternFiles []string, verdict string) (*BlocklistScreener, error) {
+	b := &BlocklistScreener{
+		domainFiles:  domainFiles,
+		patternFiles: patternFiles,
+		verdict:      verdict,
+		domains:      map[string]struct{}{},
+		modTimes:     map[string]time.Time{},
+	}
+
+	if _, err := b.Reload(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *BlocklistScreener) Screen(u *url.URL) (string, string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	host := u.Hostname()
+	for domain := host; domain != ""; {
+		if _, ok := b.domains[domain]; ok {
+			return b.verdict, "domain " + domain + " is blocklisted"
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+
+	raw := u.String()
+	for _, pattern := range b.patterns {
+		if pattern.MatchString(raw) {
+			return b.verdict, "url matches blocklisted pattern " + pattern.String()
+		}
+	}
+
+	return entity.ScreeningAllow, ""
+}
+
+// Reload re-reads the lists if any of the files changed since the last load.
+func (b *BlocklistScreener) Reload() (bool, error) {
+	modTimes := map[string]time.Time{}
+	changed := false
+	for _, file := range append(append([]string{}, b.domainFiles...), b.patternFiles...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat blocklist %s: %w", file, err)
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(b.modTimes[file]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	domains := map[string]struct{}{}
+	for _, file := range b.domainFiles {
+		if err := readListFile(file, func(line string) error {
+			for _, domain := range parseDomainLine(line) {
+				domains[domain] = struct{}{}
+			}
+			return nil
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	var patterns []*regexp.Regexp
+	for _, file := range b.patternFiles {
+		if err := readListFile(file, func(line string) error {
+			pattern, err := regexp.Compile(line)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q in %s: %w", line, file, err)
+			}
+			patterns = append(patterns, pattern)
+			return nil
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.patterns = patterns
+	b.modTimes = modTimes
+	b.mu.Unlock()
+
+	return true, nil
+}
+
+// readListFile calls fn for every line of file that is not empty or a comment.
+func readListFile(file string, fn func(line string) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open blocklist %s: %w", file, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blocklist %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// parseDomainLine accepts both hosts-file entries ("0.0.0.0 a.com b.com")
+// and plain domain lines ("a.com").
+func parseDomainLine(line string) []string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+		fields = fields[1:]
+	}
+
+	var domains []string
+	for _, domain := range normalizeDomains(fields) {
+		if domain == "localhost" || domain == "localhost.localdomain" || net.ParseIP(domain) != nil {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	return domains
+}
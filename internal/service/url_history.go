@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"reflect"
+)
+
+// historyIgnored are the url fields that change without anyone editing the
+// link, they are left out of diffs.
+var historyIgnored = map[string]bool{
+	"id":              true,
+	"user_id":         true,
+	"click_count":     true,
+	"created_at":      true,
+	"tags":            true,
+	"deleted_at":      true,
+	"metadata_status": true,
+}
+
+type URLHistoryService struct {
+	repo repository.URLHistory
+}
+
+func NewURLHistoryService(repo repository.URLHistory) *URLHistoryService {
+	return &URLHistoryService{repo: repo}
+}
+
+// Entry builds the history entry of a change from before to after made by
+// actorID, 0 for changes made by the system. before is nil for new links.
+func Entry(action string, before *entity.URL, after entity.URL, actorID int, requestID string) entity.URLHistory {
+	entry := entity.URLHistory{
+		URLID:     after.ID,
+		Action:    action,
+		RequestID: requestID,
+		Snapshot:  after,
+	}
+	entry.Snapshot.Tags = nil
+
+	if actorID != 0 {
+		entry.ActorID = &actorID
+	}
+
+	if before != nil {
+		entry.Changes = DiffURLs(*before, after)
+	}
+
+	return entry
+}
+
+// Record appends entries to the history of their links.
+func (h *URLHistoryService) Record(ctx context.Context, entries ...entity.URLHistory) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return h.repo.Save(ctx, entries)
+}
+
+func (h *URLHistoryService) GetHistory(ctx context.Context, urlID int) ([]entity.URLHistory, error) {
+	return h.repo.ByURLID(ctx, urlID)
+}
+
+func (h *URLHistoryService) GetVersion(ctx context.Context, urlID, version int) (entity.URLHistory, error) {
+	return h.repo.ByVersion(ctx, urlID, version)
+}
+
+// DiffURLs returns the fields that differ between before and after, keyed by
+// their json name.
+func DiffURLs(before, after entity.URL) map[string]entity.FieldChange {
+	from, to := urlFields(before), urlFields(after)
+
+	changes := map[string]entity.FieldChange{}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			to[key] = nil
+		}
+	}
+	for key, value := range to {
+		if historyIgnored[key] || reflect.DeepEqual(from[key], value) {
+			continue
+		}
+		changes[key] = entity.FieldChange{From: from[key], To: value}
+	}
+
+	return changes
+}
+
+func urlFields(url entity.URL) map[string]any {
+	fields := map[string]any{}
+
+	data, err := json.Marshal(url)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+
+	return fields
+}
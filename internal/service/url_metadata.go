@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/pkg/unfurl"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Fetched metadata is cut down to what the urls table and api accept.
+const (
+	MaxTitleLength       = 512
+	MaxDescriptionLength = 2048
+)
+
+// MetadataService fills in the title and description of links created
+// without a title by fetching their destination in the background.
+type MetadataService struct {
+	urls    repository.URL
+	fetcher *unfurl.Fetcher
+	batch   int
+}
+
+func NewMetadataService(urls repository.URL, fetcher *unfurl.Fetcher, batch int) *MetadataService {
+	return &MetadataService{
+		urls:    urls,
+		fetcher: fetcher,
+		batch:   batch,
+	}
+}
+
+// Run fetches pending metadata every interval. It blocks until ctx is done.
+func (m *MetadataService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.FetchPending(ctx); err != nil {
+			log.Err(err).Msg("failed to fetch pending url metadata")
+		}
+	}
+}
+
+// FetchPending fetches the metadata of one batch of pending links.
+func (m *MetadataService) FetchPending(ctx context.Context) error {
+	urls, err := m.urls.PendingMetadata(ctx, m.batch)
+	if err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.Fetch(ctx, url)
+	}
+
+	return nil
+}
+
+// Fetch fetches and stores the metadata of url. A failed fetch is recorded
+// and not retried.
+func (m *MetadataService) Fetch(ctx context.Context, url entity.URL) {
+	status := entity.MetadataDone
+
+	page, err := m.fetcher.Fetch(ctx, url.OriginalURL)
+	if err != nil {
+		log.Err(err).Str("short_url", url.ShortURL).Msg("failed to fetch url metadata")
+		status = entity.MetadataFailed
+	}
+
+	title := truncate(page.Title, MaxTitleLength)
+	description := truncate(page.Description, MaxDescriptionLength)
+
+	if err := m.urls.UpdateMetadata(ctx, url.ID, title, description, status); err != nil {
+		log.Err(err).Str("short_url", url.ShortURL).Msg("failed to store url metadata")
+		return
+	}
+
+	log.Info().Str("short_url", url.ShortURL).Str("status", status).Msg("url metadata fetched")
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
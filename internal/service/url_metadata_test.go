@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/pkg/unfurl"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+type metadataUpdate struct {
+	title, description, status string
+}
+
+// metadataRepo stands in for the urls table, only the metadata methods
+// are implemented.
+type metadataRepo struct {
+	repository.URL
+	pending []entity.URL
+	limit   int
+	updates map[int]metadataUpdate
+}
+
+func (r *metadataRepo) PendingMetadata(ctx context.Context, limit int) ([]entity.URL, error) {
+	r.limit = limit
+	if len(r.pending) > limit {
+		return r.pending[:limit], nil
+	}
+	return r.pending, nil
+}
+
+func (r *metadataRepo) UpdateMetadata(ctx context.Context, ID int, title, description, status string) error {
+	r.updates[ID] = metadataUpdate{title: title, description: description, status: status}
+	return nil
+}
+
+func TestFetchPending(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<title>Hello</title><meta name="description" content="World">`)
+	})
+	mux.HandleFunc("/long", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<title>%s</title>`, strings.Repeat("é", MaxTitleLength+10))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	repo := &metadataRepo{
+		pending: []entity.URL{
+			{ID: 1, ShortURL: "page", OriginalURL: server.URL + "/page"},
+			{ID: 2, ShortURL: "long", OriginalURL: server.URL + "/long"},
+			{ID: 3, ShortURL: "image", OriginalURL: server.URL + "/image"},
+			{ID: 4, ShortURL: "gone", OriginalURL: server.URL + "/gone"},
+			{ID: 5, ShortURL: "later", OriginalURL: server.URL + "/page"},
+		},
+		updates: map[int]metadataUpdate{},
+	}
+	fetcher := unfurl.NewFetcher(unfurl.Options{
+		Timeout:      2 * time.Second,
+		MaxBytes:     1 << 20,
+		MaxRedirects: 3,
+		Allow:        func(addr netip.Addr) bool { return addr.Unmap().IsLoopback() },
+	})
+
+	if err := NewMetadataService(repo, fetcher, 4).FetchPending(context.Background()); err != nil {
+		t.Fatalf("FetchPending: %v", err)
+	}
+
+	if repo.limit != 4 {
+		t.Errorf("PendingMetadata limit = %d, want 4", repo.limit)
+	}
+
+	want := map[int]metadataUpdate{
+		1: {title: "Hello", description: "World", status: entity.MetadataDone},
+		2: {title: strings.Repeat("é", MaxTitleLength), status: entity.MetadataDone},
+		3: {status: entity.MetadataFailed},
+		4: {status: entity.MetadataFailed},
+	}
+	if len(repo.updates) != len(want) {
+		t.Errorf("updated %d links, want %d", len(repo.updates), len(want))
+	}
+	for id, w := range want {
+		if got, ok := repo.updates[id]; !ok || got != w {
+			t.Errorf("link %d: update = %+v, want %+v", id, got, w)
+		}
+	}
+}
+
+func TestFetchPendingCanceled(t *testing.T) {
+	repo := &metadataRepo{
+		pending: []entity.URL{{ID: 1, OriginalURL: "http://127.0.0.1:1/"}},
+		updates: map[int]metadataUpdate{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := NewMetadataService(repo, unfurl.NewFetcher(unfurl.Options{}), 10).FetchPending(ctx); err == nil {
+		t.Error("FetchPending with a canceled context succeeded")
+	}
+	if len(repo.updates) != 0 {
+		t.Errorf("updated %d links after cancel, want 0", len(repo.updates))
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrURLEmpty         = errors.New("url is empty")
+	ErrURLTooLong       = errors.New("url is too long")
+	ErrURLInvalid       = errors.New("url is not valid")
+	ErrURLScheme        = errors.New("url scheme is not allowed")
+	ErrURLCredentials   = errors.New("url must not contain credentials")
+	ErrURLSelfReference = errors.New("url points back to this service")
+	ErrURLShortened     = errors.New("url is already shortened")
+	ErrURLDomainDenied  = errors.New("url domain is not allowed")
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+type URLPolicyService struct {
+	selfHost         string
+	allowedSchemes   []string
+	maxLength        int
+	allowedDomains   []string
+	deniedDomains    []string
+	shortenerDomains []string
+}
+
+func NewURLPolicyService(appURL string, allowedSchemes []string, maxLength int, allowedDomains, deniedDomains, shortenerDomains []string) *URLPolicyService {
+	p := &URLPolicyService{
+		maxLength:        maxLength,
+		allowedDomains:   normalizeDomains(allowedDomains),
+		deniedDomains:    normalizeDomains(deniedDomains),
+		shortenerDomains: normalizeDomains(shortenerDomains),
+	}
+
+	for _, scheme := range allowedSchemes {
+		p.allowedSchemes = append(p.allowedSchemes, strings.ToLower(scheme))
+	}
+
+	if u, err := url.Parse(appURL); err == nil {
+		if host, err := normalizeHost(u.Scheme, u.Host); err == nil {
+			p.selfHost = hostname(host)
+		}
+	}
+
+	return p
+}
+
+// Normalize checks a destination url against the policy and returns its
+// canonical form, which is what should be stored and redirected to.
+func (p *URLPolicyService) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", ErrURLEmpty
+	}
+
+	if p.maxLength > 0 && len(rawURL) > p.maxLength {
+		return "", ErrURLTooLong
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() {
+		return "", ErrURLInvalid
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !p.schemeAllowed(u.Scheme) {
+		return "", ErrURLScheme
+	}
+
+	if u.Opaque != "" {
+		return "", ErrURLInvalid
+	}
+
+	if u.User != nil {
+		return "", ErrURLCredentials
+	}
+
+	u.Host, err = normalizeHost(u.Scheme, u.Host)
+	if err != nil {
+		return "", err
+	}
+
+	host := hostname(u.Host)
+
+	if p.selfHost != "" && host == p.selfHost {
+		return "", ErrURLSelfReference
+	}
+
+	if matchDomains(host, p.shortenerDomains) {
+		return "", ErrURLShortened
+	}
+
+	if matchDomains(host, p.deniedDomains) {
+		return "", ErrURLDomainDenied
+	}
+
+	if len(p.allowedDomains) > 0 && !matchDomains(host, p.allowedDomains) {
+		return "", ErrURLDomainDenied
+	}
+
+	normalized := u.String()
+	if p.maxLength > 0 && len(normalized) > p.maxLength {
+		return "", ErrURLTooLong
+	}
+
+	return normalized, nil
+}
+
+func (p *URLPolicyService) schemeAllowed(scheme string) bool {
+	for _, allowed := range p.allowedSchemes {
+		if scheme == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases the host, converts IDNs to punycode and drops the
+// port when it is the default one for the scheme.
+func normalizeHost(scheme, host string) (string, error) {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = host, ""
+	}
+
+	name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "["), "]"), ".")
+	if name == "" {
+		return "", ErrURLInvalid
+	}
+
+	if ip := net.ParseIP(name); ip != nil {
+		name = ip.String()
+	} else {
+		ascii, err := idna.Lookup.ToASCII(name)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrURLInvalid, err)
+		}
+		name = strings.ToLower(ascii)
+	}
+
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+
+	if strings.Contains(name, ":") {
+		name = "[" + name + "]"
+	}
+
+	if port != "" {
+		return name + ":" + port, nil
+	}
+
+	return name, nil
+}
+
+func hostname(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
+
+func normalizeDomains(domains []string) []string {
+	var normalized []string
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+			domain = ascii
+		}
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+// matchDomains reports whether host equals one of the domains or is a
+// subdomain of it.
+func matchDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -26,14 +26,54 @@ func (u *URLPostgresService) GetURLsByUserID(ctx context.Context, userID int) ([
 	return u.repo.ByUserID(ctx, userID)
 }
 
+func (u *URLPostgresService) GetURLsByFilter(ctx context.Context, filter entity.URLFilter) ([]entity.URL, error) {
+	return u.repo.ByFilter(ctx, filter)
+}
+
 func (u *URLPostgresService) CreateURL(ctx context.Context, url entity.URL) error {
 	return u.repo.Save(ctx, url)
 }
 
+func (u *URLPostgresService) CreateURLs(ctx context.Context, urls []entity.URL) ([]bool, error) {
+	return u.repo.SaveBatch(ctx, urls)
+}
+
+func (u *URLPostgresService) UpdateURL(ctx context.Context, url entity.URL) error {
+	return u.repo.Update(ctx, url)
+}
+
+func (u *URLPostgresService) UpdateURLForcePreview(ctx context.Context, shortURL string, forcePreview bool) error {
+	return u.repo.UpdateForcePreview(ctx, shortURL, forcePreview)
+}
+
+func (u *URLPostgresService) GetFlaggedURLs(ctx context.Context) ([]entity.URL, error) {
+	return u.repo.Flagged(ctx)
+}
+
 func (u *URLPostgresService) DeleteURL(ctx context.Context, url entity.URL) error {
 	return u.repo.Delete(ctx, url)
 }
 
+func (u *URLPostgresService) GetDeletedURLByShortURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	return u.repo.DeletedByShortURL(ctx, shortURL)
+}
+
+func (u *URLPostgresService) GetDeletedURLsByUserID(ctx context.Context, userID int) ([]entity.URL, error) {
+	return u.repo.DeletedByUserID(ctx, userID)
+}
+
+func (u *URLPostgresService) RestoreURL(ctx context.Context, url entity.URL) error {
+	return u.repo.Restore(ctx, url)
+}
+
+func (u *URLPostgresService) PurgeURL(ctx context.Context, url entity.URL) error {
+	return u.repo.Purge(ctx, url)
+}
+
+func (u *URLPostgresService) UpdateURLScreening(ctx context.Context, shortURL, screening string) error {
+	return u.repo.UpdateScreening(ctx, shortURL, screening)
+}
+
 func (u *URLPostgresService) UpdateURLClickCount(ctx context.Context, shortURL string) error {
 	return u.repo.UpdateClickCount(ctx, shortURL)
 }
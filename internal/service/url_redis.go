@@ -4,8 +4,11 @@ import (
 	"context"
 	"kuchak/internal/entity"
 	"kuchak/internal/repository"
+	"time"
 )
 
+const urlCacheTTL = time.Hour
+
 type URLRedisService struct {
 	repo repository.URLRedis
 }
@@ -18,6 +21,21 @@ func (u *URLRedisService) GetFromCacheByShortURL(ctx context.Context, shortURL s
 	return u.repo.ByShortURL(ctx, shortURL)
 }
 
+// SetURLToCache caches url until its next schedule boundary, at most for an
+// hour, so a cached entry never outlives the routing it was stored with.
 func (u *URLRedisService) SetURLToCache(ctx context.Context, url entity.URL) error {
-	return u.repo.Save(ctx, url)
+	ttl := urlCacheTTL
+	if next, ok := url.NextBoundary(time.Now()); ok {
+		ttl = min(ttl, time.Until(next))
+	}
+
+	if ttl < time.Millisecond {
+		return nil
+	}
+
+	return u.repo.Save(ctx, url, ttl)
+}
+
+func (u *URLRedisService) DeleteFromCache(ctx context.Context, shortURL string) error {
+	return u.repo.Delete(ctx, shortURL)
 }
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"kuchak/internal/entity"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Limits on the routing settings of a link. Links created through the api and
+// imported ones are held to the same ones.
+const (
+	MaxDeviceRules   = 20
+	MaxGeoRules      = 50
+	MaxVariants      = 10
+	MaxScheduleRules = 20
+	MaxUTMLength     = 255
+)
+
+var rules = validator.New()
+
+// NormalizeRules checks the redirect settings, utm parameters and routing
+// rules of url and normalizes the destinations of its rules. The original url
+// is left to the caller.
+func (p *URLPolicyService) NormalizeRules(url *entity.URL) error {
+	switch url.RedirectStatus {
+	case 0, 301, 302, 307, 308:
+	default:
+		return fmt.Errorf("invalid redirect status %d", url.RedirectStatus)
+	}
+	if url.QueryConflict != "" && !IsQueryConflictPolicy(url.QueryConflict) {
+		return fmt.Errorf("invalid query conflict policy %q", url.QueryConflict)
+	}
+
+	if url.UTM != nil {
+		for _, value := range []string{url.UTM.Source, url.UTM.Medium, url.UTM.Campaign, url.UTM.Term, url.UTM.Content} {
+			if len(value) > MaxUTMLength {
+				return fmt.Errorf("utm parameters must be at most %d characters", MaxUTMLength)
+			}
+		}
+	}
+
+	var err error
+
+	if len(url.DeviceRules) > MaxDeviceRules {
+		return fmt.Errorf("at most %d device rules are allowed", MaxDeviceRules)
+	}
+	for i := range url.DeviceRules {
+		rule := &url.DeviceRules[i]
+		if rules.Var(rule.OS, "omitempty,oneof=ios android windows macos linux chromeos other") != nil {
+			return fmt.Errorf("device rule: unknown os %q", rule.OS)
+		}
+		if rules.Var(rule.Device, "omitempty,oneof=mobile tablet desktop") != nil {
+			return fmt.Errorf("device rule: unknown device %q", rule.Device)
+		}
+		if rule.Destination, err = p.Normalize(rule.Destination); err != nil {
+			return fmt.Errorf("device rule destination: %w", err)
+		}
+	}
+
+	if len(url.GeoRules) > MaxGeoRules {
+		return fmt.Errorf("at most %d geo rules are allowed", MaxGeoRules)
+	}
+	for i := range url.GeoRules {
+		rule := &url.GeoRules[i]
+		if rules.Var(rule.Countries, "required,min=1,dive,iso3166_1_alpha2") != nil {
+			return errors.New("geo rule countries must be ISO 3166-1 alpha-2 codes")
+		}
+		if rule.Destination, err = p.Normalize(rule.Destination); err != nil {
+			return fmt.Errorf("geo rule destination: %w", err)
+		}
+	}
+
+	if len(url.Variants) > MaxVariants {
+		return fmt.Errorf("at most %d variants are allowed", MaxVariants)
+	}
+	names := make(map[string]bool, len(url.Variants))
+	for i := range url.Variants {
+		variant := &url.Variants[i]
+		if rules.Var(variant.Name, "required,max=64,alphanumunicode") != nil {
+			return fmt.Errorf("variant name %q must be 1 to 64 letters or digits", variant.Name)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("variant name %q is used twice", variant.Name)
+		}
+		names[variant.Name] = true
+		if rules.Var(variant.Weight, "min=0,max=10000") != nil {
+			return fmt.Errorf("variant %s weight must be between 0 and 10000", variant.Name)
+		}
+		if variant.Destination, err = p.Normalize(variant.Destination); err != nil {
+			return fmt.Errorf("variant %s destination: %w", variant.Name, err)
+		}
+	}
+
+	if len(url.Schedule) > MaxScheduleRules {
+		return fmt.Errorf("at most %d schedule rules are allowed", MaxScheduleRules)
+	}
+	for i := range url.Schedule {
+		rule := &url.Schedule[i]
+		if rule.From != nil && rule.Until != nil && !rule.Until.After(*rule.From) {
+			return errors.New("schedule rule must end after it starts")
+		}
+		if rule.Destination, err = p.Normalize(rule.Destination); err != nil {
+			return fmt.Errorf("schedule rule destination: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/publicsuffix"
+)
+
+var verdictSeverity = map[string]int{
+	entity.ScreeningAllow:        0,
+	entity.ScreeningInterstitial: 1,
+	entity.ScreeningReview:       2,
+	entity.ScreeningReject:       3,
+}
+
+// IsScreeningVerdict reports whether v is one of the known screening verdicts.
+func IsScreeningVerdict(v string) bool {
+	_, ok := verdictSeverity[v]
+	return ok
+}
+
+// Screener inspects a normalized destination and returns a verdict and the
+// reason for it. Screeners that have nothing to say return ScreeningAllow.
+type Screener interface {
+	Screen(u *url.URL) (verdict string, reason string)
+}
+
+// ReloadableScreener is a screener backed by external data that can change
+// while the server is running.
+type ReloadableScreener interface {
+	Screener
+	// Reload refreshes the underlying data and reports whether it changed.
+	Reload() (bool, error)
+}
+
+type ScreeningResult struct {
+	Verdict string
+	Reasons []string
+}
+
+type ScreeningService struct {
+	screeners []Screener
+	urls      repository.URL
+	cache     repository.URLRedis
+	// stale holds links whose verdict changed but that could not be
+	// dropped from the redirect cache yet.
+	stale map[string]struct{}
+}
+
+func NewScreeningService(urls repository.URL, cache repository.URLRedis, screeners ...Screener) *ScreeningService {
+	return &ScreeningService{
+		screeners: screeners,
+		urls:      urls,
+		cache:     cache,
+		stale:     map[string]struct{}{},
+	}
+}
+
+// Screen runs every screener against rawURL and returns the most severe verdict.
+func (s *ScreeningService) Screen(rawURL string) ScreeningResult {
+	result := ScreeningResult{Verdict: entity.ScreeningAllow}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		result.Verdict = entity.ScreeningReject
+		result.Reasons = append(result.Reasons, "url is not valid")
+		return result
+	}
+
+	for _, screener := range s.screeners {
+		verdict, reason := screener.Screen(u)
+		if verdict == entity.ScreeningAllow {
+			continue
+		}
+		result.Reasons = append(result.Reasons, reason)
+		if verdictSeverity[verdict] > verdictSeverity[result.Verdict] {
+			result.Verdict = verdict
+		}
+	}
+
+	return result
+}
+
+// ScreenAll screens every destination and returns the most severe verdict.
+func (s *ScreeningService) ScreenAll(rawURLs []string) ScreeningResult {
+	result := ScreeningResult{Verdict: entity.ScreeningAllow}
+	for _, rawURL := range rawURLs {
+		r := s.Screen(rawURL)
+		result.Reasons = append(result.Reasons, r.Reasons...)
+		if verdictSeverity[r.Verdict] > verdictSeverity[result.Verdict] {
+			result.Verdict = r.Verdict
+		}
+	}
+	return result
+}
+
+// Run reloads the screeners' data every interval and, whenever it changes,
+// re-screens all existing urls. It blocks until ctx is done.
+func (s *ScreeningService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.reload() && len(s.stale) == 0 {
+			continue
+		}
+
+		if err := s.Rescreen(ctx); err != nil {
+			log.Err(err).Msg("failed to rescreen urls")
+		}
+	}
+}
+
+func (s *ScreeningService) reload() bool {
+	changed := false
+	for _, screener := range s.screeners {
+		reloadable, ok := screener.(ReloadableScreener)
+		if !ok {
+			continue
+		}
+		ok, err := reloadable.Reload()
+		if err != nil {
+			log.Err(err).Msg("failed to reload screener")
+			continue
+		}
+		changed = changed || ok
+	}
+	return changed
+}
+
+// Rescreen applies the current screening rules to every stored url and
+// updates the ones whose verdict changed. Links that could not be dropped
+// from the redirect cache are retried on the next call, which must not run
+// concurrently with this one.
+func (s *ScreeningService) Rescreen(ctx context.Context) error {
+	const pageSize = 500
+
+	for shortURL := range s.stale {
+		if err := s.evict(ctx, shortURL); err != nil {
+			return err
+		}
+	}
+
+	updated := 0
+	afterID := 0
+	for {
+		urls, err := s.urls.ListAfterID(ctx, afterID, pageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range urls {
+			afterID = u.ID
+
+			result := s.ScreenAll(u.Destinations())
+			if result.Verdict == u.Screening {
+				continue
+			}
+
+			if err := s.urls.UpdateScreening(ctx, u.ShortURL, result.Verdict); err != nil {
+				return err
+			}
+			if err := s.evict(ctx, u.ShortURL); err != nil {
+				return err
+			}
+
+			log.Info().Str("short_url", u.ShortURL).Str("verdict", result.Verdict).Strs("reasons", result.Reasons).Msg("url rescreened")
+			updated++
+		}
+
+		if len(urls) < pageSize {
+			break
+		}
+	}
+
+	log.Info().Int("updated", updated).Msg("rescreen finished")
+
+	return nil
+}
+
+// evict drops shortURL from the redirect cache so a new verdict applies to
+// the next click instead of after the cache expires.
+func (s *ScreeningService) evict(ctx context.Context, shortURL string) error {
+	const attempts = 3
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		}
+
+		if err = s.cache.Delete(ctx, shortURL); err == nil {
+			delete(s.stale, shortURL)
+			return nil
+		}
+	}
+
+	s.stale[shortURL] = struct{}{}
+	log.Err(err).Str("short_url", shortURL).Msg("failed to drop rescreened url from cache")
+	return err
+}
+
+// HeuristicScreener flags destinations that look suspicious regardless of
+// any blocklist.
+type HeuristicScreener struct {
+	ipHostVerdict    string
+	maxSubdomains    int
+	subdomainVerdict string
+}
+
+func NewHeuristicScreener(ipHostVerdict string, maxSubdomains int, subdomainVerdict string) *HeuristicScreener {
+	return &HeuristicScreener{
+		ipHostVerdict:    ipHostVerdict,
+		maxSubdomains:    maxSubdomains,
+		subdomainVerdict: subdomainVerdict,
+	}
+}
+
+func (h *HeuristicScreener) Screen(u *url.URL) (string, string) {
+	host := u.Hostname()
+
+	if net.ParseIP(host) != nil {
+		return h.ipHostVerdict, "host is an ip address"
+	}
+
+	if h.maxSubdomains > 0 {
+		domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+		if err != nil {
+			domain = host
+		}
+		subdomains := strings.Count(host, ".") - strings.Count(domain, ".")
+		if subdomains > h.maxSubdomains {
+			return h.subdomainVerdict, "host has too many subdomains"
+		}
+	}
+
+	return entity.ScreeningAllow, ""
+}
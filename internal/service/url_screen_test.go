@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"net/url"
+	"testing"
+)
+
+// screenRepo stands in for the urls table, only what rescreening uses is
+// implemented.
+type screenRepo struct {
+	repository.URL
+	urls []entity.URL
+}
+
+func (r *screenRepo) ListAfterID(ctx context.Context, afterID, limit int) ([]entity.URL, error) {
+	var page []entity.URL
+	for _, u := range r.urls {
+		if u.ID > afterID && len(page) < limit {
+			page = append(page, u)
+		}
+	}
+	return page, nil
+}
+
+func (r *screenRepo) UpdateScreening(ctx context.Context, shortURL, screening string) error {
+	for i := range r.urls {
+		if r.urls[i].ShortURL == shortURL {
+			r.urls[i].Screening = screening
+		}
+	}
+	return nil
+}
+
+// screenCache fails as many deletes as failures says before it works.
+type screenCache struct {
+	repository.URLRedis
+	failures int
+	deleted  []string
+}
+
+func (c *screenCache) Delete(ctx context.Context, shortURL string) error {
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("redis is down")
+	}
+	c.deleted = append(c.deleted, shortURL)
+	return nil
+}
+
+type hostScreener map[string]string
+
+func (h hostScreener) Screen(u *url.URL) (string, string) {
+	if verdict, ok := h[u.Hostname()]; ok {
+		return verdict, "listed host"
+	}
+	return entity.ScreeningAllow, ""
+}
+
+func TestRescreen(t *testing.T) {
+	repo := &screenRepo{urls: []entity.URL{
+		{ID: 1, ShortURL: "good", OriginalURL: "https://good.example/", Screening: entity.ScreeningAllow},
+		{ID: 2, ShortURL: "bad", OriginalURL: "https://bad.example/", Screening: entity.ScreeningAllow},
+		{ID: 3, ShortURL: "cleared", OriginalURL: "https://cleared.example/", Screening: entity.ScreeningReview},
+	}}
+	cache := &screenCache{}
+	s := NewScreeningService(repo, cache, hostScreener{"bad.example": entity.ScreeningReject})
+
+	if err := s.Rescreen(context.Background()); err != nil {
+		t.Fatalf("Rescreen: %v", err)
+	}
+
+	want := map[string]string{"good": entity.ScreeningAllow, "bad": entity.ScreeningReject, "cleared": entity.ScreeningAllow}
+	for _, u := range repo.urls {
+		if u.Screening != want[u.ShortURL] {
+			t.Errorf("%s: screening = %s, want %s", u.ShortURL, u.Screening, want[u.ShortURL])
+		}
+	}
+	if len(cache.deleted) != 2 || cache.deleted[0] != "bad" || cache.deleted[1] != "cleared" {
+		t.Errorf("evicted %v, want [bad cleared]", cache.deleted)
+	}
+}
+
+func TestRescreenRetriesEviction(t *testing.T) {
+	repo := &screenRepo{urls: []entity.URL{
+		{ID: 1, ShortURL: "bad", OriginalURL: "https://bad.example/", Screening: entity.ScreeningAllow},
+	}}
+
+	// A short outage is retried within the same run.
+	cache := &screenCache{failures: 2}
+	s := NewScreeningService(repo, cache, hostScreener{"bad.example": entity.ScreeningReject})
+	if err := s.Rescreen(context.Background()); err != nil {
+		t.Fatalf("Rescreen: %v", err)
+	}
+	if len(cache.deleted) != 1 {
+		t.Errorf("evicted %v after a short outage, want [bad]", cache.deleted)
+	}
+
+	// A longer one fails the run, and the next run evicts the link even
+	// though its stored verdict is already up to date.
+	repo.urls[0].Screening = entity.ScreeningAllow
+	cache = &screenCache{failures: 3}
+	s = NewScreeningService(repo, cache, hostScreener{"bad.example": entity.ScreeningReject})
+	if err := s.Rescreen(context.Background()); err == nil {
+		t.Fatal("Rescreen succeeded while the cache was down")
+	}
+	if len(cache.deleted) != 0 || len(s.stale) != 1 {
+		t.Fatalf("evicted %v with %d stale links, want none evicted and 1 stale", cache.deleted, len(s.stale))
+	}
+
+	if err := s.Rescreen(context.Background()); err != nil {
+		t.Fatalf("second Rescreen: %v", err)
+	}
+	if len(cache.deleted) != 1 || cache.deleted[0] != "bad" || len(s.stale) != 0 {
+		t.Errorf("second run evicted %v with %d stale links, want [bad] and none", cache.deleted, len(s.stale))
+	}
+}
@@ -0,0 +1,503 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"kuchak/pkg/utils"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	TransferFormatCSV    = "csv"
+	TransferFormatNDJSON = "ndjson"
+
+	ImportConflictSkip   = "skip"
+	ImportConflictRename = "rename"
+
+	importBatchSize   = 500
+	importRenameTries = 5
+)
+
+var (
+	ErrUnknownTransferFormat = errors.New("unknown format, use csv or ndjson")
+	ErrInvalidImport         = errors.New("invalid import file")
+	ErrProtectedImport       = errors.New("link is password protected and exports do not include passwords, import with unprotect to create it without one")
+)
+
+// csvColumns are the exported fields, in order. Structured fields are
+// written as json.
+var csvColumns = []string{
+	"short_url", "original_url", "title", "description", "notes", "click_count", "created_at", "screening",
+	"preview", "force_preview", "redirect_status", "forward_query", "query_conflict", "forward_path", "utm", "device_rules",
+	"geo_rules", "variants", "sticky_variants", "active_from", "schedule", "protected",
+}
+
+var csvStringColumns = map[string]bool{
+	"short_url": true, "original_url": true, "title": true, "description": true, "notes": true,
+	"created_at": true, "screening": true, "query_conflict": true, "active_from": true,
+}
+
+type ImportOptions struct {
+	// OnConflict decides what happens to a record whose short url is taken:
+	// skip it or import it under a newly generated one.
+	OnConflict   string
+	EnforceQuota bool
+	// Unprotect imports password protected links without their password,
+	// exports do not carry it. Without it such records are refused.
+	Unprotect bool
+
+	// ActorID and RequestID are recorded in the history of imported links.
+	ActorID   int
+	RequestID string
+}
+
+type ImportIssue struct {
+	Record      int    `json:"record"`
+	ShortURL    string `json:"short_url,omitempty"`
+	NewShortURL string `json:"new_short_url,omitempty"`
+	Error       string `json:"error"`
+}
+
+type ImportReport struct {
+	Imported int           `json:"imported"`
+	Renamed  int           `json:"renamed"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Issues   []ImportIssue `json:"issues"`
+}
+
+type URLTransferService struct {
+	urls      repository.URL
+	policy    *URLPolicyService
+	screening *ScreeningService
+	quota     *QuotaService
+	history   *URLHistoryService
+}
+
+func NewURLTransferService(urls repository.URL, policy *URLPolicyService, screening *ScreeningService, quota *QuotaService, history *URLHistoryService) *URLTransferService {
+	return &URLTransferService{
+		urls:      urls,
+		policy:    policy,
+		screening: screening,
+		quota:     quota,
+		history:   history,
+	}
+}
+
+func IsTransferFormat(format string) bool {
+	return format == TransferFormatCSV || format == TransferFormatNDJSON
+}
+
+// Export streams the links of a user, or of everyone when userID is 0, to w.
+func (t *URLTransferService) Export(ctx context.Context, userID int, format string, w io.Writer) error {
+	flush := func() {}
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+
+	switch format {
+	case TransferFormatNDJSON:
+		enc := json.NewEncoder(w)
+		n := 0
+		return t.urls.EachByUserID(ctx, userID, func(url entity.URL) error {
+			if n++; n%100 == 0 {
+				flush()
+			}
+			return enc.Encode(url)
+		})
+	case TransferFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return err
+		}
+		n := 0
+		err := t.urls.EachByUserID(ctx, userID, func(url entity.URL) error {
+			record, err := csvRecord(url)
+			if err != nil {
+				return err
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+			if n++; n%100 == 0 {
+				cw.Flush()
+				flush()
+			}
+			return cw.Error()
+		})
+		cw.Flush()
+		if err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	return ErrUnknownTransferFormat
+}
+
+func csvRecord(url entity.URL) ([]string, error) {
+	data, err := json.Marshal(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize url: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to serialize url: %w", err)
+	}
+
+	record := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		raw, ok := fields[column]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		if csvStringColumns[column] {
+			json.Unmarshal(raw, &record[i])
+		} else {
+			record[i] = string(raw)
+		}
+	}
+	return record, nil
+}
+
+// Import reads links from r and creates them for userID. Records keep their
+// short url when it is valid and free; otherwise they follow opts.OnConflict.
+func (t *URLTransferService) Import(ctx context.Context, userID int, format string, r io.Reader, opts ImportOptions) (ImportReport, error) {
+	var report ImportReport
+
+	next, err := recordReader(format, r)
+	if err != nil {
+		return report, err
+	}
+
+	var batch []importItem
+	for record := 1; ; record++ {
+		url, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var syntaxErr *recordError
+			if !errors.As(err, &syntaxErr) {
+				return report, err
+			}
+			report.Failed++
+			report.Issues = append(report.Issues, ImportIssue{Record: record, Error: err.Error()})
+			continue
+		}
+
+		item, err := t.prepare(userID, url, opts)
+		if err != nil {
+			report.Failed++
+			report.Issues = append(report.Issues, ImportIssue{Record: record, ShortURL: url.ShortURL, Error: err.Error()})
+			continue
+		}
+		item.record = record
+		batch = append(batch, item)
+
+		if len(batch) == importBatchSize {
+			if err := t.flush(ctx, userID, batch, opts, &report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := t.flush(ctx, userID, batch, opts, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+type importItem struct {
+	record    int
+	requested string
+	url       entity.URL
+	// unprotected marks a protected link imported without its password.
+	unprotected bool
+}
+
+type recordError struct {
+	err error
+}
+
+func (e *recordError) Error() string {
+	return e.err.Error()
+}
+
+// recordReader returns a function yielding one url per call and io.EOF at
+// the end. Malformed records are reported as *recordError.
+func recordReader(format string, r io.Reader) (func() (entity.URL, error), error) {
+	switch format {
+	case TransferFormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		return func() (entity.URL, error) {
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" {
+					continue
+				}
+				var url entity.URL
+				if err := json.Unmarshal([]byte(line), &url); err != nil {
+					return entity.URL{}, &recordError{err: fmt.Errorf("invalid json: %w", err)}
+				}
+				return url, nil
+			}
+			if err := scanner.Err(); err != nil {
+				return entity.URL{}, err
+			}
+			return entity.URL{}, io.EOF
+		}, nil
+	case TransferFormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read csv header: %v", ErrInvalidImport, err)
+		}
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		}
+		return func() (entity.URL, error) {
+			record, err := cr.Read()
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					return entity.URL{}, &recordError{err: err}
+				}
+				return entity.URL{}, err
+			}
+			url, err := urlFromCSV(header, record)
+			if err != nil {
+				return entity.URL{}, &recordError{err: err}
+			}
+			return url, nil
+		}, nil
+	}
+
+	return nil, ErrUnknownTransferFormat
+}
+
+func urlFromCSV(header, record []string) (entity.URL, error) {
+	fields := map[string]any{}
+	for i, column := range header {
+		if i >= len(record) || record[i] == "" {
+			continue
+		}
+		if csvStringColumns[column] {
+			fields[column] = record[i]
+		} else {
+			fields[column] = json.RawMessage(record[i])
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return entity.URL{}, fmt.Errorf("invalid record: %w", err)
+	}
+
+	var url entity.URL
+	if err := json.Unmarshal(data, &url); err != nil {
+		return entity.URL{}, fmt.Errorf("invalid record: %w", err)
+	}
+	return url, nil
+}
+
+// prepare keeps the importable settings of url and runs them through the
+// checks links created through the api get: the url policy, NormalizeRules
+// and screening. A moderator's forced preview is kept.
+func (t *URLTransferService) prepare(userID int, url entity.URL, opts ImportOptions) (importItem, error) {
+	item := importItem{requested: url.ShortURL, unprotected: url.Protected}
+
+	if url.Protected && !opts.Unprotect {
+		return item, ErrProtectedImport
+	}
+
+	if url.ShortURL != "" {
+		if err := ValidateAlias(url.ShortURL); err != nil {
+			if opts.OnConflict != ImportConflictRename {
+				return item, err
+			}
+			url.ShortURL = ""
+		}
+	}
+
+	originalURL, err := t.policy.Normalize(url.OriginalURL)
+	if err != nil {
+		return item, fmt.Errorf("%s: %w", url.OriginalURL, err)
+	}
+	url.OriginalURL = originalURL
+
+	if err := t.policy.NormalizeRules(&url); err != nil {
+		return item, err
+	}
+
+	screening := t.screening.ScreenAll(url.Destinations())
+	if screening.Verdict == entity.ScreeningReject {
+		return item, errors.New("url is not allowed")
+	}
+
+	item.url = entity.URL{
+		ShortURL:       url.ShortURL,
+		OriginalURL:    url.OriginalURL,
+		Title:          truncate(url.Title, MaxTitleLength),
+		Description:    truncate(url.Description, MaxDescriptionLength),
+		Notes:          url.Notes,
+		UserID:         userID,
+		Screening:      screening.Verdict,
+		Preview:        url.Preview,
+		ForcePreview:   url.ForcePreview,
+		RedirectStatus: url.RedirectStatus,
+		ForwardQuery:   url.ForwardQuery,
+		QueryConflict:  url.QueryConflict,
+		ForwardPath:    url.ForwardPath,
+		UTM:            url.UTM,
+		DeviceRules:    url.DeviceRules,
+		GeoRules:       url.GeoRules,
+		Variants:       url.Variants,
+		StickyVariants: url.StickyVariants,
+		ActiveFrom:     url.ActiveFrom,
+		Schedule:       url.Schedule,
+	}
+	if item.url.Title == "" {
+		item.url.MetadataStatus = entity.MetadataPending
+	}
+
+	return item, nil
+}
+
+func (t *URLTransferService) flush(ctx context.Context, userID int, batch []importItem, opts ImportOptions, report *ImportReport) error {
+	if opts.EnforceQuota {
+		allowed, remaining, err := t.quota.ReserveLinks(ctx, userID, len(batch))
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return fmt.Errorf("%w, %d links left today", ErrQuotaExceeded, remaining)
+		}
+	}
+
+	created := 0
+	defer func() {
+		if opts.EnforceQuota {
+			t.quota.ReleaseLinks(ctx, userID, len(batch)-created)
+		}
+	}()
+
+	pending := batch
+	for try := 0; len(pending) > 0 && try <= importRenameTries; try++ {
+		urls := make([]entity.URL, len(pending))
+		for i := range pending {
+			if pending[i].url.ShortURL == "" {
+				pending[i].url.ShortURL = utils.GenerateRandomString()
+			}
+			urls[i] = pending[i].url
+		}
+
+		inserted, err := t.urls.SaveBatch(ctx, urls)
+		if err != nil {
+			return err
+		}
+
+		var entries []entity.URLHistory
+		var retry []importItem
+		for i, ok := range inserted {
+			item := pending[i]
+			if ok {
+				entries = append(entries, Entry(entity.HistoryCreate, nil, urls[i], opts.ActorID, opts.RequestID))
+				if item.unprotected {
+					report.Issues = append(report.Issues, ImportIssue{
+						Record:   item.record,
+						ShortURL: urls[i].ShortURL,
+						Error:    "link was password protected, imported without a password",
+					})
+				}
+			}
+			if !ok && item.requested != "" && item.url.ShortURL == item.requested {
+				flagged, err := t.flaggedInTrash(ctx, userID, item.requested)
+				if err != nil {
+					t.history.Record(ctx, entries...)
+					return err
+				}
+				if flagged {
+					report.Failed++
+					report.Issues = append(report.Issues, ImportIssue{
+						Record:   item.record,
+						ShortURL: item.requested,
+						Error:    "short url belongs to a deleted link a moderator forced a preview on, restore it instead",
+					})
+					continue
+				}
+			}
+			switch {
+			case ok && (item.requested == "" || item.url.ShortURL == item.requested):
+				report.Imported++
+				created++
+			case ok:
+				report.Imported++
+				report.Renamed++
+				created++
+				report.Issues = append(report.Issues, ImportIssue{
+					Record:      item.record,
+					ShortURL:    item.requested,
+					NewShortURL: item.url.ShortURL,
+					Error:       "short url is taken or invalid, imported under a new one",
+				})
+			case item.requested != "" && item.url.ShortURL == item.requested && opts.OnConflict != ImportConflictRename:
+				report.Skipped++
+				report.Issues = append(report.Issues, ImportIssue{
+					Record:   item.record,
+					ShortURL: item.requested,
+					Error:    "short url is already taken",
+				})
+			default:
+				item.url.ShortURL = ""
+				retry = append(retry, item)
+			}
+		}
+		pending = retry
+
+		// The links are in, a failed history write is only logged.
+		t.history.Record(ctx, entries...)
+	}
+
+	for _, item := range pending {
+		report.Failed++
+		report.Issues = append(report.Issues, ImportIssue{
+			Record:   item.record,
+			ShortURL: item.requested,
+			Error:    "failed to generate a unique short url",
+		})
+	}
+
+	return nil
+}
+
+// flaggedInTrash reports whether shortURL belongs to one of the user's deleted
+// links that a moderator forced a preview on. Importing such a link under a
+// new short url would shed the flag.
+func (t *URLTransferService) flaggedInTrash(ctx context.Context, userID int, shortURL string) (bool, error) {
+	deleted, err := t.urls.DeletedByShortURL(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return deleted.UserID == userID && deleted.ForcePreview, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// transferRepo stands in for the urls table, only what importing uses is
+// implemented. Short urls in trash are taken like live ones.
+type transferRepo struct {
+	repository.URL
+	urls  map[string]entity.URL
+	trash map[string]entity.URL
+}
+
+func (r *transferRepo) SaveBatch(ctx context.Context, urls []entity.URL) ([]bool, error) {
+	inserted := make([]bool, len(urls))
+	for i, url := range urls {
+		if _, ok := r.urls[url.ShortURL]; ok {
+			continue
+		}
+		if _, ok := r.trash[url.ShortURL]; ok {
+			continue
+		}
+		r.urls[url.ShortURL] = url
+		inserted[i] = true
+	}
+	return inserted, nil
+}
+
+func (r *transferRepo) DeletedByShortURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	url, ok := r.trash[shortURL]
+	if !ok {
+		return entity.URL{}, pgx.ErrNoRows
+	}
+	return url, nil
+}
+
+type transferHistory struct {
+	repository.URLHistory
+}
+
+func (transferHistory) Save(ctx context.Context, entries []entity.URLHistory) error {
+	return nil
+}
+
+func newTransferService(trash ...entity.URL) (*URLTransferService, *transferRepo) {
+	repo := &transferRepo{urls: map[string]entity.URL{}, trash: map[string]entity.URL{}}
+	for _, url := range trash {
+		repo.trash[url.ShortURL] = url
+	}
+
+	policy := NewURLPolicyService("https://kuchak.test", []string{"http", "https"}, 2048, nil, nil, nil)
+	return NewURLTransferService(repo, policy, NewScreeningService(repo, nil), nil, NewURLHistoryService(transferHistory{})), repo
+}
+
+func importNDJSON(t *testing.T, s *URLTransferService, opts ImportOptions, records ...string) ImportReport {
+	t.Helper()
+
+	report, err := s.Import(context.Background(), 1, TransferFormatNDJSON, strings.NewReader(strings.Join(records, "\n")), opts)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	return report
+}
+
+func TestImportChecksRules(t *testing.T) {
+	var variants []string
+	for i := 0; i <= MaxVariants; i++ {
+		variants = append(variants, fmt.Sprintf(`{"name": "v%d", "destination": "https://example.com/%d", "weight": 1}`, i, i))
+	}
+
+	tests := []struct {
+		name   string
+		record string
+	}{
+		{name: "redirect status", record: `"redirect_status": 303`},
+		{name: "query conflict", record: `"query_conflict": "merge"`},
+		{name: "too many variants", record: `"variants": [` + strings.Join(variants, ",") + `]`},
+		{name: "duplicate variant names", record: `"variants": [{"name": "a", "destination": "https://a.example.com/"}, {"name": "a", "destination": "https://b.example.com/"}]`},
+		{name: "variant name", record: `"variants": [{"name": "a b", "destination": "https://a.example.com/"}]`},
+		{name: "variant weight", record: `"variants": [{"name": "a", "destination": "https://a.example.com/", "weight": 10001}]`},
+		{name: "device os", record: `"device_rules": [{"os": "symbian", "destination": "https://a.example.com/"}]`},
+		{name: "device type", record: `"device_rules": [{"device": "watch", "destination": "https://a.example.com/"}]`},
+		{name: "country code", record: `"geo_rules": [{"countries": ["XX"], "destination": "https://a.example.com/"}]`},
+		{name: "no countries", record: `"geo_rules": [{"countries": [], "destination": "https://a.example.com/"}]`},
+		{name: "schedule order", record: `"schedule": [{"from": "2026-02-01T00:00:00Z", "until": "2026-01-01T00:00:00Z", "destination": "https://a.example.com/"}]`},
+		{name: "utm length", record: `"utm": {"source": "` + strings.Repeat("s", MaxUTMLength+1) + `"}`},
+		{name: "rule destination", record: `"geo_rules": [{"countries": ["DE"], "destination": "ftp://example.de/"}]`},
+	}
+
+	for _, tt := range tests {
+		s, repo := newTransferService()
+
+		report := importNDJSON(t, s, ImportOptions{OnConflict: ImportConflictSkip}, `{"short_url": "imported", "original_url": "https://example.com/", `+tt.record+`}`)
+		if report.Failed != 1 || len(repo.urls) != 0 {
+			t.Errorf("%s: imported %d and failed %d records, want the record refused", tt.name, report.Imported, report.Failed)
+		}
+	}
+}
+
+func TestImportNormalizesRules(t *testing.T) {
+	s, repo := newTransferService()
+
+	report := importNDJSON(t, s, ImportOptions{OnConflict: ImportConflictSkip},
+		`{"short_url": "imported", "original_url": "HTTPS://Example.com", "geo_rules": [{"countries": ["DE"], "destination": "HTTPS://Example.DE:443/"}], "variants": [{"name": "a", "destination": "https://A.example.com", "weight": 1}]}`)
+	if report.Imported != 1 {
+		t.Fatalf("imported %d records, issues %v", report.Imported, report.Issues)
+	}
+
+	url := repo.urls["imported"]
+	if got := url.Destinations(); strings.Join(got, " ") != "https://example.com https://example.de/ https://a.example.com" {
+		t.Errorf("destinations = %v, want them normalized", got)
+	}
+}
+
+func TestImportKeepsForcePreview(t *testing.T) {
+	s, repo := newTransferService()
+
+	report := importNDJSON(t, s, ImportOptions{OnConflict: ImportConflictSkip},
+		`{"short_url": "flagged", "original_url": "https://example.com/", "force_preview": true}`)
+	if report.Imported != 1 {
+		t.Fatalf("imported %d records, issues %v", report.Imported, report.Issues)
+	}
+	if !repo.urls["flagged"].ForcePreview {
+		t.Error("imported link lost its forced preview")
+	}
+}
+
+func TestImportRefusesFlaggedTrash(t *testing.T) {
+	trash := []entity.URL{
+		{ShortURL: "flagged", OriginalURL: "https://example.com/", UserID: 1, ForcePreview: true},
+		{ShortURL: "deleted", OriginalURL: "https://example.com/", UserID: 1},
+	}
+	s, repo := newTransferService(trash...)
+
+	report := importNDJSON(t, s, ImportOptions{OnConflict: ImportConflictRename},
+		`{"short_url": "flagged", "original_url": "https://example.com/"}`,
+		`{"short_url": "deleted", "original_url": "https://example.com/"}`)
+
+	if report.Failed != 1 || report.Renamed != 1 || len(repo.urls) != 1 {
+		t.Fatalf("failed %d and renamed %d records, want the flagged one refused and the other renamed", report.Failed, report.Renamed)
+	}
+	if issue := report.Issues[0]; issue.Record != 1 || issue.ShortURL != "flagged" {
+		t.Errorf("first issue is %+v, want record 1 refused", issue)
+	}
+}
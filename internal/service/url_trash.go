@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/repository"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TrashService purges deleted links once they have been in the trash for
+// longer than the retention period.
+type TrashService struct {
+	urls      repository.URL
+	retention time.Duration
+}
+
+func NewTrashService(urls repository.URL, retention time.Duration) *TrashService {
+	return &TrashService{
+		urls:      urls,
+		retention: retention,
+	}
+}
+
+// Retention is how long deleted links can still be restored.
+func (t *TrashService) Retention() time.Duration {
+	return t.retention
+}
+
+// Run purges expired links every interval. It blocks until ctx is done.
+func (t *TrashService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := t.Purge(ctx); err != nil {
+			log.Err(err).Msg("failed to purge trash")
+		}
+	}
+}
+
+func (t *TrashService) Purge(ctx context.Context) error {
+	purged, err := t.urls.PurgeDeleted(ctx, time.Now().Add(-t.retention))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		log.Info().Int64("purged", purged).Msg("trash purged")
+	}
+
+	return nil
+}
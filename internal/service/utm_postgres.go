@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"kuchak/internal/entity"
+	"kuchak/internal/repository"
+)
+
+type UTMTemplatePostgresService struct {
+	repo repository.UTMTemplate
+}
+
+func NewUTMTemplatePostgresService(repo repository.UTMTemplate) *UTMTemplatePostgresService {
+	return &UTMTemplatePostgresService{repo: repo}
+}
+
+func (u *UTMTemplatePostgresService) GetTemplateByID(ctx context.Context, ID int) (entity.UTMTemplate, error) {
+	return u.repo.ByID(ctx, ID)
+}
+
+func (u *UTMTemplatePostgresService) GetTemplatesByUserID(ctx context.Context, userID int) ([]entity.UTMTemplate, error) {
+	return u.repo.ByUserID(ctx, userID)
+}
+
+func (u *UTMTemplatePostgresService) CreateTemplate(ctx context.Context, template entity.UTMTemplate) (entity.UTMTemplate, error) {
+	return u.repo.Save(ctx, template)
+}
+
+func (u *UTMTemplatePostgresService) UpdateTemplate(ctx context.Context, template entity.UTMTemplate) error {
+	return u.repo.Update(ctx, template)
+}
+
+func (u *UTMTemplatePostgresService) DeleteTemplate(ctx context.Context, template entity.UTMTemplate) error {
+	return u.repo.Delete(ctx, template)
+}
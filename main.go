@@ -1,7 +1,21 @@
 package main
 
-import "kuchak/cmd"
+import (
+	"kuchak/cmd"
+	"os"
+)
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			cmd.Export(os.Args[2:])
+			return
+		case "import":
+			cmd.Import(os.Args[2:])
+			return
+		}
+	}
+
 	cmd.Serve()
 }
@@ -1,19 +1,158 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrPasswordMismatch = errors.New("password does not match")
+	ErrUnknownHash      = errors.New("unknown password hash format")
+)
+
+// Argon2Params tune argon2id, Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106 with
+// a little more memory.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordParams are used for new hashes, a stored hash made with other
+// parameters is reported by PasswordNeedsRehash.
+var PasswordParams = DefaultArgon2Params
+
+// Validate rejects parameters argon2 can not work with or that are too weak
+// to be worth storing.
+func (p Argon2Params) Validate() error {
+	switch {
+	case p.Iterations < 1:
+		return errors.New("argon2 iterations must be at least 1")
+	case p.Parallelism < 1:
+		return errors.New("argon2 parallelism must be at least 1")
+	case p.Memory < 8*uint32(p.Parallelism):
+		return errors.New("argon2 memory must be at least 8 KiB per lane")
+	case p.SaltLength < 16:
+		return errors.New("argon2 salt must be at least 16 bytes")
+	case p.KeyLength < 16:
+		return errors.New("argon2 key must be at least 16 bytes")
+	}
+	return nil
+}
+
+// PasswordHash hashes password with argon2id and PasswordParams, encoded in
+// the PHC string format.
 func PasswordHash(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Err(err).Msg("failed to generate hash from password")
+	p := PasswordParams
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		log.Err(err).Msg("failed to generate password salt")
 		return "", err
 	}
-	return string(hashedPassword), nil
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
 }
 
+// PasswordVerify checks plainPassword against an argon2id or a bcrypt hash.
 func PasswordVerify(hashedPassword, plainPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
+	if strings.HasPrefix(hashedPassword, "$argon2id$") {
+		p, salt, key, err := decodeArgon2(hashedPassword)
+		if err != nil {
+			return err
+		}
+
+		other := argon2.IDKey([]byte(plainPassword), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	if isBcrypt(hashedPassword) {
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
+	}
+
+	return ErrUnknownHash
+}
+
+// PasswordNeedsRehash reports whether a hash that just verified should be
+// replaced, because it is bcrypt or made with other argon2 parameters.
+func PasswordNeedsRehash(hashedPassword string) bool {
+	p, salt, _, err := decodeArgon2(hashedPassword)
+	if err != nil {
+		return isBcrypt(hashedPassword)
+	}
+
+	return p.Memory != PasswordParams.Memory ||
+		p.Iterations != PasswordParams.Iterations ||
+		p.Parallelism != PasswordParams.Parallelism ||
+		p.KeyLength != PasswordParams.KeyLength ||
+		uint32(len(salt)) != PasswordParams.SaltLength
+}
+
+func isBcrypt(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") || strings.HasPrefix(hashedPassword, "$2b$") || strings.HasPrefix(hashedPassword, "$2y$")
+}
+
+// decodeArgon2 parses $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>.
+func decodeArgon2(hashedPassword string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 parameters %q: %w", parts[3], err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 key: %w", err)
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	// A stored hash is trusted only so far, parameters far beyond anything we
+	// would write are refused rather than run.
+	if p.Iterations < 1 || p.Parallelism < 1 || len(key) < 1 || p.Memory > 4*1024*1024 || p.Iterations > 100 {
+		return p, nil, nil, fmt.Errorf("argon2 parameters out of range %q", parts[3])
+	}
+
+	return p, salt, key, nil
 }
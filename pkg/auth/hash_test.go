@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheapArgon2Params keep the tests fast, PasswordParams is set to them for
+// the duration of a test.
+var cheapArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   16,
+}
+
+func withPasswordParams(t *testing.T, p Argon2Params) {
+	t.Helper()
+
+	previous := PasswordParams
+	PasswordParams = p
+	t.Cleanup(func() { PasswordParams = previous })
+}
+
+func passwordHash(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := PasswordHash(password)
+	if err != nil {
+		t.Fatalf("PasswordHash: %v", err)
+	}
+	return hash
+}
+
+func TestPasswordVerify(t *testing.T) {
+	withPasswordParams(t, cheapArgon2Params)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		wantErr  error
+	}{
+		{name: "argon2", hash: passwordHash(t, "secret"), password: "secret"},
+		{name: "argon2 mismatch", hash: passwordHash(t, "secret"), password: "Secret", wantErr: ErrPasswordMismatch},
+		{name: "bcrypt", hash: string(bcryptHash), password: "secret"},
+		{name: "bcrypt mismatch", hash: string(bcryptHash), password: "Secret", wantErr: bcrypt.ErrMismatchedHashAndPassword},
+		{name: "unknown", hash: "secret", password: "secret", wantErr: ErrUnknownHash},
+		{name: "empty", hash: "", password: "", wantErr: ErrUnknownHash},
+	}
+
+	for _, tt := range tests {
+		err := PasswordVerify(tt.hash, tt.password)
+		if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPasswordHashIsSalted(t *testing.T) {
+	withPasswordParams(t, cheapArgon2Params)
+
+	if passwordHash(t, "secret") == passwordHash(t, "secret") {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	withPasswordParams(t, cheapArgon2Params)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := passwordHash(t, "secret")
+
+	changed := func(change func(p *Argon2Params)) Argon2Params {
+		p := cheapArgon2Params
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		params Argon2Params
+		hash   string
+		want   bool
+	}{
+		{name: "current", params: cheapArgon2Params, hash: current, want: false},
+		{name: "bcrypt", params: cheapArgon2Params, hash: string(bcryptHash), want: true},
+		{name: "unknown", params: cheapArgon2Params, hash: "secret", want: false},
+		{name: "memory", params: changed(func(p *Argon2Params) { p.Memory *= 2 }), hash: current, want: true},
+		{name: "iterations", params: changed(func(p *Argon2Params) { p.Iterations++ }), hash: current, want: true},
+		{name: "parallelism", params: changed(func(p *Argon2Params) { p.Parallelism++ }), hash: current, want: true},
+		{name: "salt length", params: changed(func(p *Argon2Params) { p.SaltLength = 32 }), hash: current, want: true},
+		{name: "key length", params: changed(func(p *Argon2Params) { p.KeyLength = 32 }), hash: current, want: true},
+	}
+
+	for _, tt := range tests {
+		PasswordParams = tt.params
+		if got := PasswordNeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: PasswordNeedsRehash = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestPasswordUpgrade follows a login with a bcrypt hash: it verifies, is
+// replaced by an argon2id one, and that one verifies and is kept.
+func TestPasswordUpgrade(t *testing.T) {
+	withPasswordParams(t, cheapArgon2Params)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PasswordVerify(string(bcryptHash), "secret"); err != nil {
+		t.Fatalf("bcrypt hash does not verify: %v", err)
+	}
+	if !PasswordNeedsRehash(string(bcryptHash)) {
+		t.Fatal("bcrypt hash is not upgraded")
+	}
+
+	upgraded := passwordHash(t, "secret")
+	if err := PasswordVerify(upgraded, "secret"); err != nil {
+		t.Fatalf("upgraded hash does not verify: %v", err)
+	}
+	if err := PasswordVerify(upgraded, "other"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("upgraded hash accepts another password: %v", err)
+	}
+	if PasswordNeedsRehash(upgraded) {
+		t.Error("upgraded hash needs another rehash")
+	}
+}
+
+func TestDecodeArgon2(t *testing.T) {
+	const (
+		salt = "c2FsdHNhbHRzYWx0c2FsdA"
+		key  = "a2V5a2V5a2V5a2V5a2V5aw"
+	)
+
+	p, gotSalt, gotKey, err := decodeArgon2("$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key)
+	if err != nil {
+		t.Fatalf("decodeArgon2: %v", err)
+	}
+	want := Argon2Params{Memory: 65536, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 16}
+	if p != want || string(gotSalt) != "saltsaltsaltsalt" || string(gotKey) != "keykeykeykeykeyk" {
+		t.Errorf("decoded %+v %q %q", p, gotSalt, gotKey)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "bcrypt", hash: "$2a$10$abcdefghijklmnopqrstuv"},
+		{name: "argon2i", hash: "$argon2i$v=19$m=65536,t=3,p=2$" + salt + "$" + key},
+		{name: "missing key", hash: "$argon2id$v=19$m=65536,t=3,p=2$" + salt},
+		{name: "extra part", hash: "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key + "$"},
+		{name: "leading text", hash: "x$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key},
+		{name: "old version", hash: "$argon2id$v=16$m=65536,t=3,p=2$" + salt + "$" + key},
+		{name: "no version", hash: "$argon2id$m=65536,t=3,p=2$" + salt + "$" + key + "$"},
+		{name: "parameters", hash: "$argon2id$v=19$m=lots,t=3,p=2$" + salt + "$" + key},
+		{name: "negative memory", hash: "$argon2id$v=19$m=-1,t=3,p=2$" + salt + "$" + key},
+		{name: "parallelism overflow", hash: "$argon2id$v=19$m=65536,t=3,p=256$" + salt + "$" + key},
+		{name: "salt", hash: "$argon2id$v=19$m=65536,t=3,p=2$not base64!$" + key},
+		{name: "key", hash: "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$not base64!"},
+		{name: "empty key", hash: "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$"},
+		{name: "no iterations", hash: "$argon2id$v=19$m=65536,t=0,p=2$" + salt + "$" + key},
+		{name: "no parallelism", hash: "$argon2id$v=19$m=65536,t=3,p=0$" + salt + "$" + key},
+		{name: "too much memory", hash: "$argon2id$v=19$m=4194305,t=3,p=2$" + salt + "$" + key},
+		{name: "too many iterations", hash: "$argon2id$v=19$m=65536,t=101,p=2$" + salt + "$" + key},
+	}
+
+	for _, tt := range tests {
+		if _, _, _, err := decodeArgon2(tt.hash); err == nil {
+			t.Errorf("%s: decodeArgon2 accepted %q", tt.name, tt.hash)
+		}
+		if err := PasswordVerify(tt.hash, "secret"); err == nil {
+			t.Errorf("%s: PasswordVerify accepted %q", tt.name, tt.hash)
+		}
+	}
+}
+
+func TestArgon2ParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Argon2Params
+		wantErr bool
+	}{
+		{name: "default", params: DefaultArgon2Params},
+		{name: "cheap", params: cheapArgon2Params},
+		{name: "no iterations", params: Argon2Params{Memory: 64, Parallelism: 1, SaltLength: 16, KeyLength: 16}, wantErr: true},
+		{name: "no parallelism", params: Argon2Params{Memory: 64, Iterations: 1, SaltLength: 16, KeyLength: 16}, wantErr: true},
+		{name: "memory per lane", params: Argon2Params{Memory: 15, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 16}, wantErr: true},
+		{name: "short salt", params: Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}, wantErr: true},
+		{name: "short key", params: Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 8}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
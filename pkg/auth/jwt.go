@@ -14,38 +14,74 @@ const (
 	RefreshTokenExp = time.Hour * 24 * 7 // 7 days
 )
 
+// TokenType tells access and refresh tokens apart now that both are signed
+// with the same keys.
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	TokenType TokenType `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user entity.User, secret string, expiration time.Duration) (string, error) {
+// GenerateToken signs a token for user with the current key of the set.
+func (k *KeySet) GenerateToken(user entity.User, tokenType TokenType, expiration time.Duration) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenType: tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    k.issuer,
+			Audience:  jwt.ClaimStrings{k.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	token := jwt.NewWithClaims(k.current.method, claims)
+	token.Header["kid"] = k.current.ID
+	return token.SignedString(k.current.key)
 }
 
-func ValidateToken(tokenStr, secret string) (*Claims, error) {
+// ValidateToken checks a token against the key its kid names, and that it
+// was issued by us, for us, as tokenType.
+func (k *KeySet) ValidateToken(tokenStr string, tokenType TokenType) (*Claims, error) {
 	claims := &Claims{}
 
 	t, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.verificationKey(kid, time.Now())
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method != key.method {
+			return nil, errors.New("signing method does not match the key")
+		}
+		return key.key.Public(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(k.issuer),
+		jwt.WithAudience(k.audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil || !t.Valid {
 		log.Err(err).Msg("failed to parse jwt")
 		return nil, errors.New("invalid token")
 	}
 
+	if claims.TokenType != tokenType {
+		log.Error().Str("token_type", string(claims.TokenType)).Msg("unexpected jwt type")
+		return nil, errors.New("invalid token")
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"kuchak/internal/entity"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newKeySet(t *testing.T, issuer, audience string, current *SigningKey, previous ...*SigningKey) *KeySet {
+	t.Helper()
+
+	set, err := NewKeySet(issuer, audience, current, previous...)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return set
+}
+
+func generateToken(t *testing.T, set *KeySet, tokenType TokenType, expiration time.Duration) string {
+	t.Helper()
+
+	token, err := set.GenerateToken(entity.User{ID: 7, Email: "user@example.com"}, tokenType, expiration)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestGenerateTokenUsesCurrentKey(t *testing.T) {
+	current := newEd25519Key(t, "new")
+	set := newKeySet(t, "kuchak", "kuchak", current, newEd25519Key(t, "old"))
+
+	token := generateToken(t, set, AccessToken, AccessTokenExp)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != "new" {
+		t.Errorf("kid = %v, want new", kid)
+	}
+	if parsed.Method != current.method {
+		t.Errorf("alg = %s, want %s", parsed.Method.Alg(), current.method.Alg())
+	}
+
+	claims, err := set.ValidateToken(token, AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != 7 || claims.Email != "user@example.com" || claims.TokenType != AccessToken {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	rsaKey, err := NewSigningKey("rsa", testRSAKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := newEd25519Key(t, "old")
+	retired := newEd25519Key(t, "retired")
+	current := newEd25519Key(t, "current")
+
+	// Tokens signed before the rotation, by what was the current key then.
+	oldToken := generateToken(t, newKeySet(t, "kuchak", "kuchak", old), AccessToken, AccessTokenExp)
+	retiredToken := generateToken(t, newKeySet(t, "kuchak", "kuchak", retired), AccessToken, AccessTokenExp)
+	unknownToken := generateToken(t, newKeySet(t, "kuchak", "kuchak", newEd25519Key(t, "unknown")), AccessToken, AccessTokenExp)
+
+	// A token naming a known kid but signed with another key.
+	forged := generateToken(t, newKeySet(t, "kuchak", "kuchak", newEd25519Key(t, "old")), AccessToken, AccessTokenExp)
+
+	// An EdDSA token naming the RSA key.
+	confused := generateToken(t, newKeySet(t, "kuchak", "kuchak", newEd25519Key(t, "rsa")), AccessToken, AccessTokenExp)
+
+	retired.RetiresAt = time.Now().Add(-time.Minute)
+	set := newKeySet(t, "kuchak", "kuchak", current, old, retired, rsaKey)
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType TokenType
+		wantErr   bool
+	}{
+		{name: "current key", token: generateToken(t, set, AccessToken, AccessTokenExp), tokenType: AccessToken},
+		{name: "previous key", token: oldToken, tokenType: AccessToken},
+		{name: "retired key", token: retiredToken, tokenType: AccessToken, wantErr: true},
+		{name: "unknown key", token: unknownToken, tokenType: AccessToken, wantErr: true},
+		{name: "wrong key for kid", token: forged, tokenType: AccessToken, wantErr: true},
+		{name: "wrong algorithm for kid", token: confused, tokenType: AccessToken, wantErr: true},
+		{name: "other issuer", token: generateToken(t, newKeySet(t, "other", "kuchak", current), AccessToken, AccessTokenExp), tokenType: AccessToken, wantErr: true},
+		{name: "other audience", token: generateToken(t, newKeySet(t, "kuchak", "other", current), AccessToken, AccessTokenExp), tokenType: AccessToken, wantErr: true},
+		{name: "expired", token: generateToken(t, set, AccessToken, -time.Minute), tokenType: AccessToken, wantErr: true},
+		{name: "refresh as access", token: generateToken(t, set, RefreshToken, RefreshTokenExp), tokenType: AccessToken, wantErr: true},
+		{name: "access as refresh", token: generateToken(t, set, AccessToken, AccessTokenExp), tokenType: RefreshToken, wantErr: true},
+		{name: "refresh", token: generateToken(t, set, RefreshToken, RefreshTokenExp), tokenType: RefreshToken},
+		{name: "garbage", token: "not.a.token", tokenType: AccessToken, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := set.ValidateToken(tt.token, tt.tokenType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
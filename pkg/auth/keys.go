@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"kuchak/pkg/jwk"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported signing key")
+	ErrUnknownKey     = errors.New("unknown signing key")
+)
+
+// minRSABits is the smallest RSA key accepted for signing tokens.
+const minRSABits = 2048
+
+// SigningKey is a private key tokens are signed with, RSA keys sign with
+// RS256 and Ed25519 keys with EdDSA.
+type SigningKey struct {
+	ID     string
+	key    crypto.Signer
+	method jwt.SigningMethod
+	// RetiresAt is when a previous key stops being accepted, zero keeps
+	// it until it is removed from the set.
+	RetiresAt time.Time
+}
+
+// NewSigningKey wraps key, an empty id is replaced by the RFC 7638
+// thumbprint of the public key.
+func NewSigningKey(id string, key crypto.Signer) (*SigningKey, error) {
+	var method jwt.SigningMethod
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("%w: rsa key is %d bits, at least %d are needed", ErrUnsupportedKey, k.N.BitLen(), minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+
+	if id == "" {
+		public, err := jwk.FromPublicKey(key.Public())
+		if err != nil {
+			return nil, err
+		}
+		id = public.Thumbprint()
+	}
+
+	return &SigningKey{ID: id, key: key, method: method}, nil
+}
+
+// ParsePrivateKey reads a PEM encoded PKCS #8 or PKCS #1 private key.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+	return signer, nil
+}
+
+// KeySet signs tokens with its current key and accepts tokens signed by
+// any of its keys, so a new key can take over while tokens signed by the
+// previous ones are still around.
+type KeySet struct {
+	issuer   string
+	audience string
+	current  *SigningKey
+	keys     map[string]*SigningKey
+}
+
+func NewKeySet(issuer, audience string, current *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	keys := map[string]*SigningKey{current.ID: current}
+	for _, key := range previous {
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		keys[key.ID] = key
+	}
+
+	return &KeySet{
+		issuer:   issuer,
+		audience: audience,
+		current:  current,
+		keys:     keys,
+	}, nil
+}
+
+func (k *KeySet) verificationKey(kid string, now time.Time) (*SigningKey, bool) {
+	key, ok := k.keys[kid]
+	if !ok || (!key.RetiresAt.IsZero() && now.After(key.RetiresAt)) {
+		return nil, false
+	}
+	return key, true
+}
+
+// JWKS is the public half of the keys still accepted, for other services
+// to verify our tokens with.
+func (k *KeySet) JWKS() jwk.Set {
+	set := jwk.Set{Keys: []jwk.Key{}}
+	now := time.Now()
+
+	add := func(key *SigningKey) {
+		public, err := jwk.FromPublicKey(key.key.Public())
+		if err != nil {
+			return
+		}
+		public.Kid = key.ID
+		public.Use = "sig"
+		public.Alg = key.method.Alg()
+		set.Keys = append(set.Keys, public)
+	}
+
+	// The current key goes first, the rest follow by id so the document
+	// does not change between requests.
+	add(k.current)
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		if id != k.current.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if key, ok := k.verificationKey(id, now); ok {
+			add(key)
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"kuchak/pkg/jwk"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	rsaKeyOnce sync.Once
+	rsaKey     *rsa.PrivateKey
+)
+
+// testRSAKey is generated once, RSA keys are slow to make.
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	rsaKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			t.Fatalf("failed to generate rsa key: %v", err)
+		}
+		rsaKey = key
+	})
+	return rsaKey
+}
+
+func newEd25519Key(t *testing.T, id string) *SigningKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	key, err := NewSigningKey(id, private)
+	if err != nil {
+		t.Fatalf("NewSigningKey: %v", err)
+	}
+	return key
+}
+
+func TestNewSigningKey(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     crypto.Signer
+		wantAlg string
+		wantErr error
+	}{
+		{name: "rsa", key: testRSAKey(t), wantAlg: "RS256"},
+		{name: "ed25519", key: newEd25519Key(t, "x").key, wantAlg: "EdDSA"},
+		{name: "short rsa", key: small, wantErr: ErrUnsupportedKey},
+		{name: "ecdsa", key: ec, wantErr: ErrUnsupportedKey},
+	}
+
+	for _, tt := range tests {
+		key, err := NewSigningKey("", tt.key)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		public, err := jwk.FromPublicKey(tt.key.Public())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if key.ID != public.Thumbprint() {
+			t.Errorf("%s: id = %q, want the key thumbprint %q", tt.name, key.ID, public.Thumbprint())
+		}
+		if key.method.Alg() != tt.wantAlg {
+			t.Errorf("%s: alg = %s, want %s", tt.name, key.method.Alg(), tt.wantAlg)
+		}
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(newEd25519Key(t, "x").key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "pkcs1", data: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testRSAKey(t))})},
+		{name: "pkcs8", data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+		{name: "not pem", data: []byte("secret"), wantErr: true},
+		{name: "not a key", data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("secret")}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParsePrivateKey(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewKeySetRejectsDuplicateIDs(t *testing.T) {
+	if _, err := NewKeySet("kuchak", "kuchak", newEd25519Key(t, "a"), newEd25519Key(t, "a")); err == nil {
+		t.Error("NewKeySet accepted two keys with the same id")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaSigningKey, err := NewSigningKey("r", testRSAKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := newEd25519Key(t, "m")
+	retired := newEd25519Key(t, "a")
+	retired.RetiresAt = time.Now().Add(-time.Minute)
+	retiring := newEd25519Key(t, "z")
+	retiring.RetiresAt = time.Now().Add(time.Hour)
+
+	set, err := NewKeySet("kuchak", "kuchak", current, retiring, retired, rsaSigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := set.JWKS().Keys
+
+	// The current key first, then the ones still accepted by id.
+	want := []*SigningKey{current, rsaSigningKey, retiring}
+	if len(keys) != len(want) {
+		t.Fatalf("JWKS has %d keys, want %d", len(keys), len(want))
+	}
+	for i, key := range keys {
+		if key.Kid != want[i].ID || key.Use != "sig" || key.Alg != want[i].method.Alg() {
+			t.Errorf("key %d: kid %q use %q alg %q, want kid %q use sig alg %s", i, key.Kid, key.Use, key.Alg, want[i].ID, want[i].method.Alg())
+		}
+
+		public, err := key.PublicKey()
+		if err != nil {
+			t.Errorf("key %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(public, want[i].key.Public()) {
+			t.Errorf("key %d: public key does not match %q", i, want[i].ID)
+		}
+	}
+}
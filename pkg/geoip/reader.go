@@ -0,0 +1,376 @@
+// Package geoip reads MaxMind DB (mmdb) files, such as GeoLite2-Country,
+// without any external dependency.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+var ErrInvalidDatabase = errors.New("invalid mmdb database")
+
+type Reader struct {
+	tree       []byte
+	data       decoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mmdb file: %w", err)
+	}
+	return FromBytes(buf)
+}
+
+func FromBytes(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+
+	meta := decoder{buf: buf[i+len(metadataMarker):]}
+	value, _, err := meta.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	metadata, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{
+		nodeCount:  uint(toUint(metadata["node_count"])),
+		recordSize: uint(toUint(metadata["record_size"])),
+		ipVersion:  uint(toUint(metadata["ip_version"])),
+		BuildEpoch: toUint(metadata["build_epoch"]),
+	}
+	r.DatabaseType, _ = metadata["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, r.recordSize)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, fmt.Errorf("%w: search tree is truncated", ErrInvalidDatabase)
+	}
+
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+16 : i]}
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Lookup returns the record stored for ip, or nil when the database has no
+// entry for it.
+func (r *Reader) Lookup(ip net.IP) (any, error) {
+	var bits []byte
+	node := uint(0)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if ip16 := ip.To16(); ip16 != nil {
+		if r.ipVersion == 4 {
+			return nil, nil
+		}
+		bits = ip16
+	} else {
+		return nil, fmt.Errorf("invalid ip address: %v", ip)
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i>>3] >> (7 - uint(i&7))) & 1
+		node = r.readNode(node, uint(bit))
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, fmt.Errorf("%w: search tree ended inside the tree", ErrInvalidDatabase)
+	}
+
+	value, _, err := r.data.decode(node - r.nodeCount - 16)
+	return value, err
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country ip is located
+// in, falling back to the registered country, or "" when it is unknown.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	record, err := r.Lookup(ip)
+	if err != nil {
+		return "", err
+	}
+
+	fields, _ := record.(map[string]any)
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := fields[key].(map[string]any)
+		if code, ok := country["iso_code"].(string); ok && code != "" {
+			return code, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (r *Reader) readNode(node, index uint) uint {
+	base := node * r.recordSize / 4
+	if base+r.recordSize/4 > uint(len(r.tree)) {
+		// A corrupt tree ends the walk as "not found".
+		return r.nodeCount
+	}
+
+	b := r.tree[base:]
+	switch r.recordSize {
+	case 24:
+		off := index * 3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		if index == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := index * 4
+		return uint(binary.BigEndian.Uint32(b[off:]))
+	}
+}
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds how deeply maps, arrays and pointers may nest, so a
+// corrupt or self referencing database fails instead of overflowing the
+// stack.
+const maxDepth = 32
+
+type decoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset and returns it along with the offset
+// right after it.
+func (d *decoder) decode(offset uint) (any, uint, error) {
+	return d.decodeAt(offset, 0)
+}
+
+func (d *decoder) decodeAt(offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: data nested deeper than %d", ErrInvalidDatabase, maxDepth)
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: offset %d out of range", ErrInvalidDatabase, offset)
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// The spec does not allow a pointer to point at another pointer.
+		if pointer < uint(len(d.buf)) && d.buf[pointer]>>5 == typePointer {
+			return nil, 0, fmt.Errorf("%w: pointer at %d points to a pointer", ErrInvalidDatabase, offset-1)
+		}
+		value, _, err := d.decodeAt(pointer, depth+1)
+		return value, next, err
+	}
+
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, fmt.Errorf("%w: truncated extended type", ErrInvalidDatabase)
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typeMap:
+		// Every entry takes at least two bytes, a larger size is corrupt and
+		// must not decide how much is allocated.
+		if size > (uint(len(d.buf))-offset)/2 {
+			return nil, 0, fmt.Errorf("%w: map at %d is truncated", ErrInvalidDatabase, offset)
+		}
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrInvalidDatabase)
+			}
+			value, next, err := d.decodeAt(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		if size > uint(len(d.buf))-offset {
+			return nil, 0, fmt.Errorf("%w: array at %d is truncated", ErrInvalidDatabase, offset)
+		}
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: value at %d is truncated", ErrInvalidDatabase, offset)
+	}
+	payload := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(payload), next, nil
+	case typeBytes:
+		return append([]byte(nil), payload...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: invalid double size %d", ErrInvalidDatabase, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: invalid float size %d", ErrInvalidDatabase, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(payload)), next, nil
+	case typeUint16, typeUint32, typeUint64, typeUint128:
+		// uint128 values do not fit, keep their low 64 bits.
+		var v uint64
+		for _, b := range payload {
+			v = v<<8 | uint64(b)
+		}
+		return v, next, nil
+	case typeInt32:
+		var v uint32
+		for _, b := range payload {
+			v = v<<8 | uint32(b)
+		}
+		if size < 4 && size > 0 && payload[0]&0x80 != 0 {
+			v |= math.MaxUint32 << (8 * size)
+		}
+		return int32(v), next, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: unknown type %d", ErrInvalidDatabase, typ)
+}
+
+func (d *decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("%w: truncated size", ErrInvalidDatabase)
+	}
+	b := d.buf[offset : offset+n]
+
+	switch size {
+	case 29:
+		return 29 + uint(b[0]), offset + n, nil
+	case 30:
+		return 285 + (uint(b[0])<<8 | uint(b[1])), offset + n, nil
+	default:
+		return 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])), offset + n, nil
+	}
+}
+
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("%w: truncated pointer", ErrInvalidDatabase)
+	}
+	b := d.buf[offset : offset+n]
+	v := uint(ctrl & 0x7)
+
+	switch n {
+	case 1:
+		return v<<8 | uint(b[0]), offset + n, nil
+	case 2:
+		return (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048, offset + n, nil
+	case 3:
+		return (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336, offset + n, nil
+	default:
+		return uint(binary.BigEndian.Uint32(b)), offset + n, nil
+	}
+}
+
+func toUint(v any) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int32:
+		return uint64(n)
+	}
+	return 0
+}
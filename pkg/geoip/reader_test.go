@@ -0,0 +1,415 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+)
+
+// pointer is a data section pointer in an encoded fixture.
+type pointer uint
+
+// encode writes v in the MaxMind DB data section format. It handles the
+// types the fixtures use: maps, arrays, strings, unsigned ints and pointers.
+func encode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case pointer:
+		if v >= 2048 {
+			panic("fixture pointers must fit in 11 bits")
+		}
+		buf.WriteByte(typePointer<<5 | byte(v>>8))
+		buf.WriteByte(byte(v))
+	case string:
+		writeCtrl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case uint64:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], v)
+		payload := bytes.TrimLeft(b[:], "\x00")
+		writeCtrl(buf, typeUint64, len(payload))
+		buf.Write(payload)
+	case uint32:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], v)
+		payload := bytes.TrimLeft(b[:], "\x00")
+		writeCtrl(buf, typeUint32, len(payload))
+		buf.Write(payload)
+	case uint16:
+		payload := bytes.TrimLeft([]byte{byte(v >> 8), byte(v)}, "\x00")
+		writeCtrl(buf, typeUint16, len(payload))
+		buf.Write(payload)
+	case []any:
+		writeCtrl(buf, typeArray, len(v))
+		for _, item := range v {
+			encode(buf, item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		writeCtrl(buf, typeMap, len(v))
+		for _, k := range keys {
+			encode(buf, k)
+			encode(buf, v[k])
+		}
+	default:
+		panic("unsupported fixture value")
+	}
+}
+
+func writeCtrl(buf *bytes.Buffer, typ, size int) {
+	var extra []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		extra = []byte{byte(size - 29)}
+		size = 29
+	case size < 65821:
+		s := size - 285
+		extra = []byte{byte(s >> 8), byte(s)}
+		size = 30
+	default:
+		s := size - 65821
+		extra = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+		size = 31
+	}
+
+	if typ > 7 {
+		buf.WriteByte(byte(size))
+		buf.WriteByte(byte(typ - 7))
+	} else {
+		buf.WriteByte(byte(typ<<5 | size))
+	}
+	buf.Write(extra)
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	data     [2]*uint
+	id       uint
+}
+
+type network struct {
+	cidr   string
+	record map[string]any
+}
+
+// buildDatabase writes a database mapping each network to its record.
+func buildDatabase(t *testing.T, ipVersion, recordSize uint, networks []network) []byte {
+	t.Helper()
+
+	var data bytes.Buffer
+	root := &trieNode{}
+
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := ipNet.Mask.Size()
+
+		bits := []byte(ipNet.IP.To16())
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			if ipVersion == 4 {
+				bits = ip4
+			} else {
+				// IPv4 lives in ::/96 of an IPv6 tree.
+				bits = append(make([]byte, 12), ip4...)
+				ones += 96
+			}
+		}
+
+		offset := uint(data.Len())
+		encode(&data, n.record)
+
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+			if i == ones-1 {
+				node.data[bit] = &offset
+				break
+			}
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{}
+			}
+			node = node.children[bit]
+		}
+	}
+
+	var nodes []*trieNode
+	var number func(n *trieNode)
+	number = func(n *trieNode) {
+		n.id = uint(len(nodes))
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			if child != nil {
+				number(child)
+			}
+		}
+	}
+	number(root)
+	nodeCount := uint(len(nodes))
+
+	var tree bytes.Buffer
+	for _, n := range nodes {
+		var records [2]uint
+		for i := range records {
+			switch {
+			case n.children[i] != nil:
+				records[i] = n.children[i].id
+			case n.data[i] != nil:
+				records[i] = nodeCount + 16 + *n.data[i]
+			default:
+				records[i] = nodeCount
+			}
+		}
+
+		left, right := records[0], records[1]
+		switch recordSize {
+		case 24:
+			tree.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			tree.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>24)<<4 | byte(right>>24)&0x0F, byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			var b [8]byte
+			binary.BigEndian.PutUint32(b[:4], uint32(left))
+			binary.BigEndian.PutUint32(b[4:], uint32(right))
+			tree.Write(b[:])
+		}
+	}
+
+	var file bytes.Buffer
+	file.Write(tree.Bytes())
+	file.Write(make([]byte, 16))
+	file.Write(data.Bytes())
+	file.Write(metadataMarker)
+	encode(&file, map[string]any{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(ipVersion),
+		"database_type":               "Test-Country",
+		"build_epoch":                 uint64(1700000000),
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"languages":                   []any{"en"},
+	})
+
+	return file.Bytes()
+}
+
+func country(code string) map[string]any {
+	return map[string]any{
+		"country": map[string]any{
+			"iso_code":   code,
+			"geoname_id": uint32(1),
+		},
+	}
+}
+
+func TestCountry(t *testing.T) {
+	networks := []network{
+		{"1.2.3.0/24", country("AU")},
+		{"81.0.0.0/8", country("DE")},
+		{"203.0.113.128/25", map[string]any{
+			"registered_country": map[string]any{"iso_code": "JP"},
+		}},
+		{"2001:db8::/32", country("NL")},
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+		v4   bool
+	}{
+		{ip: "1.2.3.4", want: "AU", v4: true},
+		{ip: "1.2.4.4", want: "", v4: true},
+		{ip: "81.200.1.1", want: "DE", v4: true},
+		{ip: "203.0.113.200", want: "JP", v4: true},
+		{ip: "203.0.113.1", want: "", v4: true},
+		{ip: "2001:db8::1", want: "NL"},
+		{ip: "2001:db9::1", want: ""},
+	}
+
+	for _, ipVersion := range []uint{4, 6} {
+		for _, recordSize := range []uint{24, 28, 32} {
+			var nets []network
+			for _, n := range networks {
+				if ipVersion == 4 && bytes.Contains([]byte(n.cidr), []byte(":")) {
+					continue
+				}
+				nets = append(nets, n)
+			}
+
+			r, err := FromBytes(buildDatabase(t, ipVersion, recordSize, nets))
+			if err != nil {
+				t.Fatalf("v%d/%d: FromBytes: %v", ipVersion, recordSize, err)
+			}
+			if r.DatabaseType != "Test-Country" || r.BuildEpoch != 1700000000 {
+				t.Errorf("v%d/%d: metadata = %q %d", ipVersion, recordSize, r.DatabaseType, r.BuildEpoch)
+			}
+
+			for _, tt := range tests {
+				if ipVersion == 4 && !tt.v4 {
+					continue
+				}
+				got, err := r.Country(net.ParseIP(tt.ip))
+				if err != nil {
+					t.Fatalf("v%d/%d: Country(%s): %v", ipVersion, recordSize, tt.ip, err)
+				}
+				if got != tt.want {
+					t.Errorf("v%d/%d: Country(%s) = %q, want %q", ipVersion, recordSize, tt.ip, got, tt.want)
+				}
+			}
+
+			if ipVersion == 4 {
+				record, err := r.Lookup(net.ParseIP("2001:db8::1"))
+				if record != nil || err != nil {
+					t.Errorf("v4/%d: ipv6 lookup = %v, %v", recordSize, record, err)
+				}
+			}
+		}
+	}
+}
+
+func TestReadNode(t *testing.T) {
+	tests := []struct {
+		recordSize  uint
+		tree        []byte
+		left, right uint
+	}{
+		{24, []byte{0x01, 0x02, 0x03, 0xFA, 0xFB, 0xFC}, 0x010203, 0xFAFBFC},
+		{28, []byte{0x01, 0x02, 0x03, 0xAB, 0x04, 0x05, 0x06}, 0xA010203, 0xB040506},
+		{32, []byte{0xF1, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0xF8}, 0xF1020304, 0x050607F8},
+	}
+
+	for _, tt := range tests {
+		r := &Reader{tree: tt.tree, recordSize: tt.recordSize, nodeCount: 1}
+		if got := r.readNode(0, 0); got != tt.left {
+			t.Errorf("%d: left = %#x, want %#x", tt.recordSize, got, tt.left)
+		}
+		if got := r.readNode(0, 1); got != tt.right {
+			t.Errorf("%d: right = %#x, want %#x", tt.recordSize, got, tt.right)
+		}
+	}
+
+	// A node past the end of the tree reads as "not found".
+	r := &Reader{tree: make([]byte, 6), recordSize: 24, nodeCount: 1}
+	if got := r.readNode(5, 0); got != 1 {
+		t.Errorf("out of range node = %d, want 1", got)
+	}
+}
+
+func TestLongValues(t *testing.T) {
+	// A value this long needs the three byte size form.
+	long := string(bytes.Repeat([]byte("x"), 70000))
+	networks := []network{
+		{"10.0.0.0/8", map[string]any{"padding": long}},
+		{"11.0.0.0/8", country("FR")},
+	}
+
+	for _, recordSize := range []uint{24, 28, 32} {
+		r, err := FromBytes(buildDatabase(t, 4, recordSize, networks))
+		if err != nil {
+			t.Fatalf("%d: %v", recordSize, err)
+		}
+
+		record, err := r.Lookup(net.ParseIP("10.1.1.1"))
+		if err != nil {
+			t.Fatalf("%d: %v", recordSize, err)
+		}
+		if got := record.(map[string]any)["padding"]; got != long {
+			t.Errorf("%d: long string was not decoded", recordSize)
+		}
+
+		if got, _ := r.Country(net.ParseIP("11.1.1.1")); got != "FR" {
+			t.Errorf("%d: Country = %q, want FR", recordSize, got)
+		}
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	valid := buildDatabase(t, 6, 28, []network{{"2001:db8::/32", country("NL")}})
+	marker := bytes.LastIndex(valid, metadataMarker)
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"no metadata", valid[:marker]},
+		{"truncated tree", append(append([]byte(nil), valid[:10]...), valid[marker:]...)},
+		{"truncated metadata", valid[:len(valid)-5]},
+	}
+
+	for _, tt := range tests {
+		if _, err := FromBytes(tt.buf); !errors.Is(err, ErrInvalidDatabase) {
+			t.Errorf("%s: err = %v, want ErrInvalidDatabase", tt.name, err)
+		}
+	}
+}
+
+func TestDecodeRejectsCorruptData(t *testing.T) {
+	var nested bytes.Buffer
+	for i := 0; i < maxDepth+2; i++ {
+		writeCtrl(&nested, typeArray, 1)
+	}
+	encode(&nested, "deep")
+
+	var pointerChain bytes.Buffer
+	encode(&pointerChain, pointer(2))
+	encode(&pointerChain, pointer(4))
+	encode(&pointerChain, "value")
+
+	var cycle bytes.Buffer
+	encode(&cycle, []any{pointer(0)})
+
+	var hugeMap bytes.Buffer
+	writeCtrl(&hugeMap, typeMap, 1<<20)
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"self pointer", []byte{typePointer << 5, 0}},
+		{"pointer to pointer", pointerChain.Bytes()},
+		{"pointer cycle through array", cycle.Bytes()},
+		{"deep nesting", nested.Bytes()},
+		{"map larger than data", hugeMap.Bytes()},
+		{"truncated string", []byte{typeString<<5 | 10, 'a'}},
+		{"truncated pointer", []byte{typePointer<<5 | 0x18}},
+	}
+
+	for _, tt := range tests {
+		d := decoder{buf: tt.buf}
+		if _, _, err := d.decode(0); !errors.Is(err, ErrInvalidDatabase) {
+			t.Errorf("%s: err = %v, want ErrInvalidDatabase", tt.name, err)
+		}
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	var buf bytes.Buffer
+	encode(&buf, "shared")
+	start := buf.Len()
+	encode(&buf, map[string]any{"a": pointer(0), "b": pointer(0)})
+
+	d := decoder{buf: buf.Bytes()}
+	value, next, err := d.decode(uint(start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != uint(buf.Len()) {
+		t.Errorf("next = %d, want %d", next, buf.Len())
+	}
+
+	m := value.(map[string]any)
+	if m["a"] != "shared" || m["b"] != "shared" {
+		t.Errorf("value = %v", m)
+	}
+}
@@ -0,0 +1,261 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kuchak/pkg/oidc/oidctest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "https://kuchak.test/api/v1/auth/oidc/test/callback"
+
+func newTestProvider(idp *oidctest.Provider) *Provider {
+	return NewProvider(Config{
+		Issuer:       idp.URL,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+func TestSignIn(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+
+	ctx := context.Background()
+	p := newTestProvider(idp)
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if !strings.HasPrefix(authURL, idp.URL+"/authorize?") {
+		t.Errorf("AuthCodeURL = %s, want the authorization endpoint", authURL)
+	}
+	for name, want := range map[string]string{
+		"client_id":      oidctest.ClientID,
+		"redirect_uri":   redirectURL,
+		"scope":          "openid email",
+		"state":          "state",
+		"nonce":          "nonce",
+		"code_challenge": Challenge("verifier"),
+	} {
+		if got := q.Get(name); got != want {
+			t.Errorf("AuthCodeURL %s = %q, want %q", name, got, want)
+		}
+	}
+
+	_, code, err := idp.Authorize(authURL, "alice", jwt.MapClaims{"email": "alice@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	rawIDToken, err := p.Exchange(ctx, code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := p.Verify(ctx, rawIDToken, "nonce")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "alice" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Errorf("Verify claims = %+v", claims)
+	}
+
+	if _, err := p.Exchange(ctx, code, "verifier"); err == nil {
+		t.Error("Exchange of a used code succeeded")
+	}
+}
+
+func TestExchangeChecksVerifier(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+
+	ctx := context.Background()
+	p := newTestProvider(idp)
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	_, code, err := idp.Authorize(authURL, "alice", nil)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	if _, err := p.Exchange(ctx, code, "other verifier"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Exchange with the wrong verifier error = %v, want invalid_grant", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	other := oidctest.NewProvider()
+	defer other.Close()
+
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token func() string
+		ok    bool
+	}{
+		{
+			name:  "valid",
+			token: func() string { return idp.Sign(idp.Claims("alice", "nonce")) },
+			ok:    true,
+		},
+		{
+			name: "nonce mismatch",
+			token: func() string {
+				return idp.Sign(idp.Claims("alice", "other nonce"))
+			},
+		},
+		{
+			name: "other audience",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				claims["aud"] = "someone-else"
+				return idp.Sign(claims)
+			},
+		},
+		{
+			name: "several audiences without azp",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				claims["aud"] = []string{oidctest.ClientID, "someone-else"}
+				return idp.Sign(claims)
+			},
+		},
+		{
+			name: "several audiences issued to someone else",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				claims["aud"] = []string{oidctest.ClientID, "someone-else"}
+				claims["azp"] = "someone-else"
+				return idp.Sign(claims)
+			},
+		},
+		{
+			name: "several audiences issued to us",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				claims["aud"] = []string{oidctest.ClientID, "someone-else"}
+				claims["azp"] = oidctest.ClientID
+				return idp.Sign(claims)
+			},
+			ok: true,
+		},
+		{
+			name: "issuer mismatch",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				claims["iss"] = other.URL
+				return idp.Sign(claims)
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				claims["iat"] = now.Add(-time.Hour).Unix()
+				claims["exp"] = now.Add(-10 * time.Minute).Unix()
+				return idp.Sign(claims)
+			},
+		},
+		{
+			name: "no expiry",
+			token: func() string {
+				claims := idp.Claims("alice", "nonce")
+				delete(claims, "exp")
+				return idp.Sign(claims)
+			},
+		},
+		{
+			name: "no subject",
+			token: func() string {
+				return idp.Sign(idp.Claims("", "nonce"))
+			},
+		},
+		{
+			name: "signed by another key",
+			token: func() string {
+				return other.Sign(idp.Claims("alice", "nonce"))
+			},
+		},
+		{
+			name: "hmac",
+			token: func() string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.Claims("alice", "nonce")).SignedString([]byte(oidctest.ClientSecret))
+				return signed
+			},
+		},
+		{
+			name: "none",
+			token: func() string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, idp.Claims("alice", "nonce")).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return signed
+			},
+		},
+	}
+
+	p := newTestProvider(idp)
+	for _, tt := range tests {
+		_, err := p.Verify(context.Background(), tt.token(), "nonce")
+		if tt.ok && err != nil {
+			t.Errorf("%s: Verify: %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Verify error = %v, want %v", tt.name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+
+	p := NewProvider(Config{
+		Issuer:   idp.URL + "/",
+		ClientID: oidctest.ClientID,
+	})
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("AuthCodeURL with another issuer error = %v, want an issuer mismatch", err)
+	}
+}
+
+func TestBool(t *testing.T) {
+	tests := []struct {
+		json string
+		want Bool
+		ok   bool
+	}{
+		{`true`, true, true},
+		{`"true"`, true, true},
+		{`false`, false, true},
+		{`"false"`, false, true},
+		{`null`, false, true},
+		{`1`, false, false},
+		{`"yes"`, false, false},
+	}
+
+	for _, tt := range tests {
+		var b Bool
+		err := json.Unmarshal([]byte(tt.json), &b)
+		if (err == nil) != tt.ok || b != tt.want {
+			t.Errorf("Bool(%s) = %v, %v, want %v", tt.json, b, err, tt.want)
+		}
+	}
+}
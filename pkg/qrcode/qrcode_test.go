@@ -0,0 +1,424 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// Format information words from ISO/IEC 18004 Annex C, by level and mask.
+var goldenFormat = map[Level][8]int{
+	Low:      {0x77C4, 0x72F3, 0x7DAA, 0x789D, 0x662F, 0x6318, 0x6C41, 0x6976},
+	Medium:   {0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0},
+	Quartile: {0x355F, 0x3068, 0x3F31, 0x3A06, 0x24B4, 0x2183, 0x2EDA, 0x2BED},
+	High:     {0x1689, 0x13BE, 0x1CE7, 0x19D0, 0x0762, 0x0255, 0x0D0C, 0x083B},
+}
+
+// Version information words from ISO/IEC 18004 Annex D, versions 7 to 40.
+var goldenVersion = []int{
+	0x07C94, 0x085BC, 0x09A99, 0x0A4D3, 0x0BBF6, 0x0C762, 0x0D847, 0x0E60D,
+	0x0F928, 0x10B78, 0x1145D, 0x12A17, 0x13532, 0x149A6, 0x15683, 0x168C9,
+	0x177EC, 0x18EC4, 0x191E1, 0x1AFAB, 0x1B08E, 0x1CC1A, 0x1D33F, 0x1ED75,
+	0x1F250, 0x209D5, 0x216F0, 0x228BA, 0x2379F, 0x24B0B, 0x2542E, 0x26A64,
+	0x27541, 0x28C69,
+}
+
+type blockGroup struct {
+	count, data int
+}
+
+type symbol struct {
+	version   int
+	level     Level
+	capacity  int // bytes in byte mode
+	ecc       int // error correction codewords per block
+	groups    []blockGroup
+	alignment []int
+}
+
+// goldenSymbols are the ISO/IEC 18004 Table 7 and 9 characteristics of the
+// versions the tests encode.
+var goldenSymbols = []symbol{
+	{1, Low, 17, 7, []blockGroup{{1, 19}}, nil},
+	{1, Medium, 14, 10, []blockGroup{{1, 16}}, nil},
+	{1, Quartile, 11, 13, []blockGroup{{1, 13}}, nil},
+	{1, High, 7, 17, []blockGroup{{1, 9}}, nil},
+	{7, Low, 154, 20, []blockGroup{{2, 78}}, []int{6, 22, 38}},
+	{7, Medium, 122, 18, []blockGroup{{4, 31}}, []int{6, 22, 38}},
+	{7, Quartile, 86, 18, []blockGroup{{2, 14}, {4, 15}}, []int{6, 22, 38}},
+	{7, High, 64, 26, []blockGroup{{4, 13}, {1, 14}}, []int{6, 22, 38}},
+	{40, Low, 2953, 30, []blockGroup{{19, 118}, {6, 119}}, []int{6, 30, 58, 86, 114, 142, 170}},
+	{40, Medium, 2331, 28, []blockGroup{{18, 47}, {31, 48}}, []int{6, 30, 58, 86, 114, 142, 170}},
+	{40, Quartile, 1663, 30, []blockGroup{{34, 24}, {34, 25}}, []int{6, 30, 58, 86, 114, 142, 170}},
+	{40, High, 1273, 30, []blockGroup{{20, 15}, {61, 16}}, []int{6, 30, 58, 86, 114, 142, 170}},
+}
+
+func TestFormatInfo(t *testing.T) {
+	for level, words := range goldenFormat {
+		for mask, want := range words {
+			if got := formatInfo(level, mask); got != want {
+				t.Errorf("formatInfo(%d, %d) = %#04x, want %#04x", level, mask, got, want)
+			}
+		}
+	}
+}
+
+func TestVersionInfo(t *testing.T) {
+	for i, want := range goldenVersion {
+		if got := versionInfo(i + 7); got != want {
+			t.Errorf("versionInfo(%d) = %#05x, want %#05x", i+7, got, want)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	tests := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		14: {6, 26, 46, 66},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+	for version, want := range tests {
+		got := alignmentPositions(version)
+		if len(got) != len(want) {
+			t.Errorf("alignmentPositions(%d) = %v, want %v", version, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("alignmentPositions(%d) = %v, want %v", version, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestCodewordCounts(t *testing.T) {
+	// Total codewords of a symbol, ISO/IEC 18004 Table 1.
+	totals := map[int]int{1: 26, 2: 44, 3: 70, 4: 100, 5: 134, 6: 172, 7: 196, 10: 346, 20: 1085, 40: 3706}
+	for version, want := range totals {
+		if got := rawDataModules(version) / 8; got != want {
+			t.Errorf("version %d has %d codewords, want %d", version, got, want)
+		}
+	}
+
+	for _, s := range goldenSymbols {
+		blocks, data := 0, 0
+		for _, g := range s.groups {
+			blocks += g.count
+			data += g.count * g.data
+		}
+		if got := eccBlocks[s.level][s.version]; got != blocks {
+			t.Errorf("%d-%d: %d blocks, want %d", s.version, s.level, got, blocks)
+		}
+		if got := eccCodewordsPerBlock[s.level][s.version]; got != s.ecc {
+			t.Errorf("%d-%d: %d ecc codewords per block, want %d", s.version, s.level, got, s.ecc)
+		}
+		if got := dataCodewords(s.version, s.level); got != data {
+			t.Errorf("%d-%d: %d data codewords, want %d", s.version, s.level, got, data)
+		}
+	}
+}
+
+func TestEncodeCapacity(t *testing.T) {
+	for _, s := range goldenSymbols {
+		q, err := Encode(make([]byte, s.capacity), s.level)
+		if err != nil {
+			t.Fatalf("%d-%d: %v", s.version, s.level, err)
+		}
+		if q.Version != s.version {
+			t.Errorf("%d-%d: %d bytes gave version %d", s.version, s.level, s.capacity, q.Version)
+		}
+
+		q, err = Encode(make([]byte, s.capacity+1), s.level)
+		if s.version == 40 {
+			if !errors.Is(err, ErrTooLong) {
+				t.Errorf("40-%d: %d bytes gave %v, want ErrTooLong", s.level, s.capacity+1, err)
+			}
+			continue
+		}
+		if err != nil || q.Version != s.version+1 {
+			t.Errorf("%d-%d: %d bytes did not move to the next version", s.version, s.level, s.capacity+1)
+		}
+	}
+}
+
+// TestEncodeDecodes reads every golden symbol back with a decoder written
+// from the standard, independent of the encoder's own tables.
+func TestEncodeDecodes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, s := range goldenSymbols {
+		for _, n := range []int{s.capacity, s.capacity - 3} {
+			data := make([]byte, n)
+			rng.Read(data)
+
+			q, err := Encode(data, s.level)
+			if err != nil {
+				t.Fatalf("%d-%d: %v", s.version, s.level, err)
+			}
+			if q.Version != s.version {
+				t.Fatalf("%d-%d: got version %d", s.version, s.level, q.Version)
+			}
+
+			got, err := decode(q, s)
+			if err != nil {
+				t.Fatalf("%d-%d, %d bytes: %v", s.version, s.level, n, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("%d-%d, %d bytes: decoded data differs", s.version, s.level, n)
+			}
+		}
+	}
+}
+
+type decodeError string
+
+func (e decodeError) Error() string { return string(e) }
+
+func decode(q *Code, s symbol) ([]byte, error) {
+	size := s.version*4 + 17
+	if q.Size != size {
+		return nil, decodeError("wrong size")
+	}
+	dark := func(x, y int) bool { return q.Dark(x, y) }
+
+	// Function patterns, marked from the standard's layout.
+	function := newGrid(size)
+	mark := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				function[y][x] = true
+			}
+		}
+	}
+	mark(0, 0, 9, 9)
+	mark(size-8, 0, 8, 9)
+	mark(0, size-8, 9, 8)
+	mark(6, 0, 1, size)
+	mark(0, 6, size, 1)
+	if s.version >= 7 {
+		mark(size-11, 0, 3, 6)
+		mark(0, size-11, 6, 3)
+	}
+	for _, cx := range s.alignment {
+		for _, cy := range s.alignment {
+			// The three corners taken by finder patterns have none.
+			if (cx == 6 && cy == 6) || (cx == 6 && cy == size-7) || (cx == size-7 && cy == 6) {
+				continue
+			}
+			mark(cx-2, cy-2, 5, 5)
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					ring := max(abs(dx), abs(dy))
+					if dark(cx+dx, cy+dy) != (ring != 1) {
+						return nil, decodeError("broken alignment pattern")
+					}
+				}
+			}
+		}
+	}
+
+	for y := range function {
+		for x := range function[y] {
+			if function[y][x] != q.function[y][x] {
+				return nil, decodeError("function patterns differ from the standard")
+			}
+		}
+	}
+
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if dark(corner[0]+dx, corner[1]+dy) != (ring != 2) {
+					return nil, decodeError("broken finder pattern")
+				}
+			}
+		}
+	}
+	for i := 8; i < size-8; i++ {
+		if dark(i, 6) != (i%2 == 0) || dark(6, i) != (i%2 == 0) {
+			return nil, decodeError("broken timing pattern")
+		}
+	}
+	if !dark(8, size-8) {
+		return nil, decodeError("missing dark module")
+	}
+
+	// Format information, most significant bit first, in both copies.
+	var format1, format2 int
+	for _, p := range [][2]int{{0, 8}, {1, 8}, {2, 8}, {3, 8}, {4, 8}, {5, 8}, {7, 8}, {8, 8}, {8, 7}, {8, 5}, {8, 4}, {8, 3}, {8, 2}, {8, 1}, {8, 0}} {
+		format1 <<= 1
+		if dark(p[0], p[1]) {
+			format1 |= 1
+		}
+	}
+	for i := 0; i < 7; i++ {
+		format2 <<= 1
+		if dark(8, size-1-i) {
+			format2 |= 1
+		}
+	}
+	for i := 0; i < 8; i++ {
+		format2 <<= 1
+		if dark(size-8+i, 8) {
+			format2 |= 1
+		}
+	}
+	if format1 != format2 || format1 != goldenFormat[s.level][q.Mask] {
+		return nil, decodeError("wrong format information")
+	}
+
+	if s.version >= 7 {
+		var version1, version2 int
+		for i := 17; i >= 0; i-- {
+			version1 <<= 1
+			version2 <<= 1
+			if dark(size-11+i%3, i/3) {
+				version1 |= 1
+			}
+			if dark(i/3, size-11+i%3) {
+				version2 |= 1
+			}
+		}
+		if version1 != version2 || version1 != goldenVersion[s.version-7] {
+			return nil, decodeError("wrong version information")
+		}
+	}
+
+	masks := [8]func(i, j int) bool{
+		func(i, j int) bool { return (i+j)%2 == 0 },
+		func(i, j int) bool { return i%2 == 0 },
+		func(i, j int) bool { return j%3 == 0 },
+		func(i, j int) bool { return (i+j)%3 == 0 },
+		func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+		func(i, j int) bool { return (i*j)%2+(i*j)%3 == 0 },
+		func(i, j int) bool { return ((i*j)%2+(i*j)%3)%2 == 0 },
+		func(i, j int) bool { return ((i+j)%2+(i*j)%3)%2 == 0 },
+	}
+	mask := masks[q.Mask]
+
+	// Codewords are placed in two module wide columns, right to left,
+	// alternately upwards and downwards, skipping the vertical timing line.
+	var bits []bool
+	upwards := true
+	for col := size - 1; col > 0; col -= 2 {
+		if col == 6 {
+			col--
+		}
+		for k := 0; k < size; k++ {
+			row := k
+			if upwards {
+				row = size - 1 - k
+			}
+			for _, x := range []int{col, col - 1} {
+				if !function[row][x] {
+					bits = append(bits, dark(x, row) != mask(row, x))
+				}
+			}
+		}
+		upwards = !upwards
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for _, bit := range bits[i*8 : i*8+8] {
+			codewords[i] <<= 1
+			if bit {
+				codewords[i] |= 1
+			}
+		}
+	}
+
+	// Undo the interleaving and check every block's Reed-Solomon syndromes.
+	var blocks [][]byte
+	var maxData int
+	for _, g := range s.groups {
+		for i := 0; i < g.count; i++ {
+			blocks = append(blocks, make([]byte, 0, g.data+s.ecc))
+		}
+		maxData = max(maxData, g.data)
+	}
+	dataLens := make([]int, 0, len(blocks))
+	for _, g := range s.groups {
+		for i := 0; i < g.count; i++ {
+			dataLens = append(dataLens, g.data)
+		}
+	}
+
+	next := 0
+	for i := 0; i < maxData; i++ {
+		for b := range blocks {
+			if i < dataLens[b] {
+				blocks[b] = append(blocks[b], codewords[next])
+				next++
+			}
+		}
+	}
+	for i := 0; i < s.ecc; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[next])
+			next++
+		}
+	}
+
+	var stream []byte
+	for b, block := range blocks {
+		for i := 0; i < s.ecc; i++ {
+			var syndrome byte
+			for _, c := range block {
+				syndrome = gfMultiply(syndrome, gfExp[i]) ^ c
+			}
+			if syndrome != 0 {
+				return nil, decodeError("reed-solomon syndrome is not zero")
+			}
+		}
+		stream = append(stream, block[:dataLens[b]]...)
+	}
+
+	// Byte mode segment, terminator and padding.
+	r := bitReader{buf: stream}
+	if r.read(4) != 0b0100 {
+		return nil, decodeError("not byte mode")
+	}
+	countBits := 8
+	if s.version >= 10 {
+		countBits = 16
+	}
+	n := r.read(countBits)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+
+	for r.pos%8 != 0 {
+		if r.read(1) != 0 {
+			return nil, decodeError("terminator is not zero")
+		}
+	}
+	for pad := byte(0xEC); r.pos < len(stream)*8; pad ^= 0xEC ^ 0x11 {
+		if byte(r.read(8)) != pad {
+			return nil, decodeError("wrong padding")
+		}
+	}
+
+	return out, nil
+}
+
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | int(r.buf[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
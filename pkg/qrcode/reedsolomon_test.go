@@ -0,0 +1,110 @@
+package qrcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+// gfExp is α^i in GF(2^8) with the QR code polynomial 0x11D, built by
+// shifting rather than with gfMultiply so the two can check each other.
+var gfExp = func() [255]byte {
+	var exp [255]byte
+	x := 1
+	for i := range exp {
+		exp[i] = byte(x)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	return exp
+}()
+
+func gfLog(x byte) int {
+	for i, v := range gfExp {
+		if v == x {
+			return i
+		}
+	}
+	panic("log of zero")
+}
+
+func TestGFMultiply(t *testing.T) {
+	tests := []struct{ x, y, want byte }{
+		{0, 0, 0},
+		{0, 0xFF, 0},
+		{1, 0xA7, 0xA7},
+		{2, 0x80, 0x1D},
+		{0x80, 0x80, 0x13},
+		{3, 7, 9},
+		{0xFF, 0xFF, 0xE2},
+	}
+	for _, tt := range tests {
+		if got := gfMultiply(tt.x, tt.y); got != tt.want {
+			t.Errorf("gfMultiply(%#x, %#x) = %#x, want %#x", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	// Known logarithms of the QR code field.
+	for value, log := range map[byte]int{2: 1, 29: 8, 3: 25, 0x8E: 254, 0x74: 10} {
+		if gfExp[log] != value {
+			t.Errorf("α^%d = %d, want %d", log, gfExp[log], value)
+		}
+	}
+
+	for x := 1; x < 256; x++ {
+		for y := 1; y < 256; y++ {
+			want := gfExp[(gfLog(byte(x))+gfLog(byte(y)))%255]
+			if got := gfMultiply(byte(x), byte(y)); got != want {
+				t.Fatalf("gfMultiply(%d, %d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRSDivisor(t *testing.T) {
+	// Generator polynomials as the exponents of α of their coefficients,
+	// leading term left out, from ISO/IEC 18004 Annex A.
+	tests := map[int][]int{
+		7:  {87, 229, 146, 149, 238, 102, 21},
+		10: {251, 67, 46, 61, 118, 70, 64, 94, 32, 45},
+		13: {74, 152, 176, 100, 86, 100, 106, 104, 130, 218, 206, 140, 78},
+		17: {43, 139, 206, 78, 43, 239, 123, 206, 214, 147, 24, 99, 150, 39, 243, 163, 136},
+	}
+
+	for degree, exponents := range tests {
+		want := make([]byte, len(exponents))
+		for i, e := range exponents {
+			want[i] = gfExp[e]
+		}
+		if got := rsDivisor(degree); !bytes.Equal(got, want) {
+			t.Errorf("rsDivisor(%d) = %v, want %v", degree, got, want)
+		}
+	}
+}
+
+func TestRSRemainder(t *testing.T) {
+	tests := []struct {
+		name      string
+		data, ecc []byte
+	}{
+		{
+			// "HELLO WORLD" as 1-M in alphanumeric mode.
+			name: "hello world",
+			data: []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			ecc:  []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+		{
+			// "01234567" as 1-M in numeric mode, ISO/IEC 18004 Annex I.
+			name: "01234567",
+			data: []byte{16, 32, 12, 86, 97, 128, 236, 17, 236, 17, 236, 17, 236, 17, 236, 17},
+			ecc:  []byte{165, 36, 212, 193, 237, 54, 199, 135, 44, 85},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := rsRemainder(tt.data, rsDivisor(len(tt.ecc))); !bytes.Equal(got, tt.ecc) {
+			t.Errorf("%s: rsRemainder = %v, want %v", tt.name, got, tt.ecc)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package unfurl fetches the title and description of web pages from their
+// <title> and OpenGraph tags. Fetches are bounded in time and size and only
+// ever connect to public addresses.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	ErrBlockedAddress = errors.New("address is not allowed")
+	ErrNotHTML        = errors.New("response is not html")
+)
+
+type Page struct {
+	Title       string
+	Description string
+}
+
+type Options struct {
+	// Timeout bounds a whole fetch, redirects and body included.
+	Timeout time.Duration
+	// MaxBytes is how much of the body is read, the rest is ignored.
+	MaxBytes     int64
+	MaxRedirects int
+	UserAgent    string
+	// Allow decides which resolved addresses may be connected to, it
+	// defaults to IsPublic. Tests can widen it to reach a local server.
+	Allow func(addr netip.Addr) bool
+}
+
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	allow := opts.Allow
+	if allow == nil {
+		allow = IsPublic
+	}
+
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		// Control runs after name resolution, right before connecting, so
+		// a host that resolves to an internal address is caught even when
+		// it changes its answer between lookups.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			if !allow(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    opts.Timeout,
+		ResponseHeaderTimeout:  opts.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		DisableKeepAlives:      true,
+	}
+
+	maxRedirects := opts.MaxRedirects
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+
+	return &Fetcher{
+		client:    client,
+		maxBytes:  opts.MaxBytes,
+		userAgent: opts.UserAgent,
+	}
+}
+
+// Fetch downloads rawURL and extracts its metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to build request: %w", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return Page{}, fmt.Errorf("unsupported scheme %s", req.URL.Scheme)
+	}
+
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	if f.userAgent != "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Page{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Page{}, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+
+	return Parse(io.LimitReader(resp.Body, f.maxBytes)), nil
+}
+
+// Parse reads the metadata in the head of an html document. OpenGraph
+// values win over <title> and the description meta tag.
+func Parse(r io.Reader) Page {
+	var title, ogTitle, description, ogDescription string
+
+	z := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return page(title, ogTitle, description, ogDescription)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = title == ""
+			case atom.Body:
+				return page(title, ogTitle, description, ogDescription)
+			case atom.Meta:
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for more := true; more; {
+					var attr, value []byte
+					attr, value, more = z.TagAttr()
+					switch string(attr) {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(string(value))
+						}
+					case "content":
+						content = string(value)
+					}
+				}
+				switch key {
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					ogDescription = content
+				case "description":
+					description = content
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return page(title, ogTitle, description, ogDescription)
+			}
+		}
+	}
+}
+
+func page(title, ogTitle, description, ogDescription string) Page {
+	if ogTitle != "" {
+		title = ogTitle
+	}
+	if ogDescription != "" {
+		description = ogDescription
+	}
+	return Page{
+		Title:       clean(title),
+		Description: clean(description),
+	}
+}
+
+// clean collapses whitespace and drops invalid utf-8, pages in other
+// encodings are not converted.
+func clean(s string) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,296 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allowLoopback lets the fetcher reach an httptest server.
+func allowLoopback(addr netip.Addr) bool {
+	return addr.Unmap().IsLoopback()
+}
+
+func testOptions() Options {
+	return Options{
+		Timeout:      2 * time.Second,
+		MaxBytes:     1 << 20,
+		MaxRedirects: 3,
+		UserAgent:    "kuchak-test",
+		Allow:        allowLoopback,
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want Page
+	}{
+		{
+			name: "title and description",
+			doc:  `<html><head><title>Hello</title><meta name="description" content="A page"></head></html>`,
+			want: Page{Title: "Hello", Description: "A page"},
+		},
+		{
+			name: "opengraph wins",
+			doc: `<html><head><title>Hello</title><meta name="description" content="A page">
+				<meta property="og:title" content="OG hello"><meta property="og:description" content="OG page"></head></html>`,
+			want: Page{Title: "OG hello", Description: "OG page"},
+		},
+		{
+			name: "opengraph before title",
+			doc:  `<head><meta property="OG:TITLE" content="First"><title>Second</title></head>`,
+			want: Page{Title: "First"},
+		},
+		{
+			name: "whitespace collapsed",
+			doc:  "<title>\n  Hello \t\n  world  </title>",
+			want: Page{Title: "Hello world"},
+		},
+		{
+			name: "entities decoded",
+			doc:  `<title>Fish &amp; chips</title><meta name="description" content="&quot;fresh&quot;">`,
+			want: Page{Title: "Fish & chips", Description: `"fresh"`},
+		},
+		{
+			name: "first title only",
+			doc:  `<title>One</title><svg><title>Two</title></svg>`,
+			want: Page{Title: "One"},
+		},
+		{
+			name: "body ignored",
+			doc:  `<html><head></head><body><title>Late</title><meta property="og:title" content="Late"></body></html>`,
+			want: Page{},
+		},
+		{
+			name: "invalid utf-8 dropped",
+			doc:  "<title>caf\xe9</title>",
+			want: Page{Title: "caf"},
+		},
+		{
+			name: "empty",
+			doc:  "",
+			want: Page{},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(strings.NewReader(tt.doc)); got != tt.want {
+			t.Errorf("%s: Parse = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	var userAgent string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Plain</title><meta property="og:title" content="Hello"><meta property="og:description" content="World"></head></html>`)
+	})
+	mux.HandleFunc("/xhtml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml")
+		fmt.Fprint(w, `<html><head><title>Strict</title></head></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := NewFetcher(testOptions())
+
+	page, err := f.Fetch(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if want := (Page{Title: "Hello", Description: "World"}); page != want {
+		t.Errorf("Fetch = %+v, want %+v", page, want)
+	}
+	if userAgent != "kuchak-test" {
+		t.Errorf("User-Agent = %q, want %q", userAgent, "kuchak-test")
+	}
+
+	page, err = f.Fetch(context.Background(), server.URL+"/xhtml")
+	if err != nil {
+		t.Fatalf("Fetch xhtml: %v", err)
+	}
+	if page.Title != "Strict" {
+		t.Errorf("Fetch xhtml title = %q, want %q", page.Title, "Strict")
+	}
+}
+
+func TestFetchMaxBytes(t *testing.T) {
+	head := `<html><head><title>Kept</title>`
+	rest := strings.Repeat(" ", 4096) + `<meta name="description" content="Dropped"></head></html>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, head+rest)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxBytes int64
+		want     Page
+	}{
+		{maxBytes: int64(len(head)) + 100, want: Page{Title: "Kept"}},
+		{maxBytes: int64(len(head) + len(rest)), want: Page{Title: "Kept", Description: "Dropped"}},
+	}
+
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.MaxBytes = tt.maxBytes
+
+		page, err := NewFetcher(opts).Fetch(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("%d bytes: Fetch: %v", tt.maxBytes, err)
+		}
+		if page != tt.want {
+			t.Errorf("%d bytes: Fetch = %+v, want %+v", tt.maxBytes, page, tt.want)
+		}
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	opts := testOptions()
+	opts.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := NewFetcher(opts).Fetch(context.Background(), server.URL); err == nil {
+		t.Fatal("Fetch of a stalled server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fetch took %s with a %s timeout", elapsed, opts.Timeout)
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hop/{n}", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.PathValue("n"))
+		if n == 0 {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<title>Landed</title>")
+			return
+		}
+		http.Redirect(w, r, "/hop/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := NewFetcher(testOptions())
+
+	page, err := f.Fetch(context.Background(), server.URL+"/hop/3")
+	if err != nil {
+		t.Fatalf("Fetch with 3 redirects: %v", err)
+	}
+	if page.Title != "Landed" {
+		t.Errorf("Fetch with 3 redirects title = %q, want %q", page.Title, "Landed")
+	}
+
+	if _, err := f.Fetch(context.Background(), server.URL+"/hop/4"); err == nil {
+		t.Error("Fetch with 4 redirects succeeded, want an error")
+	}
+
+	if _, err := f.Fetch(context.Background(), server.URL+"/ftp"); err == nil {
+		t.Error("Fetch redirected to ftp succeeded, want an error")
+	}
+}
+
+func TestFetchRejects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"title":"no"}`)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := NewFetcher(testOptions())
+
+	if _, err := f.Fetch(context.Background(), server.URL+"/json"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("Fetch json error = %v, want %v", err, ErrNotHTML)
+	}
+	if _, err := f.Fetch(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("Fetch of a 404 succeeded, want an error")
+	}
+	if _, err := f.Fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("Fetch of a file url succeeded, want an error")
+	}
+}
+
+func TestFetchBlocksInternalAddresses(t *testing.T) {
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<title>Internal</title>")
+	}))
+	defer server.Close()
+
+	opts := testOptions()
+	opts.Allow = nil
+
+	_, err := NewFetcher(opts).Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch of a loopback server error = %v, want %v", err, ErrBlockedAddress)
+	}
+	if hit {
+		t.Error("Fetch reached a loopback server")
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"240.0.0.1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"2001:db8::1", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package useragent
+
+import "strings"
+
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+	OSOther    = "other"
+
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "preview", "facebookexternalhit",
+	"embedly", "whatsapp", "curl/", "wget/", "python-requests", "go-http-client",
+	"httpclient", "headlesschrome", "phantomjs", "lighthouse",
+}
+
+type UserAgent struct {
+	OS     string `json:"os"`
+	Device string `json:"device"`
+	Bot    bool   `json:"bot"`
+}
+
+// Parse classifies a User-Agent header by operating system, device class
+// and whether it looks like an automated client.
+func Parse(header string) UserAgent {
+	ua := strings.ToLower(header)
+
+	result := UserAgent{
+		OS:     parseOS(ua),
+		Device: DeviceDesktop,
+		Bot:    header == "",
+	}
+
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			result.Bot = true
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(result.OS == OSAndroid && !strings.Contains(ua, "mobile")):
+		result.Device = DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		result.Device = DeviceMobile
+	}
+
+	return result
+}
+
+func parseOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ipod"):
+		return OSIOS
+	case strings.Contains(ua, "android"):
+		return OSAndroid
+	// The CrOS token is followed by the platform, matching it bare would
+	// also catch "microsoft".
+	case strings.Contains(ua, "cros "):
+		return OSChromeOS
+	case strings.Contains(ua, "windows"):
+		return OSWindows
+	case strings.Contains(ua, "mac os x") || strings.Contains(ua, "macintosh"):
+		return OSMacOS
+	case strings.Contains(ua, "linux") || strings.Contains(ua, "x11"):
+		return OSLinux
+	}
+	return OSOther
+}
@@ -0,0 +1,75 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   UserAgent
+	}{
+		{
+			name:   "iphone",
+			header: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			want:   UserAgent{OS: OSIOS, Device: DeviceMobile},
+		},
+		{
+			name:   "ipad",
+			header: "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			want:   UserAgent{OS: OSIOS, Device: DeviceTablet},
+		},
+		{
+			name:   "android phone",
+			header: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			want:   UserAgent{OS: OSAndroid, Device: DeviceMobile},
+		},
+		{
+			name:   "android tablet",
+			header: "Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want:   UserAgent{OS: OSAndroid, Device: DeviceTablet},
+		},
+		{
+			name:   "chromebook",
+			header: "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want:   UserAgent{OS: OSChromeOS, Device: DeviceDesktop},
+		},
+		{
+			name:   "windows",
+			header: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			want:   UserAgent{OS: OSWindows, Device: DeviceDesktop},
+		},
+		{
+			name:   "windows naming microsoft",
+			header: "Microsoft Office/16.0 (Windows NT 10.0; Microsoft Outlook 16.0.17029; Pro)",
+			want:   UserAgent{OS: OSWindows, Device: DeviceDesktop},
+		},
+		{
+			name:   "macos",
+			header: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+			want:   UserAgent{OS: OSMacOS, Device: DeviceDesktop},
+		},
+		{
+			name:   "linux",
+			header: "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+			want:   UserAgent{OS: OSLinux, Device: DeviceDesktop},
+		},
+		{
+			name:   "crawler",
+			header: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:   UserAgent{OS: OSOther, Device: DeviceDesktop, Bot: true},
+		},
+		{
+			name:   "empty",
+			header: "",
+			want:   UserAgent{OS: OSOther, Device: DeviceDesktop, Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.header); got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
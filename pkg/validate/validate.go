@@ -21,8 +21,15 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return nil
 }
 
+// MaxPasswordLength keeps hashing a password cheap enough to not be a way
+// to tie up the server.
+const MaxPasswordLength = 256
+
 func CustomPasswordValidator(fl validator.FieldLevel) bool {
 	password := fl.Field().String()
+	if len(password) > MaxPasswordLength {
+		return false
+	}
 
 	var hasMinLen, hasUpper, hasLower, hasNumber, hasSpecial bool
 	if len(password) >= 8 {